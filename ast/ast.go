@@ -6,6 +6,8 @@
 package ast
 
 import (
+	"strings"
+
 	"github.com/capnspacehook/rose/token"
 )
 
@@ -50,9 +52,185 @@ type Expr interface {
 	exprNode()
 }
 
+// -----------------------------------------------------------------------------
+// Comments
+
+// A Comment node represents a single //-style or /*-style comment.
+//
+// The Text field contains the comment text without carriage returns (\r) that
+// may have been present in the source. Because a comment's end position is
+// computed using len(Text), the position reported by End() does not match the
+// true source end position for comments containing carriage returns.
+type Comment struct {
+	Slash token.Pos // position of "/" starting the comment
+	Text  string    // comment text (excluding '\n' for //-style comments)
+}
+
+func (c *Comment) Pos() token.Pos { return c.Slash }
+func (c *Comment) End() token.Pos { return token.Pos(int(c.Slash) + len(c.Text)) }
+
+// A CommentGroup represents a sequence of comments
+// with no other tokens and no empty lines between.
+type CommentGroup struct {
+	List []*Comment // len(List) > 0
+}
+
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Pos { return g.List[len(g.List)-1].End() }
+
+func isWhitespace(ch byte) bool { return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' }
+
+func stripTrailingWhitespace(s string) string {
+	i := len(s)
+	for i > 0 && isWhitespace(s[i-1]) {
+		i--
+	}
+	return s[0:i]
+}
+
+// Text returns the text of the comment.
+// Comment markers (//, /*, and */), the first space of a line comment, and
+// leading and trailing empty lines are removed.
+// Multiple empty lines are reduced to one, and trailing space on lines is trimmed.
+// Unless the result is empty, it is newline-terminated.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	comments := make([]string, len(g.List))
+	for i, c := range g.List {
+		comments[i] = c.Text
+	}
+
+	lines := make([]string, 0, 10) // most comments are less than 10 lines
+	for _, c := range comments {
+		// Remove comment markers.
+		// The parser has given us exactly the comment text.
+		switch c[1] {
+		case '/':
+			//-style comment (no newline at the end)
+			c = c[2:]
+			if len(c) == 0 {
+				// empty line
+				break
+			}
+			if c[0] == ' ' {
+				// strip first space - required for Example tests
+				c = c[1:]
+			}
+		case '*':
+			/*-style comment */
+			c = c[2 : len(c)-2]
+		}
+
+		// Split on newlines.
+		cl := strings.Split(c, "\n")
+
+		// Walk lines, stripping trailing white space and adding to list.
+		for _, l := range cl {
+			lines = append(lines, stripTrailingWhitespace(l))
+		}
+	}
+
+	// Remove leading blank lines; convert runs of
+	// interior blank lines to a single blank line.
+	n := 0
+	for _, line := range lines {
+		if line != "" || n > 0 && lines[n-1] != "" {
+			lines[n] = line
+			n++
+		}
+	}
+	lines = lines[0:n]
+
+	// Add final "" entry to get trailing newline from Join.
+	if n > 0 && lines[n-1] != "" {
+		lines = append(lines, "")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 // -----------------------------------------------------------------------------
 // Expressions and types
 
+// A Field represents a parameter declaration in a signature or a
+// result type in a result list.
+type Field struct {
+	Names    []*Ident  // parameter names; or nil
+	Ellipsis token.Pos // position of "..." for a variadic parameter, if any
+	Type     Expr      // parameter or result type; or nil
+	Assign   token.Pos // position of "=", if any
+	Default  Expr      // default value; or nil
+}
+
+func (f *Field) Pos() token.Pos {
+	if f.Ellipsis.IsValid() {
+		return f.Ellipsis
+	}
+	if len(f.Names) > 0 {
+		return f.Names[0].Pos()
+	}
+	return f.Type.Pos()
+}
+
+func (f *Field) End() token.Pos {
+	if f.Default != nil {
+		return f.Default.End()
+	}
+	if f.Type != nil {
+		return f.Type.End()
+	}
+	return f.Names[len(f.Names)-1].End()
+}
+
+// A FieldList represents a list of Fields, enclosed by parentheses.
+type FieldList struct {
+	Opening token.Pos // position of opening parenthesis, if any
+	List    []*Field  // field list; or nil
+	Closing token.Pos // position of closing parenthesis, if any
+}
+
+func (f *FieldList) Pos() token.Pos {
+	if f.Opening.IsValid() {
+		return f.Opening
+	}
+	// the list should not be empty in this case;
+	// be conservative and guard against bad ASTs
+	if len(f.List) > 0 {
+		return f.List[0].Pos()
+	}
+	return token.NoPos
+}
+
+func (f *FieldList) End() token.Pos {
+	if f.Closing.IsValid() {
+		return f.Closing + 1
+	}
+	// the list should not be empty in this case;
+	// be conservative and guard against bad ASTs
+	if n := len(f.List); n > 0 {
+		return f.List[n-1].End()
+	}
+	return token.NoPos
+}
+
+// NumFields returns the number of parameters or result types
+// represented by a FieldList.
+func (f *FieldList) NumFields() int {
+	n := 0
+	if f != nil {
+		for _, g := range f.List {
+			m := len(g.Names)
+			if m == 0 {
+				m = 1
+			}
+			n += m
+		}
+	}
+	return n
+}
+
 // A BadExpr node is a placeholder for expressions containing
 // syntax errors for which no correct expression nodes can be
 // created.
@@ -70,10 +248,55 @@ type Ident struct {
 // A BasicLit node represents a literal of basic type.
 type BasicLit struct {
 	ValuePos token.Pos   // literal position
-	Kind     token.Token // token.INT, token.FLOAT, token.CHAR, token.STRING, or token.RAW_STRING
+	Kind     token.Token // token.INT, token.FLOAT, token.CHAR, token.STRING, token.RAW_STRING, or a string segment
 	Value    string      // literal string; e.g. 42, 0x7f, 3.14, 1e-9, 2.4i, 'a', '\x7f', "foo" or `\m\n\o`
 }
 
+// A FuncLit node represents a function literal.
+type FuncLit struct {
+	Type *FuncType  // function type
+	Body *BlockStmt // function body
+}
+
+// An InterpolatedString node represents a string literal containing
+// interpolated expressions, such as "Hi {name}!". The literal segments
+// are stored as *BasicLits of kind token.STRING_HEAD, token.STRING_MID
+// and token.STRING_TAIL whose values include the surrounding quote and
+// brace characters.
+type InterpolatedString struct {
+	Parts []Expr // literal segments and interpolated expressions, in source order
+}
+
+// A ListLit node represents a list literal.
+type ListLit struct {
+	Lbrack token.Pos // position of "["
+	Elts   []Expr    // list of list elements; or nil
+	Rbrack token.Pos // position of "]"
+}
+
+// A SetLit node represents a set literal.
+type SetLit struct {
+	Lbrace token.Pos // position of "{"
+	Elts   []Expr    // list of set elements; or nil
+	Rbrace token.Pos // position of "}"
+}
+
+// A MapLit node represents a map literal. An empty map
+// literal is written as {:}.
+type MapLit struct {
+	Lbrace token.Pos       // position of "{"
+	Elts   []*KeyValueExpr // list of map entries; or nil
+	Rbrace token.Pos       // position of "}"
+}
+
+// A TupleLit node represents a tuple literal. The parentheses
+// are optional unless the tuple is empty.
+type TupleLit struct {
+	Lparen token.Pos // position of "(", if any
+	Elts   []Expr    // list of tuple elements; or nil
+	Rparen token.Pos // position of ")", if any
+}
+
 // A ParenExpr node represents a parenthesized expression.
 type ParenExpr struct {
 	Lparen token.Pos // position of "("
@@ -81,6 +304,64 @@ type ParenExpr struct {
 	Rparen token.Pos // position of ")"
 }
 
+// A SelectorExpr node represents an expression followed by a selector.
+type SelectorExpr struct {
+	X   Expr   // expression
+	Sel *Ident // field selector
+}
+
+// An IndexExpr node represents an expression followed by an index.
+type IndexExpr struct {
+	X      Expr      // expression
+	Lbrack token.Pos // position of "["
+	Index  Expr      // index expression
+	Rbrack token.Pos // position of "]"
+}
+
+// A SliceExpr node represents an expression followed by slice indices.
+type SliceExpr struct {
+	X      Expr      // expression
+	Lbrack token.Pos // position of "["
+	Low    Expr      // begin of slice range; or nil
+	High   Expr      // end of slice range; or nil
+	Max    Expr      // maximum capacity of slice; or nil
+	Slice3 bool      // true if 3-index slice (2 colons present)
+	Rbrack token.Pos // position of "]"
+}
+
+// A TypeAssertExpr node represents an expression followed by the
+// "as" keyword and a type.
+type TypeAssertExpr struct {
+	X    Expr      // expression
+	As   token.Pos // position of "as"
+	Type Expr      // asserted type
+}
+
+// A CallExpr node represents an expression followed by an argument list.
+type CallExpr struct {
+	Fun      Expr      // function expression
+	Lparen   token.Pos // position of "("
+	Args     []Expr    // function arguments; or nil
+	Ellipsis token.Pos // position of "..." (token.NoPos if there is no "...")
+	Rparen   token.Pos // position of ")"
+}
+
+// A KeywordArg node represents a keyword argument of the form
+// name=value in an argument list.
+type KeywordArg struct {
+	Name   *Ident    // parameter name
+	Assign token.Pos // position of "="
+	Value  Expr      // argument value
+}
+
+// A KeyValueExpr node represents (key : value) pairs
+// in map literals.
+type KeyValueExpr struct {
+	Key   Expr
+	Colon token.Pos // position of ":"
+	Value Expr
+}
+
 // A UnaryExpr node represents a unary expression.
 type UnaryExpr struct {
 	OpPos token.Pos   // position of Op
@@ -96,29 +377,149 @@ type BinaryExpr struct {
 	Rhs   Expr        // right operand
 }
 
+// -----------------------------------------------------------------------------
+// Types
+//
+// A type is represented by a tree consisting of one or more of the
+// following type-specific expression nodes.
+
+// A FuncType node represents a function type. The parameter list
+// of a function literal may be omitted entirely, in which case
+// Params.Opening and Params.Closing are invalid.
+type FuncType struct {
+	Func    token.Pos  // position of "fn" keyword
+	Params  *FieldList // (incoming) parameters; non-nil
+	Results *FieldList // (outgoing) results; or nil
+}
+
+// A ListType node represents a list type.
+type ListType struct {
+	List   token.Pos // position of "list"
+	Lbrack token.Pos // position of "["
+	Elt    Expr      // element type
+	Rbrack token.Pos // position of "]"
+}
+
+// A SetType node represents a set type.
+type SetType struct {
+	Set    token.Pos // position of "set"
+	Lbrack token.Pos // position of "["
+	Elt    Expr      // element type
+	Rbrack token.Pos // position of "]"
+}
+
+// A MapType node represents a map type.
+type MapType struct {
+	Map    token.Pos // position of "map"
+	Lbrack token.Pos // position of "["
+	Key    Expr
+	Rbrack token.Pos // position of "]"
+	Value  Expr
+}
+
+// A TupleType node represents a tuple type.
+type TupleType struct {
+	Tuple  token.Pos // position of "tuple"
+	Lbrack token.Pos // position of "["
+	Elts   []Expr    // element types
+	Rbrack token.Pos // position of "]"
+}
+
 // Pos and End implementations for expression/type nodes.
-func (x *BadExpr) Pos() token.Pos    { return x.From }
-func (x *Ident) Pos() token.Pos      { return x.NamePos }
-func (x *BasicLit) Pos() token.Pos   { return x.ValuePos }
-func (x *ParenExpr) Pos() token.Pos  { return x.Lparen }
-func (x *UnaryExpr) Pos() token.Pos  { return x.OpPos }
-func (x *BinaryExpr) Pos() token.Pos { return x.Lhs.Pos() }
-
-func (x *BadExpr) End() token.Pos    { return x.To }
-func (x *Ident) End() token.Pos      { return token.Pos(int(x.NamePos) + len(x.Name)) }
-func (x *BasicLit) End() token.Pos   { return token.Pos(int(x.ValuePos) + len(x.Value)) }
-func (x *ParenExpr) End() token.Pos  { return x.Rparen + 1 }
-func (x *UnaryExpr) End() token.Pos  { return x.Expr.End() }
-func (x *BinaryExpr) End() token.Pos { return x.Rhs.End() }
+func (x *BadExpr) Pos() token.Pos            { return x.From }
+func (x *Ident) Pos() token.Pos              { return x.NamePos }
+func (x *BasicLit) Pos() token.Pos           { return x.ValuePos }
+func (x *FuncLit) Pos() token.Pos            { return x.Type.Pos() }
+func (x *InterpolatedString) Pos() token.Pos { return x.Parts[0].Pos() }
+func (x *ListLit) Pos() token.Pos            { return x.Lbrack }
+func (x *SetLit) Pos() token.Pos             { return x.Lbrace }
+func (x *MapLit) Pos() token.Pos             { return x.Lbrace }
+func (x *TupleLit) Pos() token.Pos {
+	if x.Lparen.IsValid() {
+		return x.Lparen
+	}
+	return x.Elts[0].Pos()
+}
+func (x *ParenExpr) Pos() token.Pos      { return x.Lparen }
+func (x *SelectorExpr) Pos() token.Pos   { return x.X.Pos() }
+func (x *IndexExpr) Pos() token.Pos      { return x.X.Pos() }
+func (x *SliceExpr) Pos() token.Pos      { return x.X.Pos() }
+func (x *TypeAssertExpr) Pos() token.Pos { return x.X.Pos() }
+func (x *CallExpr) Pos() token.Pos       { return x.Fun.Pos() }
+func (x *KeywordArg) Pos() token.Pos     { return x.Name.Pos() }
+func (x *KeyValueExpr) Pos() token.Pos   { return x.Key.Pos() }
+func (x *UnaryExpr) Pos() token.Pos      { return x.OpPos }
+func (x *BinaryExpr) Pos() token.Pos     { return x.Lhs.Pos() }
+func (x *FuncType) Pos() token.Pos       { return x.Func }
+func (x *ListType) Pos() token.Pos       { return x.List }
+func (x *SetType) Pos() token.Pos        { return x.Set }
+func (x *MapType) Pos() token.Pos        { return x.Map }
+func (x *TupleType) Pos() token.Pos      { return x.Tuple }
+
+func (x *BadExpr) End() token.Pos            { return x.To }
+func (x *Ident) End() token.Pos              { return token.Pos(int(x.NamePos) + len(x.Name)) }
+func (x *BasicLit) End() token.Pos           { return token.Pos(int(x.ValuePos) + len(x.Value)) }
+func (x *FuncLit) End() token.Pos            { return x.Body.End() }
+func (x *InterpolatedString) End() token.Pos { return x.Parts[len(x.Parts)-1].End() }
+func (x *ListLit) End() token.Pos            { return x.Rbrack + 1 }
+func (x *SetLit) End() token.Pos             { return x.Rbrace + 1 }
+func (x *MapLit) End() token.Pos             { return x.Rbrace + 1 }
+func (x *TupleLit) End() token.Pos {
+	if x.Rparen.IsValid() {
+		return x.Rparen + 1
+	}
+	return x.Elts[len(x.Elts)-1].End()
+}
+func (x *ParenExpr) End() token.Pos      { return x.Rparen + 1 }
+func (x *SelectorExpr) End() token.Pos   { return x.Sel.End() }
+func (x *IndexExpr) End() token.Pos      { return x.Rbrack + 1 }
+func (x *SliceExpr) End() token.Pos      { return x.Rbrack + 1 }
+func (x *TypeAssertExpr) End() token.Pos { return x.Type.End() }
+func (x *CallExpr) End() token.Pos       { return x.Rparen + 1 }
+func (x *KeywordArg) End() token.Pos     { return x.Value.End() }
+func (x *KeyValueExpr) End() token.Pos   { return x.Value.End() }
+func (x *UnaryExpr) End() token.Pos      { return x.Expr.End() }
+func (x *BinaryExpr) End() token.Pos     { return x.Rhs.End() }
+func (x *FuncType) End() token.Pos {
+	if x.Results != nil {
+		return x.Results.End()
+	}
+	if x.Params.Closing.IsValid() {
+		return x.Params.End()
+	}
+	return x.Func + 2 // len("fn")
+}
+func (x *ListType) End() token.Pos  { return x.Rbrack + 1 }
+func (x *SetType) End() token.Pos   { return x.Rbrack + 1 }
+func (x *MapType) End() token.Pos   { return x.Value.End() }
+func (x *TupleType) End() token.Pos { return x.Rbrack + 1 }
 
 // exprNode() ensures that only expression/type nodes can be
 // assigned to an Expr.
-func (*BadExpr) exprNode()    {}
-func (*Ident) exprNode()      {}
-func (*BasicLit) exprNode()   {}
-func (*ParenExpr) exprNode()  {}
-func (*UnaryExpr) exprNode()  {}
-func (*BinaryExpr) exprNode() {}
+func (*BadExpr) exprNode()            {}
+func (*Ident) exprNode()              {}
+func (*BasicLit) exprNode()           {}
+func (*FuncLit) exprNode()            {}
+func (*InterpolatedString) exprNode() {}
+func (*ListLit) exprNode()            {}
+func (*SetLit) exprNode()             {}
+func (*MapLit) exprNode()             {}
+func (*TupleLit) exprNode()           {}
+func (*ParenExpr) exprNode()          {}
+func (*SelectorExpr) exprNode()       {}
+func (*IndexExpr) exprNode()          {}
+func (*SliceExpr) exprNode()          {}
+func (*TypeAssertExpr) exprNode()     {}
+func (*CallExpr) exprNode()           {}
+func (*KeywordArg) exprNode()         {}
+func (*KeyValueExpr) exprNode()       {}
+func (*UnaryExpr) exprNode()          {}
+func (*BinaryExpr) exprNode()         {}
+func (*FuncType) exprNode()           {}
+func (*ListType) exprNode()           {}
+func (*SetType) exprNode()            {}
+func (*MapType) exprNode()            {}
+func (*TupleType) exprNode()          {}
 
 // -----------------------------------------------------------------------------
 // Convenience functions for Idents
@@ -151,7 +552,7 @@ type BadStmt struct {
 
 // A DeclStmt node represents a declaration in a statement list.
 type DeclStmt struct {
-	Decl Decl // *GenDecl with CONST, TYPE, or VAR token
+	Decl Decl // *GenDecl with CONST, LET, or VAR token, or *FuncDecl
 }
 
 // An EmptyStmt node represents an empty statement.
@@ -175,23 +576,110 @@ type IncDecStmt struct {
 	Tok    token.Token // INC or DEC
 }
 
-// An AssignStmt node represents an assignment or
-// a short variable declaration.
+// An AssignStmt node represents an assignment. Identifiers on the
+// left side of an ASSIGN that do not denote an existing object are
+// implicitly declared as variables.
 type AssignStmt struct {
 	Lhs    []Expr
 	TokPos token.Pos   // position of Tok
-	Tok    token.Token // assignment token, DEFINE
+	Tok    token.Token // ASSIGN or compound assignment token
 	Rhs    []Expr
 }
 
+// A ReturnStmt node represents a return statement.
+type ReturnStmt struct {
+	Return  token.Pos // position of "return" keyword
+	Results []Expr    // result expressions; or nil
+}
+
+// A BranchStmt node represents a break, continue or fallthrough statement.
+type BranchStmt struct {
+	TokPos token.Pos   // position of Tok
+	Tok    token.Token // keyword token (BREAK, CONTINUE, FALLTHROUGH)
+}
+
+// A BlockStmt node represents a braced statement list.
+type BlockStmt struct {
+	Lbrace token.Pos // position of "{"
+	List   []Stmt
+	Rbrace token.Pos // position of "}"
+}
+
+// An IfStmt node represents an if statement.
+type IfStmt struct {
+	If   token.Pos // position of "if" keyword
+	Init Stmt      // initialization statement; or nil
+	Cond Expr      // condition
+	Body *BlockStmt
+	Else Stmt // else branch; or nil
+}
+
+// A CaseClause represents a case of an expression or type switch statement.
+type CaseClause struct {
+	Case  token.Pos // position of "case" or "default" keyword
+	List  []Expr    // list of expressions or types; nil means default case
+	Colon token.Pos // position of ":"
+	Body  []Stmt    // statement list; or nil
+}
+
+// A SwitchStmt node represents an expression switch statement.
+type SwitchStmt struct {
+	Switch token.Pos  // position of "switch" keyword
+	Init   Stmt       // initialization statement; or nil
+	Tag    Expr       // tag expression; or nil
+	Body   *BlockStmt // CaseClauses only
+}
+
+// A TypeSwitchStmt node represents a type switch statement. In
+// switch v = typeof x, v is declared anew in every case clause.
+type TypeSwitchStmt struct {
+	Switch token.Pos  // position of "switch" keyword
+	Init   Stmt       // initialization statement; or nil
+	Lhs    *Ident     // variable bound in each case clause; or nil
+	TypeOf token.Pos  // position of "typeof" keyword
+	X      Expr       // expression whose type is switched on
+	Body   *BlockStmt // CaseClauses only
+}
+
+// A ForStmt represents a for statement.
+type ForStmt struct {
+	For  token.Pos // position of "for" keyword
+	Init Stmt      // initialization statement; or nil
+	Cond Expr      // condition; or nil
+	Post Stmt      // post iteration statement; or nil
+	Body *BlockStmt
+	Else *BlockStmt // else branch; or nil
+}
+
+// A ForInStmt represents a for statement with an in clause.
+type ForInStmt struct {
+	For    token.Pos // position of "for" keyword
+	Vars   []*Ident  // iteration variables
+	In     token.Pos // position of "in" keyword
+	X      Expr      // value to iterate over
+	If     token.Pos // position of "if" keyword, if any
+	Filter Expr      // filter condition; or nil
+	Body   *BlockStmt
+	Else   *BlockStmt // else branch; or nil
+}
+
 // Pos and End implementations for statement nodes.
 
-func (s *BadStmt) Pos() token.Pos    { return s.From }
-func (s *DeclStmt) Pos() token.Pos   { return s.Decl.Pos() }
-func (s *EmptyStmt) Pos() token.Pos  { return s.Semicolon }
-func (s *ExprStmt) Pos() token.Pos   { return s.Expr.Pos() }
-func (s *IncDecStmt) Pos() token.Pos { return s.Expr.Pos() }
-func (s *AssignStmt) Pos() token.Pos { return s.Lhs[0].Pos() }
+func (s *BadStmt) Pos() token.Pos        { return s.From }
+func (s *DeclStmt) Pos() token.Pos       { return s.Decl.Pos() }
+func (s *EmptyStmt) Pos() token.Pos      { return s.Semicolon }
+func (s *ExprStmt) Pos() token.Pos       { return s.Expr.Pos() }
+func (s *IncDecStmt) Pos() token.Pos     { return s.Expr.Pos() }
+func (s *AssignStmt) Pos() token.Pos     { return s.Lhs[0].Pos() }
+func (s *ReturnStmt) Pos() token.Pos     { return s.Return }
+func (s *BranchStmt) Pos() token.Pos     { return s.TokPos }
+func (s *BlockStmt) Pos() token.Pos      { return s.Lbrace }
+func (s *IfStmt) Pos() token.Pos         { return s.If }
+func (s *CaseClause) Pos() token.Pos     { return s.Case }
+func (s *SwitchStmt) Pos() token.Pos     { return s.Switch }
+func (s *TypeSwitchStmt) Pos() token.Pos { return s.Switch }
+func (s *ForStmt) Pos() token.Pos        { return s.For }
+func (s *ForInStmt) Pos() token.Pos      { return s.For }
 
 func (s *BadStmt) End() token.Pos  { return s.To }
 func (s *DeclStmt) End() token.Pos { return s.Decl.End() }
@@ -206,15 +694,60 @@ func (s *IncDecStmt) End() token.Pos {
 	return s.TokPos + 2 /* len("++") */
 }
 func (s *AssignStmt) End() token.Pos { return s.Rhs[len(s.Rhs)-1].End() }
+func (s *ReturnStmt) End() token.Pos {
+	if n := len(s.Results); n > 0 {
+		return s.Results[n-1].End()
+	}
+	return s.Return + 6 // len("return")
+}
+func (s *BranchStmt) End() token.Pos {
+	return token.Pos(int(s.TokPos) + len(s.Tok.String()))
+}
+func (s *BlockStmt) End() token.Pos { return s.Rbrace + 1 }
+func (s *IfStmt) End() token.Pos {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Body.End()
+}
+func (s *CaseClause) End() token.Pos {
+	if n := len(s.Body); n > 0 {
+		return s.Body[n-1].End()
+	}
+	return s.Colon + 1
+}
+func (s *SwitchStmt) End() token.Pos     { return s.Body.End() }
+func (s *TypeSwitchStmt) End() token.Pos { return s.Body.End() }
+func (s *ForStmt) End() token.Pos {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Body.End()
+}
+func (s *ForInStmt) End() token.Pos {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Body.End()
+}
 
 // stmtNode() ensures that only statement nodes can be
 // assigned to a Stmt.
-func (*BadStmt) stmtNode()    {}
-func (*DeclStmt) stmtNode()   {}
-func (*EmptyStmt) stmtNode()  {}
-func (*ExprStmt) stmtNode()   {}
-func (*IncDecStmt) stmtNode() {}
-func (*AssignStmt) stmtNode() {}
+func (*BadStmt) stmtNode()        {}
+func (*DeclStmt) stmtNode()       {}
+func (*EmptyStmt) stmtNode()      {}
+func (*ExprStmt) stmtNode()       {}
+func (*IncDecStmt) stmtNode()     {}
+func (*AssignStmt) stmtNode()     {}
+func (*ReturnStmt) stmtNode()     {}
+func (*BranchStmt) stmtNode()     {}
+func (*BlockStmt) stmtNode()      {}
+func (*IfStmt) stmtNode()         {}
+func (*CaseClause) stmtNode()     {}
+func (*SwitchStmt) stmtNode()     {}
+func (*TypeSwitchStmt) stmtNode() {}
+func (*ForStmt) stmtNode()        {}
+func (*ForInStmt) stmtNode()      {}
 
 // -----------------------------------------------------------------------------
 // Declarations
@@ -231,11 +764,11 @@ type Spec interface {
 // A ValueSpec node represents a constant or variable declaration
 // (ConstSpec or VarSpec production).
 type ValueSpec struct {
-	//Doc     *CommentGroup // associated documentation; or nil
-	Names  []*Ident // value names (len(Names) > 0)
-	Type   Expr     // value type; or nil
-	Values []Expr   // initial values; or nil
-	//Comment *CommentGroup // line comments; or nil
+	Doc     *CommentGroup // associated documentation; or nil
+	Names   []*Ident      // value names (len(Names) > 0)
+	Type    Expr          // value type; or nil
+	Values  []Expr        // initial values; or nil
+	Comment *CommentGroup // line comments; or nil
 }
 
 // Pos and End implementations for spec nodes.
@@ -275,18 +808,27 @@ type BadDecl struct {
 //	token.TYPE    *TypeSpec
 //	token.VAR     *ValueSpec
 type GenDecl struct {
-	//Doc    *CommentGroup // associated documentation; or nil
-	TokPos token.Pos   // position of Tok
-	Tok    token.Token // IMPORT, CONST, TYPE, VAR
-	Lparen token.Pos   // position of '(', if any
+	Doc    *CommentGroup // associated documentation; or nil
+	TokPos token.Pos     // position of Tok
+	Tok    token.Token   // IMPORT, CONST, TYPE, VAR
+	Lparen token.Pos     // position of '(', if any
 	Specs  []Spec
 	Rparen token.Pos // position of ')', if any
 }
 
+// A FuncDecl node represents a function declaration.
+type FuncDecl struct {
+	Doc  *CommentGroup // associated documentation; or nil
+	Name *Ident        // function name
+	Type *FuncType     // function signature: parameters and results
+	Body *BlockStmt    // function body
+}
+
 // Pos and End implementations for declaration nodes.
 
-func (d *BadDecl) Pos() token.Pos { return d.From }
-func (d *GenDecl) Pos() token.Pos { return d.TokPos }
+func (d *BadDecl) Pos() token.Pos  { return d.From }
+func (d *GenDecl) Pos() token.Pos  { return d.TokPos }
+func (d *FuncDecl) Pos() token.Pos { return d.Type.Pos() }
 
 func (d *BadDecl) End() token.Pos { return d.To }
 func (d *GenDecl) End() token.Pos {
@@ -295,11 +837,13 @@ func (d *GenDecl) End() token.Pos {
 	}
 	return d.Specs[0].End()
 }
+func (d *FuncDecl) End() token.Pos { return d.Body.End() }
 
 // declNode() ensures that only declaration nodes can be
 // assigned to a Decl.
-func (*BadDecl) declNode() {}
-func (*GenDecl) declNode() {}
+func (*BadDecl) declNode()  {}
+func (*GenDecl) declNode()  {}
+func (*FuncDecl) declNode() {}
 
 // ----------------------------------------------------------------------------
 // Files and packages
@@ -311,7 +855,8 @@ type File struct {
 	Stmts   []Stmt    // top-level statements; or nil
 	Scope   *Scope    // package scope (this file only)
 	//Imports    []*ImportSpec   // imports in this file
-	Unresolved []*Ident // unresolved identifiers in this file
+	Unresolved []*Ident        // unresolved identifiers in this file
+	Comments   []*CommentGroup // list of all comments in the source file
 }
 
 func (f *File) Pos() token.Pos { return f.Package }
@@ -7,6 +7,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"go/constant"
 
 	"github.com/capnspacehook/rose/token"
 )
@@ -69,13 +70,21 @@ func (s *Scope) String() string {
 //	Kind    Data type         Data value
 //	Pkg     *Scope            package scope
 //	Con     int               iota for the respective declaration
+//	Let     int               index of the spec in the respective declaration
+//
+// The Value of a constant whose value cannot be represented by a
+// constant.Value, such as a container, or could not be computed
+// is of kind constant.Unknown.
+//
 // TODO: (capnspacehook) remove interface{} fields?
 type Object struct {
 	Kind ObjKind
 	Name string      // declared name
-	Decl interface{} // corresponding Field, XxxSpec, FuncDecl, LabeledStmt, AssignStmt, Scope; or nil
+	Decl interface{} // corresponding Field, XxxSpec, FuncDecl, AssignStmt, ForInStmt, TypeSwitchStmt, Scope; or nil
 	Data interface{} // object-specific data; or nil
 	Type interface{} // placeholder for type information; may be nil
+
+	Value constant.Value // value of a constant declared with const; or nil
 }
 
 // NewObj creates a new object of a given kind and name.
@@ -89,13 +98,13 @@ func NewObj(kind ObjKind, name string) *Object {
 func (obj *Object) Pos() token.Pos {
 	name := obj.Name
 	switch d := obj.Decl.(type) {
-	/*case *Field:
+	case *Field:
 		for _, n := range d.Names {
 			if n.Name == name {
 				return n.Pos()
 			}
 		}
-	case *ImportSpec:
+	/*case *ImportSpec:
 		if d.Name != nil && d.Name.Name == name {
 			return d.Name.Pos()
 		}
@@ -109,7 +118,7 @@ func (obj *Object) Pos() token.Pos {
 	/*case *TypeSpec:
 		if d.Name.Name == name {
 			return d.Name.Pos()
-		}
+		}*/
 	case *FuncDecl:
 		if d.Name.Name == name {
 			return d.Name.Pos()
@@ -119,7 +128,17 @@ func (obj *Object) Pos() token.Pos {
 			if ident, isIdent := x.(*Ident); isIdent && ident.Name == name {
 				return ident.Pos()
 			}
-		}*/
+		}
+	case *ForInStmt:
+		for _, n := range d.Vars {
+			if n.Name == name {
+				return n.Pos()
+			}
+		}
+	case *TypeSwitchStmt:
+		if d.Lhs != nil && d.Lhs.Name == name {
+			return d.Lhs.Pos()
+		}
 	case *Scope:
 		// predeclared object - nothing to do for now
 	}
@@ -134,6 +153,7 @@ const (
 	Bad ObjKind = iota // for error handling
 	//Pkg                // package
 	Con // constant
+	Let // runtime constant
 	Typ // type
 	Var // variable
 	Fun // function or method
@@ -143,6 +163,7 @@ var objKindStrings = [...]string{
 	Bad: "bad",
 	//Pkg: "package",
 	Con: "const",
+	Let: "let",
 	Typ: "type",
 	Var: "var",
 	Fun: "func",
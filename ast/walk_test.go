@@ -0,0 +1,320 @@
+package ast_test
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nodes holds a value of every node type declared in package ast.
+var nodes = []ast.Node{
+	&ast.Comment{},
+	&ast.CommentGroup{},
+	&ast.Field{},
+	&ast.FieldList{},
+	&ast.BadExpr{},
+	&ast.Ident{},
+	&ast.BasicLit{},
+	&ast.FuncLit{},
+	&ast.InterpolatedString{},
+	&ast.ListLit{},
+	&ast.SetLit{},
+	&ast.MapLit{},
+	&ast.TupleLit{},
+	&ast.ParenExpr{},
+	&ast.SelectorExpr{},
+	&ast.IndexExpr{},
+	&ast.SliceExpr{},
+	&ast.TypeAssertExpr{},
+	&ast.CallExpr{},
+	&ast.KeywordArg{},
+	&ast.KeyValueExpr{},
+	&ast.UnaryExpr{},
+	&ast.BinaryExpr{},
+	&ast.FuncType{},
+	&ast.ListType{},
+	&ast.SetType{},
+	&ast.MapType{},
+	&ast.TupleType{},
+	&ast.BadStmt{},
+	&ast.DeclStmt{},
+	&ast.EmptyStmt{},
+	&ast.ExprStmt{},
+	&ast.IncDecStmt{},
+	&ast.AssignStmt{},
+	&ast.ReturnStmt{},
+	&ast.BranchStmt{},
+	&ast.BlockStmt{},
+	&ast.IfStmt{},
+	&ast.CaseClause{},
+	&ast.SwitchStmt{},
+	&ast.TypeSwitchStmt{},
+	&ast.ForStmt{},
+	&ast.ForInStmt{},
+	&ast.ValueSpec{},
+	&ast.BadDecl{},
+	&ast.GenDecl{},
+	&ast.FuncDecl{},
+	&ast.File{},
+}
+
+// TestNodesComplete checks that nodes contains every type of package
+// ast that has an End method, so that new node types are covered by
+// TestWalkChildren.
+func TestNodesComplete(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	var declared []string
+	fset := gotoken.NewFileSet()
+	for _, filename := range files {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		f, err := goparser.ParseFile(fset, filename, nil, 0)
+		require.NoError(t, err)
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*goast.FuncDecl); ok && fn.Recv != nil && fn.Name.Name == "End" {
+				if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+					declared = append(declared, star.X.(*goast.Ident).Name)
+				}
+			}
+		}
+	}
+
+	var covered []string
+	for _, n := range nodes {
+		covered = append(covered, reflect.TypeOf(n).Elem().Name())
+	}
+
+	sort.Strings(declared)
+	sort.Strings(covered)
+	require.Equal(t, declared, covered)
+}
+
+var (
+	nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+	// leaves maps the node interface types to a function
+	// returning a new node implementing the interface
+	leaves = map[reflect.Type]func() ast.Node{
+		nodeType:                                func() ast.Node { return &ast.BadExpr{} },
+		reflect.TypeOf((*ast.Expr)(nil)).Elem(): func() ast.Node { return &ast.BadExpr{} },
+		reflect.TypeOf((*ast.Stmt)(nil)).Elem(): func() ast.Node { return &ast.BadStmt{} },
+		reflect.TypeOf((*ast.Decl)(nil)).Elem(): func() ast.Node { return &ast.BadDecl{} },
+		reflect.TypeOf((*ast.Spec)(nil)).Elem(): func() ast.Node { return &ast.ValueSpec{} },
+	}
+)
+
+// newChild returns a new node of type typ, or nil if typ is not a
+// node type.
+func newChild(typ reflect.Type) ast.Node {
+	if leaf, ok := leaves[typ]; ok {
+		return leaf()
+	}
+	if typ.Kind() == reflect.Ptr && typ.Implements(nodeType) {
+		return reflect.New(typ.Elem()).Interface().(ast.Node)
+	}
+	return nil
+}
+
+// populate sets every field of node that refers to child nodes
+// to new nodes, and returns the children in field order.
+func populate(node ast.Node) []ast.Node {
+	var children []ast.Node
+	v := reflect.ValueOf(node).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if _, isFile := node.(*ast.File); isFile {
+			switch v.Type().Field(i).Name {
+			case "Comments", "Unresolved":
+				// not walked; their nodes appear elsewhere in the tree
+				continue
+			}
+		}
+
+		switch f.Kind() {
+		case reflect.Slice:
+			if child := newChild(f.Type().Elem()); child != nil {
+				s := reflect.MakeSlice(f.Type(), 2, 2)
+				for j := 0; j < 2; j++ {
+					if j > 0 {
+						child = newChild(f.Type().Elem())
+					}
+					s.Index(j).Set(reflect.ValueOf(child))
+					children = append(children, child)
+				}
+				f.Set(s)
+			}
+		case reflect.Ptr, reflect.Interface:
+			if child := newChild(f.Type()); child != nil {
+				f.Set(reflect.ValueOf(child))
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// TestWalkChildren checks that Walk, Inspect and Apply visit all
+// children of every node type in order.
+func TestWalkChildren(t *testing.T) {
+	for _, n := range nodes {
+		name := reflect.TypeOf(n).String()
+		expected := populate(n)
+
+		var walked []ast.Node
+		ast.Inspect(n, func(x ast.Node) bool {
+			if x == nil || x == n {
+				return x == n
+			}
+			walked = append(walked, x)
+			return false
+		})
+		require.Equal(t, expected, walked, name)
+
+		var applied []ast.Node
+		ast.Apply(n, func(c *ast.Cursor) bool {
+			if c.Node() == nil || c.Node() == n {
+				return c.Node() == n
+			}
+			applied = append(applied, c.Node())
+			return false
+		}, nil)
+		require.Equal(t, expected, applied, name)
+	}
+}
+
+type counter map[string]int
+
+func (c counter) Visit(n ast.Node) ast.Visitor {
+	if n != nil {
+		c[reflect.TypeOf(n).Elem().Name()]++
+	}
+	return c
+}
+
+func parse(t *testing.T, input string) *ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "", input, parser.ParseComments)
+	require.NoError(t, err)
+	return f
+}
+
+func TestWalk(t *testing.T) {
+	f := parse(t, `// doc
+fn f(a int, b = 2) int {
+	return a + b
+}
+x = [f(1), f(2, b=3)]
+for v in x if v > 2 {
+	x[0]++
+}
+`)
+
+	c := make(counter)
+	ast.Walk(c, f)
+	require.Equal(t, counter{
+		"File":         1,
+		"CommentGroup": 1,
+		"Comment":      1,
+		"DeclStmt":     1,
+		"FuncDecl":     1,
+		"FuncType":     1,
+		"FieldList":    2,
+		"Field":        3,
+		"Ident":        15,
+		"BasicLit":     6,
+		"BlockStmt":    2,
+		"ReturnStmt":   1,
+		"BinaryExpr":   2,
+		"AssignStmt":   1,
+		"ListLit":      1,
+		"CallExpr":     2,
+		"KeywordArg":   1,
+		"ForInStmt":    1,
+		"IncDecStmt":   1,
+		"IndexExpr":    1,
+	}, c)
+
+	// Inspect stops descending when f returns false
+	var idents []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			return false
+		case *ast.Ident:
+			idents = append(idents, n.Name)
+		}
+		return true
+	})
+	require.Equal(t, []string{"x", "f", "f", "b", "v", "x", "v", "x"}, idents)
+}
+
+func TestApply(t *testing.T) {
+	f := parse(t, `a = 1
+b = a
+c = a + b
+`)
+
+	ast.Apply(f, func(c *ast.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.AssignStmt:
+			switch n.Lhs[0].(*ast.Ident).Name {
+			case "a":
+				c.InsertBefore(&ast.ExprStmt{Expr: &ast.Ident{Name: "before"}})
+				c.InsertAfter(&ast.ExprStmt{Expr: &ast.Ident{Name: "after"}})
+			case "b":
+				c.Delete()
+				return false
+			}
+		case *ast.Ident:
+			if n.Name == "a" {
+				c.Replace(&ast.Ident{Name: "z"})
+			}
+		}
+		return true
+	}, nil)
+
+	var stmts []string
+	for _, s := range f.Stmts {
+		switch s := s.(type) {
+		case *ast.ExprStmt:
+			stmts = append(stmts, s.Expr.(*ast.Ident).Name)
+		case *ast.AssignStmt:
+			stmts = append(stmts, s.Lhs[0].(*ast.Ident).Name+" = "+types(s.Rhs[0]))
+		}
+	}
+	require.Equal(t, []string{"before", "z = *ast.BasicLit", "after", "c = *ast.BinaryExpr"}, stmts)
+	bin := f.Stmts[3].(*ast.AssignStmt).Rhs[0].(*ast.BinaryExpr)
+	require.Equal(t, "z", bin.Lhs.(*ast.Ident).Name)
+
+	// post returning false terminates the traversal
+	var visited int
+	ast.Apply(f, nil, func(c *ast.Cursor) bool {
+		visited++
+		return c.Name() != "Lhs"
+	})
+	require.Equal(t, 4, visited) // File.Name, before, its ExprStmt and z
+
+	// the root itself can be replaced
+	root := ast.Apply(f, func(c *ast.Cursor) bool {
+		c.Replace(&ast.BadStmt{})
+		return false
+	}, nil)
+	require.IsType(t, &ast.BadStmt{}, root)
+}
+
+func types(x ast.Expr) string {
+	return reflect.TypeOf(x).String()
+}
@@ -5,9 +5,10 @@ import (
 	"os"
 
 	"github.com/capnspacehook/rose/repl"
+	"github.com/capnspacehook/rose/token"
 )
 
 func main() {
 	fmt.Println("Rose REPL")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(token.NewFileSet(), os.Stdin, os.Stdout)
 }
@@ -0,0 +1,231 @@
+// Rose runs, checks and inspects Rose programs.
+//
+// Usage:
+//
+//	rose <command> [arguments]
+//
+// The commands are:
+//
+//	run     run a Rose program
+//	repl    start an interactive session
+//	check   parse and type check Rose files, printing diagnostics
+//	tokens  print the tokens of Rose files
+//	ast     print the syntax trees of Rose files
+//
+// All commands share a single token.FileSet, so diagnostics and
+// positions refer to the files by the names given on the command line.
+// Rose exits with status 1 if diagnostics were reported and with
+// status 2 if it was invoked incorrectly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/eval"
+	"github.com/capnspacehook/rose/lexer"
+	"github.com/capnspacehook/rose/object"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/repl"
+	"github.com/capnspacehook/rose/token"
+	"github.com/capnspacehook/rose/types"
+)
+
+// A command is a subcommand of rose.
+type command struct {
+	name  string
+	args  string // the arguments, for the usage message
+	short string // short description
+	run   func(args []string)
+}
+
+var commands []*command
+
+func init() {
+	// initialized here, as the commands refer to usage
+	commands = []*command{
+		{"run", "file.rose", "run a Rose program", runRun},
+		{"repl", "", "start an interactive session", runRepl},
+		{"check", "file.rose...", "parse and type check Rose files, printing diagnostics", runCheck},
+		{"tokens", "file.rose...", "print the tokens of Rose files", runTokens},
+		{"ast", "file.rose...", "print the syntax trees of Rose files", runAST},
+	}
+}
+
+var (
+	fset     = token.NewFileSet() // shared by all files
+	exitCode = 0
+
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// report prints err and sets the exit code. If err holds diagnostics,
+// src is the source they refer to, or nil.
+func report(src []byte, err error) {
+	diagnostics.Fprint(stderr, fset, src, err)
+	exitCode = 1
+}
+
+func usage() {
+	fmt.Fprintf(stderr, "Rose runs, checks and inspects Rose programs.\n\n")
+	fmt.Fprintf(stderr, "usage: rose <command> [arguments]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(stderr, "\t%-7s %s\n", cmd.name, cmd.short)
+	}
+	exitCode = 2
+}
+
+func commandUsage(cmd *command) {
+	fmt.Fprintf(stderr, "usage: rose %s %s\n", cmd.name, cmd.args)
+	exitCode = 2
+}
+
+func main() {
+	roseMain(os.Args[1:])
+	os.Exit(exitCode)
+}
+
+func roseMain(args []string) {
+	flags := flag.NewFlagSet("rose", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = usage
+	if err := flags.Parse(args); err != nil {
+		exitCode = 2
+		return
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		usage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(stderr, "rose %s: unknown command\n", args[0])
+	usage()
+}
+
+// parseFile parses the file filename and reports any errors. It
+// returns the syntax tree and source of the file, or a nil tree if
+// the file could not be read or parsed.
+func parseFile(filename string, mode parser.Mode) (*ast.File, []byte) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		report(nil, err)
+		return nil, nil
+	}
+	f, err := parser.ParseFile(fset, filename, src, mode)
+	if err != nil {
+		report(src, err)
+		return nil, src
+	}
+	return f, src
+}
+
+func runRun(args []string) {
+	if len(args) != 1 {
+		commandUsage(commands[0])
+		return
+	}
+
+	f, src := parseFile(args[0], parser.DeclarationErrors)
+	if f == nil {
+		return
+	}
+	if err := types.Check(fset, f, nil); err != nil {
+		report(src, err)
+		return
+	}
+	env := object.NewEnvironment()
+	env.SetOutput(stdout)
+	if _, err := eval.Eval(f, env); err != nil {
+		if e, ok := err.(*eval.Error); ok {
+			err = e.Diagnostic(fset)
+		}
+		report(src, err)
+	}
+}
+
+func runRepl(args []string) {
+	if len(args) != 0 {
+		commandUsage(commands[1])
+		return
+	}
+
+	fmt.Fprintln(stdout, "Rose REPL")
+	repl.Start(fset, stdin, stdout)
+}
+
+func runCheck(args []string) {
+	if len(args) == 0 {
+		commandUsage(commands[2])
+		return
+	}
+
+	for _, filename := range args {
+		f, src := parseFile(filename, parser.DeclarationErrors|parser.AllErrors)
+		if f == nil {
+			continue
+		}
+		if err := types.Check(fset, f, nil); err != nil {
+			report(src, err)
+		}
+	}
+}
+
+func runTokens(args []string) {
+	if len(args) == 0 {
+		commandUsage(commands[3])
+		return
+	}
+
+	for _, filename := range args {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			report(nil, err)
+			continue
+		}
+
+		var errors diagnostics.List
+		var lx lexer.Lexer
+		lx.Init(fset.AddFile(filename, -1, len(src)), src, errors.Add, true)
+		for {
+			pos, tok, lit := lx.Lex()
+			if tok == token.EOF {
+				break
+			}
+			fmt.Fprintf(stdout, "%s\t%s\t%q\n", fset.Position(pos), tok, lit)
+		}
+		if err := errors.Err(); err != nil {
+			report(src, err)
+		}
+	}
+}
+
+func runAST(args []string) {
+	if len(args) == 0 {
+		commandUsage(commands[4])
+		return
+	}
+
+	for _, filename := range args {
+		f, _ := parseFile(filename, parser.ParseComments|parser.DeclarationErrors)
+		if f == nil {
+			continue
+		}
+		if err := ast.Fprint(stdout, fset, f, ast.NotNilFilter); err != nil {
+			report(nil, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rose runs the rose command with args and returns its standard
+// output, standard error and exit code.
+func rose(t *testing.T, input string, args ...string) (string, string, int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	stdin, stdout, stderr = strings.NewReader(input), &out, &errOut
+	exitCode = 0
+	defer func() {
+		stdin, stdout, stderr = os.Stdin, os.Stdout, os.Stderr
+		exitCode = 0
+	}()
+
+	roseMain(args)
+	return out.String(), errOut.String(), exitCode
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "rose")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, src := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0600))
+	}
+	return dir
+}
+
+func TestUsage(t *testing.T) {
+	_, errOut, code := rose(t, "")
+	require.Equal(t, 2, code)
+	require.Contains(t, errOut, "usage: rose <command> [arguments]")
+
+	_, errOut, code = rose(t, "", "build")
+	require.Equal(t, 2, code)
+	require.Contains(t, errOut, "rose build: unknown command")
+
+	_, errOut, code = rose(t, "", "run")
+	require.Equal(t, 2, code)
+	require.Equal(t, "usage: rose run file.rose\n", errOut)
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.rose":  "x = 1\ny = x + 2\n",
+		"fib.rose": "fn fib(n int) int {\n\tif n < 2 {\n\t\treturn n\n\t}\n\treturn fib(n-1) + fib(n-2)\n}\n\nfor i in range(1, 6) {\n\tprint(i, fib(i))\n}\n",
+		"div.rose": "x = 0\ny = 1 / x\n",
+		"bad.rose": "x = \"a\" + 1\n",
+	})
+
+	out, errOut, code := rose(t, "", "run", filepath.Join(dir, "ok.rose"))
+	require.Equal(t, 0, code, errOut)
+	require.Empty(t, out)
+
+	out, errOut, code = rose(t, "", "run", filepath.Join(dir, "fib.rose"))
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, "1 1\n2 1\n3 2\n4 3\n5 5\n", out)
+
+	filename := filepath.Join(dir, "div.rose")
+	_, errOut, code = rose(t, "", "run", filename)
+	require.Equal(t, 1, code)
+	require.True(t, strings.HasPrefix(errOut, filename+":2:7: error: integer divide by zero"), errOut)
+
+	// programs are type checked before they are run
+	filename = filepath.Join(dir, "bad.rose")
+	_, errOut, code = rose(t, "", "run", filename)
+	require.Equal(t, 1, code)
+	require.True(t, strings.HasPrefix(errOut, filename+":1:"), errOut)
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.rose": "let x int = 1\n",
+		"b.rose": "x = (\n",
+		"c.rose": "let y string = 2\n",
+	})
+
+	_, errOut, code := rose(t, "", "check", filepath.Join(dir, "a.rose"))
+	require.Equal(t, 0, code)
+	require.Empty(t, errOut)
+
+	_, errOut, code = rose(t, "", "check", filepath.Join(dir, "a.rose"), filepath.Join(dir, "b.rose"), filepath.Join(dir, "c.rose"))
+	require.Equal(t, 1, code)
+	require.Contains(t, errOut, filepath.Join(dir, "b.rose")+":1:")
+	require.Contains(t, errOut, filepath.Join(dir, "c.rose")+":1:")
+	require.NotContains(t, errOut, "<input>")
+
+	_, errOut, code = rose(t, "", "check", filepath.Join(dir, "missing.rose"))
+	require.Equal(t, 1, code)
+	require.NotEmpty(t, errOut)
+}
+
+func TestTokens(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.rose": "x = 1 // one\n"})
+	filename := filepath.Join(dir, "a.rose")
+
+	out, errOut, code := rose(t, "", "tokens", filename)
+	require.Equal(t, 0, code, errOut)
+	require.Equal(t, strings.Join([]string{
+		filename + ":1:1\tIDENT\t\"x\"",
+		filename + ":1:3\t=\t\"\"",
+		filename + ":1:5\tINT\t\"1\"",
+		filename + ":1:7\t;\t\"\\n\"",
+		filename + ":1:7\tCOMMENT\t\"// one\"",
+		"",
+	}, "\n"), out)
+}
+
+func TestAST(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.rose": "x = 1\n"})
+	filename := filepath.Join(dir, "a.rose")
+
+	out, errOut, code := rose(t, "", "ast", filename)
+	require.Equal(t, 0, code, errOut)
+	require.True(t, strings.HasPrefix(out, "     0  *ast.File {\n"), out)
+	require.Contains(t, out, "NamePos: "+filename+":1:1\n")
+}
+
+func TestRepl(t *testing.T) {
+	out, _, code := rose(t, "x=1\nx + 1\ny = (\n", "repl")
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(out, "Rose REPL\n>> >> 2\n>> .. <repl>:1:6: error[E0001]:"), out)
+}
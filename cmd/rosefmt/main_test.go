@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformatted = "const (\na = 1\nbbb = 2\n)\nx=a*b+c\n"
+	formatted   = "const (\n\ta   = 1\n\tbbb = 2\n)\nx = a*b + c\n"
+)
+
+func setFlags(t *testing.T, l, w, d bool) {
+	*list, *write, *doDiff = l, w, d
+	t.Cleanup(func() {
+		*list, *write, *doDiff = false, false, false
+	})
+}
+
+func TestProcessFile(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, processFile("a.rose", strings.NewReader(unformatted), &out))
+	require.Equal(t, formatted, out.String())
+
+	setFlags(t, true, false, false)
+	out.Reset()
+	require.NoError(t, processFile("a.rose", strings.NewReader(unformatted), &out))
+	require.Equal(t, "a.rose\n", out.String())
+	out.Reset()
+	require.NoError(t, processFile("b.rose", strings.NewReader(formatted), &out))
+	require.Empty(t, out.String())
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rosefmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.rose")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(unformatted), 0600))
+
+	setFlags(t, false, true, false)
+	var out bytes.Buffer
+	require.NoError(t, processFile(filename, nil, &out))
+	require.Empty(t, out.String())
+	src, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, formatted, string(src))
+}
+
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not found")
+	}
+
+	setFlags(t, false, false, true)
+	var out bytes.Buffer
+	require.NoError(t, processFile("a.rose", strings.NewReader(unformatted), &out))
+	lines := strings.Split(out.String(), "\n")
+	require.Equal(t, "diff -u a.rose.orig a.rose", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "--- a.rose.orig"), lines[1])
+	require.True(t, strings.HasPrefix(lines[2], "+++ a.rose"), lines[2])
+	require.Contains(t, out.String(), "-x=a*b+c\n+x = a*b + c\n")
+}
+
+func TestParseError(t *testing.T) {
+	defer func() { exitCode = 0 }()
+
+	var out bytes.Buffer
+	require.NoError(t, processFile("bad.rose", strings.NewReader("x = (\n"), &out))
+	require.Empty(t, out.String())
+	require.Equal(t, 2, exitCode)
+}
@@ -0,0 +1,59 @@
+package diagnostics
+
+// Codes of the diagnostics reported by the lexer, the parser and the
+// type checker. A code identifies the kind of a problem independently
+// of the wording of its message.
+const (
+	// SyntaxError is reported for source text that does not
+	// conform to the grammar.
+	SyntaxError = "E0001"
+
+	// MisplacedBranch is reported for return, break, continue and
+	// fallthrough statements and typeof guards outside of the
+	// statements they may appear in.
+	MisplacedBranch = "E0002"
+
+	// Redeclared is reported for an identifier declared twice in
+	// the same block.
+	Redeclared = "E0003"
+
+	// DuplicateCase is reported for switch cases with equal
+	// constant values and for multiple default cases.
+	DuplicateCase = "E0004"
+
+	// InvalidConstant is reported for constant declarations whose
+	// values are not constant or cannot be represented.
+	InvalidConstant = "E0005"
+
+	// Undefined is reported for identifiers that are not declared.
+	Undefined = "E0006"
+
+	// TypeMismatch is reported for values used where a value of a
+	// different type is required.
+	TypeMismatch = "E0007"
+
+	// InvalidOperation is reported for operators, indexing, slicing,
+	// calls and iteration that are not defined on their operands.
+	InvalidOperation = "E0008"
+
+	// InvalidAssignment is reported for assignments to constants
+	// and immutable values and for mismatched assignment counts.
+	InvalidAssignment = "E0009"
+
+	// InvalidCall is reported for arguments that do not match the
+	// parameters of the called function.
+	InvalidCall = "E0010"
+
+	// InvalidIndex is reported for index expressions that are not
+	// integers or are out of bounds.
+	InvalidIndex = "E0011"
+
+	// InvalidReturn is reported for return statements with the
+	// wrong number of results.
+	InvalidReturn = "E0012"
+
+	// InvalidExpression is reported for expressions that are not
+	// values where values are expected, such as types, functions
+	// without results and the blank identifier.
+	InvalidExpression = "E0013"
+)
@@ -0,0 +1,186 @@
+// Package diagnostics defines the representation of problems found in
+// Rose source text by the lexer, the parser and the type checker, and
+// renders them for humans.
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+// A Severity describes how serious a diagnostic is.
+type Severity int
+
+const (
+	Error   Severity = iota // the source text is invalid
+	Warning                 // the source text is valid but likely wrong
+	Note                    // additional information
+)
+
+var severities = [...]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string {
+	if 0 <= s && int(s) < len(severities) {
+		return severities[s]
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// A Span describes the source text in the range [Pos, End). If End
+// is not valid, the span covers the character at Pos.
+type Span struct {
+	Pos, End token.Pos
+}
+
+// A Related describes a secondary span of a diagnostic, such as a
+// previous declaration of a redeclared identifier.
+type Related struct {
+	Pos  token.Position // position of the start of Span
+	Span Span
+	Msg  string
+}
+
+// A Fix describes a suggested edit that resolves a diagnostic:
+// the source text of Span is replaced by NewText.
+type Fix struct {
+	Msg     string
+	Span    Span
+	NewText string
+}
+
+// A Diagnostic describes a problem found in source text. The position
+// Pos, if valid, points to the beginning of the primary span, and the
+// problem is described by Msg.
+type Diagnostic struct {
+	Severity Severity
+	Code     string         // error code, such as "E0001"; or empty
+	Pos      token.Position // position of the start of Span
+	Span     Span           // primary span
+	Msg      string
+	Related  []Related // secondary spans, if any
+	Fixes    []Fix     // suggested fixes, if any
+}
+
+// position returns the string form of pos, using "<input>"
+// for positions without a file name.
+func position(pos token.Position) string {
+	s := pos.Filename
+	if s == "" {
+		s = "<input>"
+	}
+	if pos.IsValid() {
+		s += fmt.Sprintf(":%d:%d", pos.Line, pos.Column)
+	}
+	return s
+}
+
+// Error implements the error interface. Related spans are
+// described on separate, indented lines.
+func (d *Diagnostic) Error() string {
+	s := d.Msg
+	if d.Pos.Filename != "" || d.Pos.IsValid() {
+		// don't print "<unknown position>"
+		s = position(d.Pos) + ": " + s
+	}
+	for _, r := range d.Related {
+		s += fmt.Sprintf("\n\t%s at %s", r.Msg, r.Pos)
+	}
+	return s
+}
+
+// A List is a list of *Diagnostics.
+// The zero value for a List is an empty List ready to use.
+type List []*Diagnostic
+
+// Add adds an error with the given position and message to a List.
+func (l *List) Add(pos token.Position, msg string) {
+	*l = append(*l, &Diagnostic{Severity: Error, Pos: pos, Msg: msg})
+}
+
+// Report adds the diagnostic d to a List.
+func (l *List) Report(d *Diagnostic) {
+	*l = append(*l, d)
+}
+
+// Reset resets a List to no diagnostics.
+func (l *List) Reset() { *l = (*l)[0:0] }
+
+// List implements the sort Interface.
+func (l List) Len() int      { return len(l) }
+func (l List) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l List) Less(i, j int) bool {
+	e := &l[i].Pos
+	f := &l[j].Pos
+	if e.Filename != f.Filename {
+		return e.Filename < f.Filename
+	}
+	if e.Line != f.Line {
+		return e.Line < f.Line
+	}
+	if e.Column != f.Column {
+		return e.Column < f.Column
+	}
+	return l[i].Msg < l[j].Msg
+}
+
+// Sort sorts a List by position and, for equal
+// positions, by message.
+func (l List) Sort() {
+	sort.Sort(l)
+}
+
+// RemoveMultiples sorts a List and removes all but the first
+// diagnostic per line.
+func (l *List) RemoveMultiples() {
+	sort.Sort(l)
+	var last token.Position // initial last.Line is != any legal line
+	i := 0
+	for _, d := range *l {
+		if d.Pos.Filename != last.Filename || d.Pos.Line != last.Line {
+			last = d.Pos
+			(*l)[i] = d
+			i++
+		}
+	}
+	(*l) = (*l)[0:i]
+}
+
+// HasErrors reports whether a List contains a diagnostic
+// of severity Error.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// A List implements the error interface.
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and 1 more error)", l[0])
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this list. If
+// the list contains no errors, Err returns nil.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,138 @@
+package diagnostics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+const src = "a = 1\n\tb = a +\n\t\t2\nc = \"héllo\" + d\n"
+
+func testFile() (*token.FileSet, *token.File) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	file.SetLinesForContent([]byte(src))
+	return fset, file
+}
+
+// span returns the span of the n'th occurrence of text in src.
+func span(file *token.File, text string, n int) diagnostics.Span {
+	offs := -1
+	for ; n >= 0; n-- {
+		offs += 1 + strings.Index(src[offs+1:], text)
+	}
+	pos := file.Pos(offs)
+	return diagnostics.Span{Pos: pos, End: pos + token.Pos(len(text))}
+}
+
+func TestError(t *testing.T) {
+	_, file := testFile()
+	var list diagnostics.List
+	require.NoError(t, list.Err())
+
+	list.Report(&diagnostics.Diagnostic{
+		Severity: diagnostics.Warning,
+		Pos:      file.Position(span(file, "c", 0).Pos),
+		Msg:      "unused c",
+	})
+	require.NoError(t, list.Err())
+
+	a := span(file, "a", 1)
+	list.Report(&diagnostics.Diagnostic{
+		Pos:  file.Position(a.Pos),
+		Span: a,
+		Msg:  "a redeclared in this block",
+		Related: []diagnostics.Related{{
+			Pos:  file.Position(span(file, "a", 0).Pos),
+			Span: span(file, "a", 0),
+			Msg:  "previous declaration",
+		}},
+	})
+	list.Add(token.Position{}, "no position")
+	list.Sort()
+
+	require.EqualError(t, list.Err(), "no position (and 2 more errors)")
+	require.Equal(t, "<input>:2:6: a redeclared in this block\n\tprevious declaration at 1:1", list[1].Error())
+	require.Equal(t, "<input>:4:1: unused c", list[2].Error())
+}
+
+func TestFprint(t *testing.T) {
+	fset, file := testFile()
+	d := span(file, "d", 0)
+	list := diagnostics.List{
+		{
+			Code: "E0001",
+			Pos:  file.Position(d.Pos),
+			Span: d,
+			Msg:  "undefined: d",
+			Fixes: []diagnostics.Fix{{
+				Msg:     "did you mean a?",
+				Span:    d,
+				NewText: "a",
+			}},
+		},
+		{
+			Pos:  file.Position(span(file, "a +", 0).Pos),
+			Span: diagnostics.Span{Pos: span(file, "a +", 0).Pos, End: span(file, "2", 0).End},
+			Msg:  "mismatched types",
+			Related: []diagnostics.Related{{
+				Pos:  file.Position(span(file, `"héllo"`, 0).Pos),
+				Span: span(file, `"héllo"`, 0),
+				Msg:  "string value",
+			}},
+		},
+		{
+			Severity: diagnostics.Note,
+			Pos:      token.Position{Filename: "other.rose"},
+			Span:     diagnostics.Span{Pos: token.Pos(len(src) + 100)},
+			Msg:      "outside of file",
+		},
+	}
+
+	var b strings.Builder
+	diagnostics.Fprint(&b, fset, []byte(src), list)
+	require.Equal(t, `<input>:4:16: error[E0001]: undefined: d
+ 4 | c = "héllo" + d
+   |               ^
+<input>:4:16: help: did you mean a?
+ 4 | c = "héllo" + a
+   |               +
+<input>:2:6: error: mismatched types
+ 2 | 	b = a +
+   | 	    ^^^
+<input>:4:5: note: string value
+ 4 | c = "héllo" + d
+   |     -------
+other.rose: note: outside of file
+`, b.String())
+
+	b.Reset()
+	diagnostics.Fprint(&b, nil, nil, list[0])
+	require.Equal(t, "<input>:4:16: error[E0001]: undefined: d\n<input>:4:16: help: did you mean a?\n", b.String())
+
+	b.Reset()
+	diagnostics.Fprint(&b, fset, []byte(src), errors.New("some error"))
+	require.Equal(t, "some error\n", b.String())
+
+	// an error at the end of the file is shown on the last line
+	eof := "x = (\n"
+	fset = token.NewFileSet()
+	file = fset.AddFile("", -1, len(eof))
+	file.SetLinesForContent([]byte(eof))
+	pos := file.Pos(len(eof))
+	b.Reset()
+	diagnostics.Fprint(&b, fset, []byte(eof), &diagnostics.Diagnostic{
+		Pos:  file.Position(pos),
+		Span: diagnostics.Span{Pos: pos},
+		Msg:  "expected operand, found 'EOF'",
+	})
+	require.Equal(t, `<input>:1:7: error: expected operand, found 'EOF'
+ 1 | x = (
+   |      ^
+`, b.String())
+}
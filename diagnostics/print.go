@@ -0,0 +1,156 @@
+// This file implements the rendering of diagnostics as plain text.
+
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+// Fprint writes the diagnostics described by err to w if err is a
+// List or a *Diagnostic; otherwise it writes the err string. If fset
+// and the source text src of the file the diagnostics refer to are
+// provided, each diagnostic is followed by an excerpt of the source
+// line it refers to, with its primary span underlined by carets.
+// Related spans are underlined by dashes and suggested fixes are
+// shown applied to the source line.
+func Fprint(w io.Writer, fset *token.FileSet, src []byte, err error) {
+	var list List
+	switch err := err.(type) {
+	case List:
+		list = err
+	case *Diagnostic:
+		list = List{err}
+	default:
+		if err != nil {
+			fmt.Fprintf(w, "%s\n", err)
+		}
+		return
+	}
+
+	p := printer{w: w, fset: fset, src: src}
+	for _, d := range list {
+		p.diagnostic(d)
+	}
+}
+
+type printer struct {
+	w    io.Writer
+	fset *token.FileSet
+	src  []byte
+}
+
+func (p *printer) header(pos token.Position, kind, msg string) {
+	if pos.Filename != "" || pos.IsValid() {
+		fmt.Fprintf(p.w, "%s: ", position(pos))
+	}
+	fmt.Fprintf(p.w, "%s: %s\n", kind, msg)
+}
+
+func (p *printer) diagnostic(d *Diagnostic) {
+	kind := d.Severity.String()
+	if d.Code != "" {
+		kind += "[" + d.Code + "]"
+	}
+	p.header(d.Pos, kind, d.Msg)
+	p.excerpt(d.Span, "", '^')
+
+	for _, r := range d.Related {
+		p.header(r.Pos, "note", r.Msg)
+		p.excerpt(r.Span, "", '-')
+	}
+
+	for _, f := range d.Fixes {
+		pos := d.Pos
+		if f.Span.Pos.IsValid() && p.fset != nil {
+			pos = p.fset.Position(f.Span.Pos)
+		}
+		p.header(pos, "help", f.Msg)
+		p.excerpt(f.Span, f.NewText, '+')
+	}
+}
+
+// offsets returns the file containing the span s and the source
+// offsets of s, or false if s does not describe text of p.src.
+func (p *printer) offsets(s Span) (file *token.File, start, end int, ok bool) {
+	if p.fset == nil || !s.Pos.IsValid() {
+		return nil, 0, 0, false
+	}
+	file = p.fset.File(s.Pos)
+	if file == nil || file.Size() != len(p.src) {
+		return nil, 0, 0, false
+	}
+	start = file.Offset(s.Pos)
+	end = start
+	if base := file.Base(); base <= int(s.End) && int(s.End) <= base+file.Size() {
+		end = file.Offset(s.End)
+	}
+	return file, start, end, true
+}
+
+// excerpt writes the source line containing the start of the span s
+// and underlines the part of s on that line with mark. If newText
+// is not empty, the source text of s is replaced by newText first and
+// newText is underlined instead.
+func (p *printer) excerpt(s Span, newText string, mark rune) {
+	file, start, end, ok := p.offsets(s)
+	if !ok {
+		return
+	}
+
+	line := file.Line(s.Pos)
+	lineStart := file.Offset(file.LineStart(line))
+	lineEnd := len(p.src)
+	if line < file.LineCount() {
+		lineEnd = file.Offset(file.LineStart(line + 1))
+	}
+	if lineEnd > lineStart && p.src[lineEnd-1] == '\n' {
+		lineEnd--
+	}
+	if start > lineEnd {
+		// the span starts at the end of the file, after
+		// the newline ending the last line
+		start = lineEnd
+	}
+	if end < start {
+		end = start
+	}
+	if end > lineEnd {
+		// only the first line of the span is underlined
+		end = lineEnd
+	}
+
+	before := string(p.src[lineStart:start])
+	text := string(p.src[start:end])
+	after := strings.TrimSuffix(string(p.src[end:lineEnd]), "\r")
+	if newText != "" {
+		text = newText
+	}
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		n = 1
+	}
+
+	num := fmt.Sprint(line)
+	fmt.Fprintf(p.w, " %s | %s%s%s\n", num, before, text, after)
+	fmt.Fprintf(p.w, " %s | %s%s\n", strings.Repeat(" ", len(num)), indent(before), strings.Repeat(string(mark), n))
+}
+
+// indent returns s with all characters other than tabs replaced by
+// spaces, so that text written after it lines up with text written
+// after s.
+func indent(s string) string {
+	var b strings.Builder
+	for _, ch := range s {
+		if ch == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
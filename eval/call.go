@@ -0,0 +1,270 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/object"
+	"github.com/capnspacehook/rose/token"
+)
+
+var builtins = map[string]*object.Builtin{
+	"cap":   {Name: "cap", Fn: builtinCap},
+	"len":   {Name: "len", Fn: builtinLen},
+	"print": {Name: "print", Fn: builtinPrint},
+	"range": {Name: "range", Fn: builtinRange},
+}
+
+func evalCallExpr(call *ast.CallExpr, env *object.Environment) (object.Object, error) {
+	fn, err := Eval(call.Fun, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		return applyFunction(fn, call, env)
+	case *object.Builtin:
+		return applyBuiltin(fn, call, env)
+	}
+
+	return nil, newError(call.Pos(), "cannot call non-function %s", fn.Type())
+}
+
+// applyFunction calls the function fn with the arguments of call,
+// evaluated in env. Arguments may be passed by position or by name;
+// parameters without an argument take their default value, which
+// is evaluated in the environment of the call once the preceding
+// parameters are bound. The arguments for a variadic parameter are
+// collected in a list.
+func applyFunction(fn *object.Function, call *ast.CallExpr, env *object.Environment) (object.Object, error) {
+	name := fn.Name
+	if name == "" {
+		name = "function literal"
+	}
+
+	type param struct {
+		name  *ast.Ident
+		field *ast.Field
+	}
+	var params []param
+	if fn.Params != nil {
+		for _, field := range fn.Params.List {
+			for _, name := range field.Names {
+				params = append(params, param{name, field})
+			}
+		}
+	}
+	last := len(params) - 1
+	variadic := last >= 0 && params[last].field.Ellipsis.IsValid()
+
+	args := make([]object.Object, len(params))
+	var rest []object.Object
+	for i, arg := range call.Args {
+		if kw, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			j := -1
+			for k, p := range params {
+				if p.name.Name == kw.Name.Name && !(variadic && k == last) {
+					j = k
+					break
+				}
+			}
+			switch {
+			case j < 0:
+				return nil, newError(kw.Pos(), "unknown parameter %s in call to %s", kw.Name.Name, name)
+			case args[j] != nil:
+				return nil, newError(kw.Pos(), "duplicate argument for parameter %s in call to %s", kw.Name.Name, name)
+			}
+			val, err := Eval(kw.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			args[j] = val
+			continue
+		}
+
+		val, err := Eval(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case variadic && i >= last:
+			if call.Ellipsis.IsValid() {
+				elems, err := spread(arg.Pos(), val)
+				if err != nil {
+					return nil, err
+				}
+				rest = append(rest, elems...)
+				break
+			}
+			rest = append(rest, val)
+		case i < len(params):
+			args[i] = val
+		default:
+			return nil, newError(arg.Pos(), "too many arguments in call to %s", name)
+		}
+	}
+	if variadic {
+		args[last] = &object.List{Elements: rest}
+	}
+
+	fnEnv := object.NewEnclosedEnvironment(fn.Env)
+	for i, p := range params {
+		if args[i] == nil {
+			if p.field.Default == nil {
+				return nil, newError(call.Rparen, "not enough arguments in call to %s", name)
+			}
+			val, err := Eval(p.field.Default, fnEnv)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = val
+		}
+		if p.name.Name != "_" {
+			fnEnv.Set(p.name.Name, args[i])
+		}
+	}
+
+	_, err := evalStmts(fn.Body.List, fnEnv)
+	if ret, isReturn := err.(*returnValue); isReturn {
+		return ret.val, nil
+	}
+
+	return nil, err
+}
+
+// applyBuiltin calls the builtin function fn with the arguments of
+// call, evaluated in env. Builtin functions take no keyword arguments.
+func applyBuiltin(fn *object.Builtin, call *ast.CallExpr, env *object.Environment) (object.Object, error) {
+	var args []object.Object
+	for i, arg := range call.Args {
+		if kw, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			return nil, newError(kw.Pos(), "unexpected keyword argument in call to %s", fn.Name)
+		}
+		val, err := Eval(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		if call.Ellipsis.IsValid() && i == len(call.Args)-1 {
+			elems, err := spread(arg.Pos(), val)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, elems...)
+			continue
+		}
+		args = append(args, val)
+	}
+
+	val, err := fn.Fn(env, args)
+	if err != nil {
+		if _, isError := err.(*Error); !isError {
+			err = newError(call.Pos(), "%s", err)
+		}
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// spread returns the elements of the final argument x of a call
+// with "...".
+func spread(pos token.Pos, x object.Object) ([]object.Object, error) {
+	c, ok := x.(object.Container)
+	if !ok {
+		return nil, newError(pos, "cannot use ... with %s", x.Type())
+	}
+
+	return c.Elems(), nil
+}
+
+// builtinPrint writes its arguments separated by spaces and
+// followed by a newline to the output of env.
+func builtinPrint(env *object.Environment, args []object.Object) (object.Object, error) {
+	a := make([]interface{}, len(args))
+	for i, arg := range args {
+		a[i] = arg
+	}
+	_, err := fmt.Fprintln(env.Output(), a...)
+
+	return nil, err
+}
+
+// builtinLen returns the number of elements of a container or the
+// number of chars of a string.
+func builtinLen(_ *object.Environment, args []object.Object) (object.Object, error) {
+	if err := checkArgCount("len", args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	switch x := args[0].(type) {
+	case object.String:
+		return object.Int(utf8.RuneCountInString(string(x))), nil
+	case object.Container:
+		return object.Int(x.Len()), nil
+	}
+
+	return nil, fmt.Errorf("invalid argument: %s for len", args[0].Type())
+}
+
+// builtinCap returns the capacity of a list.
+func builtinCap(_ *object.Environment, args []object.Object) (object.Object, error) {
+	if err := checkArgCount("cap", args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	if l, ok := args[0].(*object.List); ok {
+		return object.Int(cap(l.Elements)), nil
+	}
+
+	return nil, fmt.Errorf("invalid argument: %s for cap", args[0].Type())
+}
+
+// builtinRange returns the list of ints from start up to but not
+// including stop, incremented by step. It is called as range(stop),
+// range(start, stop) or range(start, stop, step); start defaults to
+// 0 and step to 1.
+func builtinRange(_ *object.Environment, args []object.Object) (object.Object, error) {
+	if err := checkArgCount("range", args, 1, 3); err != nil {
+		return nil, err
+	}
+
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		n, ok := arg.(object.Int)
+		if !ok {
+			return nil, fmt.Errorf("invalid argument: %s for range", arg.Type())
+		}
+		bounds[i] = int64(n)
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, fmt.Errorf("range step must not be zero")
+	}
+	var elems []object.Object
+	for i := start; step > 0 && i < stop || step < 0 && i > stop; i += step {
+		elems = append(elems, object.Int(i))
+		if step > 0 && i > math.MaxInt64-step || step < 0 && i < math.MinInt64-step {
+			break
+		}
+	}
+
+	return &object.List{Elements: elems}, nil
+}
+
+func checkArgCount(name string, args []object.Object, min, max int) error {
+	switch {
+	case len(args) < min:
+		return fmt.Errorf("not enough arguments for %s", name)
+	case len(args) > max:
+		return fmt.Errorf("too many arguments for %s", name)
+	}
+
+	return nil
+}
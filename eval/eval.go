@@ -1,54 +1,189 @@
 package eval
 
 import (
+	"fmt"
+	"go/constant"
+	gotoken "go/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/lexer"
 	"github.com/capnspacehook/rose/object"
+	"github.com/capnspacehook/rose/token"
+	"github.com/capnspacehook/rose/types"
 )
 
+// compoundOps maps compound assignment tokens to their
+// corresponding binary operators.
+var compoundOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN:     token.ADD,
+	token.SUB_ASSIGN:     token.SUB,
+	token.MUL_ASSIGN:     token.MUL,
+	token.QUO_ASSIGN:     token.QUO,
+	token.REM_ASSIGN:     token.REM,
+	token.EXP_ASSIGN:     token.EXP,
+	token.AND_ASSIGN:     token.AND,
+	token.OR_ASSIGN:      token.OR,
+	token.XOR_ASSIGN:     token.XOR,
+	token.SHL_ASSIGN:     token.SHL,
+	token.SHR_ASSIGN:     token.SHR,
+	token.AND_NOT_ASSIGN: token.AND_NOT,
+}
+
 var (
-	NIL   *object.Nil
-	TRUE  *object.Bool
-	FALSE *object.Bool
+	NIL   = object.Nil{}
+	TRUE  = object.Bool(true)
+	FALSE = object.Bool(false)
 )
 
-func init() {
-	NIL = &object.Nil{}
-	t := object.Bool(true)
-	f := object.Bool(false)
+// An Error describes a runtime error. The position Pos, if valid,
+// points to the beginning of the node whose evaluation failed.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Diagnostic returns the diagnostic describing e, with the position
+// of e interpreted relative to fset.
+func (e *Error) Diagnostic(fset *token.FileSet) *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Pos:  fset.Position(e.Pos),
+		Span: diagnostics.Span{Pos: e.Pos},
+		Msg:  e.Msg,
+	}
+}
 
-	TRUE = &t
-	FALSE = &f
+func newError(pos token.Pos, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
 }
 
-func Eval(node ast.Node) (object.Object, error) {
+// Eval evaluates node in the environment env and returns the
+// resulting value. Statements that do not produce a value, such
+// as declarations, evaluate to nil.
+func Eval(node ast.Node, env *object.Environment) (object.Object, error) {
 	switch node := node.(type) {
-	case *ast.Program:
-		return evalStatements(node.Statements)
-		// Statements
-	case *ast.ExprStatement:
-		return Eval(node.Expr)
-
-		// Expressions
-	case *ast.NilLiteral:
-		return NIL, nil
-	case *ast.BooleanLiteral:
-		return nativeBoolToBoolObj(node.Value), nil
-	case *ast.IntegerLiteral:
-		return object.Int(node.Value), nil
-	case *ast.FloatLiteral:
-		return object.Float(node.Value), nil
-	case *ast.CharLiteral:
-		return object.Char(node.Value), nil
-	case *ast.StringLiteral:
-		return object.String(node.Token.Literal), nil
+	case *ast.File:
+		return evalStmts(node.Stmts, env)
+
+	// Statements
+	case *ast.DeclStmt:
+		return Eval(node.Decl, env)
+	case *ast.EmptyStmt:
+		return nil, nil
+	case *ast.ExprStmt:
+		return Eval(node.Expr, env)
+	case *ast.AssignStmt:
+		return nil, evalAssignStmt(node, env)
+	case *ast.IncDecStmt:
+		op := token.ADD
+		if node.Tok == token.DEC {
+			op = token.SUB
+		}
+		return nil, evalCompoundAssign(node.Expr, node.TokPos, op, nil, env)
+	case *ast.ReturnStmt:
+		return nil, evalReturnStmt(node, env)
+	case *ast.BranchStmt:
+		return nil, &branch{pos: node.TokPos, tok: node.Tok}
+	case *ast.BlockStmt:
+		return nil, evalBlock(node.List, env)
+	case *ast.IfStmt:
+		return nil, evalIfStmt(node, env)
+	case *ast.SwitchStmt:
+		return nil, evalSwitchStmt(node, env)
+	case *ast.ForStmt:
+		return nil, evalForStmt(node, env)
+	case *ast.ForInStmt:
+		return nil, evalForInStmt(node, env)
+
+	// Declarations
+	case *ast.GenDecl:
+		if node.Tok == token.CONST {
+			return nil, evalConstDecl(node, env)
+		}
+		for _, spec := range node.Specs {
+			spec := spec.(*ast.ValueSpec)
+			if err := evalValueSpec(spec, node.Tok, spec.Values, env, env); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *ast.FuncDecl:
+		env.Set(node.Name.Name, &object.Function{
+			Name:   node.Name.Name,
+			Params: node.Type.Params,
+			Body:   node.Body,
+			Env:    env,
+		})
+		return nil, nil
+
+	// Expressions
+	case *ast.BasicLit:
+		return evalBasicLit(node)
+	case *ast.Ident:
+		return evalIdent(node, env)
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.FuncLit:
+		return &object.Function{Params: node.Type.Params, Body: node.Body, Env: env}, nil
+	case *ast.ListLit:
+		elems, err := evalExprs(node.Elts, env)
+		if err != nil {
+			return nil, err
+		}
+		return &object.List{Elements: elems}, nil
+	case *ast.MapLit:
+		m := new(object.Map)
+		for _, kv := range node.Elts {
+			key, err := Eval(kv.Key, env)
+			if err != nil {
+				return nil, err
+			}
+			val, err := Eval(kv.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, val)
+		}
+		return m, nil
+	case *ast.TupleLit:
+		elems, err := evalExprs(node.Elts, env)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Tuple{Elements: elems}, nil
+	case *ast.ParenExpr:
+		return Eval(node.Expr, env)
+	case *ast.IndexExpr:
+		return evalIndexExpr(node, env)
+	case *ast.SliceExpr:
+		return evalSliceExpr(node, env)
+	case *ast.CallExpr:
+		return evalCallExpr(node, env)
+	case *ast.UnaryExpr:
+		x, err := Eval(node.Expr, env)
+		if err != nil {
+			return nil, err
+		}
+		return evalUnaryExpr(node, x)
+	case *ast.BinaryExpr:
+		return evalBinaryExpr(node, env)
 	}
 
-	return nil, nil
+	return nil, newError(node.Pos(), "cannot evaluate %T", node)
 }
 
-func evalStatements(stmts []ast.Statement) (obj object.Object, err error) {
-	for _, statement := range stmts {
-		obj, err = Eval(statement)
+func evalStmts(stmts []ast.Stmt, env *object.Environment) (obj object.Object, err error) {
+	for _, stmt := range stmts {
+		obj, err = Eval(stmt, env)
 		if err != nil {
 			return nil, err
 		}
@@ -57,7 +192,573 @@ func evalStatements(stmts []ast.Statement) (obj object.Object, err error) {
 	return
 }
 
-func nativeBoolToBoolObj(input bool) *object.Bool {
+func evalExprs(list []ast.Expr, env *object.Environment) ([]object.Object, error) {
+	vals := make([]object.Object, len(list))
+	for i, x := range list {
+		val, err := Eval(x, env)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	return vals, nil
+}
+
+// evalConstDecl evaluates the constant declaration decl. The values
+// of each spec are evaluated with iota bound to the index of the spec
+// in decl. A spec without type and values repeats the values of the
+// last spec with values.
+func evalConstDecl(decl *ast.GenDecl, env *object.Environment) error {
+	var last []ast.Expr
+	for i, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		values := spec.Values
+		if values == nil && spec.Type == nil {
+			values = last
+		}
+		last = values
+
+		iotaEnv := object.NewEnclosedEnvironment(env)
+		iotaEnv.Set("iota", object.Int(i))
+		if err := evalValueSpec(spec, token.CONST, values, iotaEnv, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evalValueSpec binds the names declared by spec with the keyword
+// tok in env to the values of the expressions values, evaluated in
+// valueEnv. Names declared with const or let cannot be reassigned,
+// and are bound to frozen copies of their values so that they never
+// change.
+func evalValueSpec(spec *ast.ValueSpec, tok token.Token, values []ast.Expr, valueEnv, env *object.Environment) error {
+	for i, name := range spec.Names {
+		var val object.Object
+		if i < len(values) {
+			var err error
+			val, err = Eval(values[i], valueEnv)
+			if err != nil {
+				return err
+			}
+		} else {
+			val = zeroValue(spec.Type)
+		}
+
+		switch {
+		case name.Name == "_":
+		case tok == token.VAR:
+			env.Set(name.Name, val)
+		default:
+			c := &object.Constant{Name: name.Name, Tok: tok}
+			env.SetConst(name.Name, object.Freeze(val, c), tok)
+		}
+	}
+
+	return nil
+}
+
+// evalAssignStmt evaluates the assignment s. Identifiers that are
+// not bound in env are bound in env by an ASSIGN. A single value
+// assigned to several variables is unpacked.
+func evalAssignStmt(s *ast.AssignStmt, env *object.Environment) error {
+	if s.Tok != token.ASSIGN {
+		return evalCompoundAssign(s.Lhs[0], s.TokPos, compoundOps[s.Tok], s.Rhs[0], env)
+	}
+	if len(s.Lhs) != len(s.Rhs) && len(s.Rhs) != 1 {
+		return newError(s.Pos(), "assignment mismatch: %d variables but %d values", len(s.Lhs), len(s.Rhs))
+	}
+
+	// the operands of the left-hand side and all values are
+	// evaluated before anything is assigned
+	targets := make([]*target, len(s.Lhs))
+	for i, lhs := range s.Lhs {
+		t, err := evalTarget(lhs, env)
+		if err != nil {
+			return err
+		}
+		targets[i] = t
+	}
+	vals, err := evalExprs(s.Rhs, env)
+	if err != nil {
+		return err
+	}
+	if len(s.Lhs) != len(s.Rhs) {
+		// the value is unpacked into the variables
+		if vals, err = unpack(s.Rhs[0].Pos(), vals[0], len(s.Lhs)); err != nil {
+			return err
+		}
+	}
+
+	for i, t := range targets {
+		if err := t.set(vals[i], env, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evalCompoundAssign evaluates the assignment of lhs op rhs to lhs.
+func evalCompoundAssign(lhs ast.Expr, opPos token.Pos, op token.Token, rhs ast.Expr, env *object.Environment) error {
+	t, err := evalTarget(lhs, env)
+	if err != nil {
+		return err
+	}
+	x, err := t.get(env)
+	if err != nil {
+		return err
+	}
+
+	var y object.Object
+	if rhs != nil {
+		if y, err = Eval(rhs, env); err != nil {
+			return err
+		}
+	} else if _, isFloat := x.(object.Float); isFloat {
+		// increment or decrement
+		y = object.Float(1)
+	} else {
+		y = object.Int(1)
+	}
+
+	val, err := binaryOp(opPos, op, x, y)
+	if err != nil {
+		return err
+	}
+
+	return t.set(val, env, false)
+}
+
+// A target is the location denoted by the left-hand side of an
+// assignment: a variable or an element of a list or map.
+type target struct {
+	lhs   ast.Expr
+	ident *ast.Ident    // variable; or nil for an element
+	x     object.Object // list or map holding the element
+	index object.Object // index or key of the element
+}
+
+// evalTarget evaluates the operands of the left-hand side
+// expression lhs.
+func evalTarget(lhs ast.Expr, env *object.Environment) (*target, error) {
+	for {
+		paren, isParen := lhs.(*ast.ParenExpr)
+		if !isParen {
+			break
+		}
+		lhs = paren.Expr
+	}
+
+	switch e := lhs.(type) {
+	case *ast.Ident:
+		return &target{lhs: lhs, ident: e}, nil
+	case *ast.IndexExpr:
+		x, err := Eval(e.X, env)
+		if err != nil {
+			return nil, err
+		}
+		index, err := Eval(e.Index, env)
+		if err != nil {
+			return nil, err
+		}
+		switch x.(type) {
+		case *object.List, *object.Map:
+			return &target{lhs: lhs, x: x, index: index}, nil
+		}
+		return nil, newError(lhs.Pos(), "cannot assign to %s (%s is immutable)", types.ExprString(lhs), x.Type())
+	}
+
+	return nil, newError(lhs.Pos(), "cannot evaluate assignment to %T", lhs)
+}
+
+// get returns the value at t.
+func (t *target) get(env *object.Environment) (object.Object, error) {
+	if t.ident != nil {
+		return evalIdent(t.ident, env)
+	}
+	return index(t.lhs.(*ast.IndexExpr), t.x, t.index)
+}
+
+// set assigns val to t. If define is set, a variable that is not
+// bound is bound in env. Variables are assigned mutable copies of
+// constant values.
+func (t *target) set(val object.Object, env *object.Environment, define bool) error {
+	if t.ident != nil {
+		name := t.ident.Name
+		if name == "_" {
+			return nil
+		}
+		val = object.Thaw(val)
+		if _, ok := env.Get(name); !ok && define {
+			env.Set(name, val)
+			return nil
+		}
+		if err := env.Assign(name, val); err != nil {
+			return newError(t.lhs.Pos(), "%s", err)
+		}
+		return nil
+	}
+
+	if c := object.FrozenBy(t.x); c != nil {
+		return frozenError(t.lhs, "assign to", c)
+	}
+	switch x := t.x.(type) {
+	case *object.List:
+		i, err := checkIndex(t.lhs.(*ast.IndexExpr).Index.Pos(), t.index, len(x.Elements))
+		if err != nil {
+			return err
+		}
+		return x.Set(i, val)
+	case *object.Map:
+		return x.Set(t.index, val)
+	}
+
+	return nil
+}
+
+// frozenError returns the error of changing x by action, where x
+// is part of the constant c.
+func frozenError(x ast.Expr, action string, c *object.Constant) *Error {
+	return newError(x.Pos(), "cannot %s %s (%s declared %s)", action, types.ExprString(x), c.Name, c.Tok)
+}
+
+// zeroValue returns the zero value of the type typ.
+func zeroValue(typ ast.Expr) object.Object {
+	if ident, isIdent := typ.(*ast.Ident); isIdent {
+		switch ident.Name {
+		case "bool":
+			return FALSE
+		case "int":
+			return object.Int(0)
+		case "float":
+			return object.Float(0)
+		case "complex":
+			return object.Complex(0)
+		case "char":
+			return object.Char(0)
+		case "string":
+			return object.String("")
+		}
+	}
+	switch typ.(type) {
+	case *ast.ListType:
+		return new(object.List)
+	case *ast.MapType:
+		return new(object.Map)
+	}
+
+	return NIL
+}
+
+func evalBasicLit(lit *ast.BasicLit) (object.Object, error) {
+	switch lit.Kind {
+	case token.INT:
+		i, err := strconv.ParseInt(lit.Value, 0, 64)
+		if err != nil {
+			return nil, newError(lit.Pos(), "integer literal %s overflows int", lit.Value)
+		}
+		return object.Int(i), nil
+	case token.FLOAT:
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return nil, newError(lit.Pos(), "float literal %s overflows float", lit.Value)
+		}
+		return object.Float(f), nil
+	case token.IMAG:
+		// the integer part of an imaginary literal may have a
+		// leading 0 but is always decimal, as in Go
+		val := constant.MakeFromLiteral(lit.Value, gotoken.IMAG, 0)
+		f, _ := constant.Float64Val(constant.Imag(val))
+		if val.Kind() == constant.Unknown || math.IsInf(f, 0) {
+			return nil, newError(lit.Pos(), "imaginary literal %s overflows complex", lit.Value)
+		}
+		return object.Complex(complex(0, f)), nil
+	case token.CHAR:
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, newError(lit.Pos(), "invalid char literal %s", lit.Value)
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		return object.Char(r), nil
+	case token.STRING, token.RAW_STRING:
+		val := lit.Value
+		if lit.Kind == token.STRING {
+			val = lexer.UnescapeBraces(val)
+		}
+		s, err := strconv.Unquote(val)
+		if err != nil {
+			return nil, newError(lit.Pos(), "invalid string literal %s", lit.Value)
+		}
+		return object.String(s), nil
+	}
+
+	return nil, newError(lit.Pos(), "cannot evaluate %s literal", lit.Kind)
+}
+
+// evalInterpolatedString evaluates the interpolated string x. The
+// literal segments are concatenated with the string representations
+// of the values of the interpolated expressions.
+func evalInterpolatedString(x *ast.InterpolatedString, env *object.Environment) (object.Object, error) {
+	var b strings.Builder
+	for i, part := range x.Parts {
+		// literal segments and interpolated expressions alternate
+		if i%2 == 0 {
+			lit := part.(*ast.BasicLit)
+			// strip the quote and brace characters delimiting the
+			// segment before unquoting it as a string literal
+			val := lexer.UnescapeBraces(lit.Value[1 : len(lit.Value)-1])
+			seg, err := strconv.Unquote(`"` + val + `"`)
+			if err != nil {
+				return nil, newError(lit.Pos(), "invalid string literal %s", lit.Value)
+			}
+			b.WriteString(seg)
+			continue
+		}
+
+		val, err := Eval(part, env)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			b.WriteString(val.String())
+		}
+	}
+
+	return object.String(b.String()), nil
+}
+
+func evalIdent(ident *ast.Ident, env *object.Environment) (object.Object, error) {
+	if val, ok := env.Get(ident.Name); ok {
+		return val, nil
+	}
+
+	switch ident.Name {
+	case "nil":
+		return NIL, nil
+	case "true":
+		return TRUE, nil
+	case "false":
+		return FALSE, nil
+	}
+	if fn, ok := builtins[ident.Name]; ok {
+		return fn, nil
+	}
+
+	return nil, newError(ident.Pos(), "undefined: %s", ident.Name)
+}
+
+func evalIndexExpr(x *ast.IndexExpr, env *object.Environment) (object.Object, error) {
+	val, err := Eval(x.X, env)
+	if err != nil {
+		return nil, err
+	}
+	i, err := Eval(x.Index, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return index(x, val, i)
+}
+
+// index returns the element of val at index i, where x is the
+// index expression. Indexing a map with a key it does not hold
+// results in nil, as the type of its values is not known.
+func index(x *ast.IndexExpr, val, i object.Object) (object.Object, error) {
+	var elems []object.Object
+	switch val := val.(type) {
+	case object.String:
+		elems, _ = iterElems(x.X.Pos(), val, 1)
+	case *object.List:
+		elems = val.Elements
+	case *object.Tuple:
+		elems = val.Elements
+	case *object.Map:
+		if elem, ok := val.Get(i); ok {
+			return elem, nil
+		}
+		return NIL, nil
+	default:
+		return nil, newError(x.X.Pos(), "cannot index %s", val.Type())
+	}
+
+	n, err := checkIndex(x.Index.Pos(), i, len(elems))
+	if err != nil {
+		return nil, err
+	}
+
+	return elems[n], nil
+}
+
+// checkIndex returns the index i of a sequence of length n, or an
+// error if i is not an int in range.
+func checkIndex(pos token.Pos, i object.Object, n int) (int, error) {
+	index, ok := i.(object.Int)
+	if !ok {
+		return 0, newError(pos, "invalid index of type %s", i.Type())
+	}
+	if index < 0 || int64(index) >= int64(n) {
+		return 0, newError(pos, "index out of range [%d] with length %d", index, n)
+	}
+
+	return int(index), nil
+}
+
+// evalSliceExpr evaluates the slice expression x. Strings are
+// sliced by char. The maximum index of a 3-index slice of a list
+// sets the capacity of the resulting list, which shares its
+// elements with the sliced list.
+func evalSliceExpr(x *ast.SliceExpr, env *object.Environment) (object.Object, error) {
+	val, err := Eval(x.X, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var elems []object.Object
+	switch val := val.(type) {
+	case object.String:
+		elems, _ = iterElems(x.X.Pos(), val, 1)
+	case *object.List:
+		elems = val.Elements
+	case *object.Tuple:
+		elems = val.Elements
+	default:
+		return nil, newError(x.X.Pos(), "cannot slice %s", val.Type())
+	}
+	if _, isList := val.(*object.List); !isList {
+		elems = elems[:len(elems):len(elems)]
+	}
+
+	// the indices default to 0, the length and the capacity
+	indices := []int64{0, int64(len(elems)), int64(cap(elems))}
+	for i, e := range []ast.Expr{x.Low, x.High, x.Max} {
+		if e == nil {
+			continue
+		}
+		index, err := Eval(e, env)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := index.(object.Int)
+		if !ok {
+			return nil, newError(e.Pos(), "invalid slice index of type %s", index.Type())
+		}
+		indices[i] = int64(n)
+	}
+	low, high, max := indices[0], indices[1], indices[2]
+	if low < 0 || high < low || max < high || max > int64(cap(elems)) {
+		return nil, newError(x.Lbrack, "slice bounds out of range [%d:%d:%d] with capacity %d", low, high, max, cap(elems))
+	}
+
+	switch val.(type) {
+	case object.String:
+		var s []rune
+		for _, c := range elems[low:high] {
+			s = append(s, rune(c.(object.Char)))
+		}
+		return object.String(s), nil
+	case *object.Tuple:
+		return &object.Tuple{Elements: elems[low:high:high], Const: object.FrozenBy(val)}, nil
+	}
+
+	// the slice of a frozen container is part of the same constant
+	return &object.List{Elements: elems[low:high:max], Const: object.FrozenBy(val)}, nil
+}
+
+func evalUnaryExpr(x *ast.UnaryExpr, val object.Object) (object.Object, error) {
+	operable, ok := val.(object.UnaryOperable)
+	if !ok {
+		return nil, newError(x.Pos(), "operator %s not defined on %s", x.Op, val.Type())
+	}
+	res, err := operable.UnaryOp(x.Op)
+	if err != nil {
+		return nil, newError(x.Pos(), "%s", err)
+	}
+
+	return res, nil
+}
+
+func evalBinaryExpr(x *ast.BinaryExpr, env *object.Environment) (object.Object, error) {
+	lhs, err := Eval(x.Lhs, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// logical operators short-circuit
+	if x.Op == token.LAND || x.Op == token.LOR {
+		l, ok := lhs.(object.Bool)
+		if !ok {
+			return nil, newError(x.OpPos, "operator %s not defined on %s", x.Op, lhs.Type())
+		}
+		if bool(l) == (x.Op == token.LOR) {
+			return l, nil
+		}
+		rhs, err := Eval(x.Rhs, env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rhs.(object.Bool); !ok {
+			return nil, newError(x.OpPos, "operator %s not defined on %s", x.Op, rhs.Type())
+		}
+		return rhs, nil
+	}
+
+	rhs, err := Eval(x.Rhs, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// appending to and prepending to a list change the list
+	if _, isList := lhs.(*object.List); isList && x.Op == token.SHL {
+		if c := object.FrozenBy(lhs); c != nil {
+			return nil, frozenError(x.Lhs, "append to", c)
+		}
+	}
+	if _, isList := rhs.(*object.List); isList && x.Op == token.SHR {
+		if c := object.FrozenBy(rhs); c != nil {
+			return nil, frozenError(x.Rhs, "prepend to", c)
+		}
+	}
+
+	return binaryOp(x.OpPos, x.Op, lhs, rhs)
+}
+
+// binaryOp returns the result of lhs op rhs for any operator op
+// except the logical operators. The in operators test whether
+// a container holds lhs, and lhs >> rhs prepends lhs to the list
+// rhs.
+func binaryOp(opPos token.Pos, op token.Token, lhs, rhs object.Object) (object.Object, error) {
+	if c, isContainer := rhs.(object.Container); isContainer && (op == token.IN || op == token.NOT_IN) {
+		return nativeBoolToBoolObj(c.Contains(lhs) == (op == token.IN)), nil
+	}
+	if l, isList := rhs.(*object.List); isList && op == token.SHR {
+		if err := l.Prepend(lhs); err != nil {
+			return nil, newError(opPos, "%s", err)
+		}
+		return l, nil
+	}
+
+	operable, ok := lhs.(object.BinaryOperable)
+	if !ok {
+		switch op {
+		case token.EQL:
+			return nativeBoolToBoolObj(lhs.Equals(rhs)), nil
+		case token.NEQ:
+			return nativeBoolToBoolObj(!lhs.Equals(rhs)), nil
+		}
+		return nil, newError(opPos, "operator %s not defined on %s", op, lhs.Type())
+	}
+	val, err := operable.BinaryOp(op, rhs)
+	if err != nil {
+		return nil, newError(opPos, "%s", err)
+	}
+
+	return val, nil
+}
+
+func nativeBoolToBoolObj(input bool) object.Bool {
 	if input {
 		return TRUE
 	}
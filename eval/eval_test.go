@@ -1,25 +1,485 @@
 package eval
 
 import (
-	"fmt"
 	"strings"
 	"testing"
 
-	"github.com/capnspacehook/rose/parse"
+	"github.com/stretchr/testify/require"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/object"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/token"
 )
 
-func TestEval(t *testing.T) {
-	input := `'☺'`
+func TestEvalLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"5", object.Int(5)},
+		{"0x1f", object.Int(31)},
+		{"0o17", object.Int(15)},
+		{"017", object.Int(15)},
+		{"0b_1010", object.Int(10)},
+		{"1_000", object.Int(1000)},
+		{"2.5", object.Float(2.5)},
+		{"1_0.2_5", object.Float(10.25)},
+		{"0x1.8p1", object.Float(3)},
+		{"2.5i", object.Complex(2.5i)},
+		{"0x10i", object.Complex(16i)},
+		{"0123i", object.Complex(123i)},
+		{`'☺'`, object.Char('☺')},
+		{`'\n'`, object.Char('\n')},
+		{`"hi\tthere"`, object.String("hi\tthere")},
+		{"`raw\\n`", object.String(`raw\n`)},
+		{`"{{Braces}} {{{{galore}}}}"`, object.String("{Braces} {{galore}}")},
+		{`"a}}b"`, object.String("a}b")},
+		{"`{{raw}}`", object.String("{{raw}}")},
+		{"true", TRUE},
+		{"false", FALSE},
+		{"nil", NIL},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalExprs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"-5", object.Int(-5)},
+		{"+5", object.Int(5)},
+		{"~5", object.Int(-6)},
+		{"-2.5", object.Float(-2.5)},
+		{"not true", FALSE},
+		{"1 + 2 * 3", object.Int(7)},
+		{"(1 + 2) * 3", object.Int(9)},
+		{"7 / 2", object.Int(3)},
+		{"7 % 2", object.Int(1)},
+		{"1.5 * 2.0", object.Float(3)},
+		{`"a" + "b"`, object.String("ab")},
+		{"true and false", FALSE},
+		{"false or true", TRUE},
+		{"false and undefined", FALSE},
+		{"true or undefined", TRUE},
+		{`x = 2; "x is {x}, {{x}} is {x * 2}"`, object.String("x is 2, {x} is 4")},
+		{`"{1}{'a'}{[1, "b"]}"`, object.String(`1'a'[1, "b"]`)},
+		{`x = "b"; "a\t{x + "c"}\n}}"`, object.String("a\tbc\n}")},
+		{`x = 1; "outer {"inner {x}"}"`, object.String("outer inner 1")},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalBinaryOps(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"7 - 10", object.Int(-3)},
+		{"-7 / 2", object.Int(-3)},
+		{"-7 % 2", object.Int(-1)},
+		{"2 ** 10", object.Int(1024)},
+		{"3 ** 0", object.Int(1)},
+		{"6 & 3", object.Int(2)},
+		{"6 | 3", object.Int(7)},
+		{"6 ^ 3", object.Int(5)},
+		{"6 &^ 3", object.Int(4)},
+		{"1 << 4", object.Int(16)},
+		{"-16 >> 2", object.Int(-4)},
+		{"0 << 64", object.Int(0)},
+		{"-1 << 63", object.Int(-9223372036854775808)},
+		{"-1 >> 100", object.Int(-1)},
+		{"2i * 2i", object.Complex(-4)},
+		{"1.5i - 0.5i", object.Complex(1i)},
+		{"-2i / 1i", object.Complex(-2)},
+		{"1i == 1i", TRUE},
+		{"1i != 2i", TRUE},
+		{"1 < 2", TRUE},
+		{"2 <= 2", TRUE},
+		{"1 > 2", FALSE},
+		{"1 >= 2", FALSE},
+		{"1 == 1", TRUE},
+		{"1 != 1", FALSE},
+		{"7.0 / 2.0", object.Float(3.5)},
+		{"2.0 ** 0.5 > 1.41", TRUE},
+		{"1.0 / 0.0 > 1e308", TRUE},
+		{"1.5 < 2.5", TRUE},
+		{"'a' + 1", object.Char('b')},
+		{"'c' - 'a'", object.Char(2)},
+		{"'a' < 'b'", TRUE},
+		{"'b' in \"abc\"", TRUE},
+		{"'z' not in \"abc\"", TRUE},
+		{`"ab" + "cd"`, object.String("abcd")},
+		{`"abc" < "abd"`, TRUE},
+		{`"a" == "a"`, TRUE},
+		{`"bc" in "abcd"`, TRUE},
+		{`"ca" in "abcd"`, FALSE},
+		{`"ca" not in "abcd"`, TRUE},
+		{"true == false", FALSE},
+		{"true != false", TRUE},
+		{"nil == nil", TRUE},
+		{`not ("a" in "b")`, TRUE},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalDecls(t *testing.T) {
+	env := object.NewEnvironment()
+	obj, err := Eval(parseFile(t, `const a = 4; let b = a * 2
+let c, d = b - 1, "d"
+var (
+	e int
+	f string
+)
+b`), env)
+	require.NoError(t, err)
+	require.Equal(t, object.Int(8), obj)
 
-	program, err := parse.Parse(strings.NewReader(input))
-	if err != nil {
-		t.Fatal(err)
+	for name, expected := range map[string]object.Object{
+		"a": object.Int(4),
+		"b": object.Int(8),
+		"c": object.Int(7),
+		"d": object.String("d"),
+		"e": object.Int(0),
+		"f": object.String(""),
+	} {
+		val, ok := env.Get(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, val, name)
 	}
+}
+
+func TestEvalAssignments(t *testing.T) {
+	env := object.NewEnvironment()
+	obj, err := Eval(parseFile(t, `var a int
+a = 2
+b, c = a + 1, 'x'
+a, b = b, a
+a **= 3
+b++
+c--
+d = 1.5
+d++
+_ = d
+a`), env)
+	require.NoError(t, err)
+	require.Equal(t, object.Int(27), obj)
+
+	for name, expected := range map[string]object.Object{
+		"a": object.Int(27),
+		"b": object.Int(3),
+		"c": object.Char('w'),
+		"d": object.Float(2.5),
+	} {
+		val, ok := env.Get(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, val, name)
+	}
+}
+
+func TestEvalConstAssignments(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"const x = 1; x = 2", "cannot assign to x (declared const)"},
+		{"let x = 1; x = 2", "cannot assign to x (declared let)"},
+		{"let x = 1; x += 2", "cannot assign to x (declared let)"},
+		{"const (x = iota; y); y++", "cannot assign to y (declared const)"},
+		{"let x = 1; y = 2; y, x = x, y", "cannot assign to x (declared let)"},
+	}
+
+	for _, test := range tests {
+		env := object.NewEnvironment()
+		_, err := Eval(parseFile(t, test.input), env)
+		require.EqualError(t, err, test.err, test.input)
+	}
+
+	// constants can be redeclared with var, but variables
+	// cannot be assigned once redeclared as constants
+	env := object.NewEnvironment()
+	_, err := Eval(parseFile(t, "const x = 1"), env)
+	require.NoError(t, err)
+	_, err = Eval(parseFile(t, "var x int; x = 2"), env)
+	require.NoError(t, err)
+	_, err = Eval(parseFile(t, "let x = 3"), env)
+	require.NoError(t, err)
+	_, err = Eval(parseFile(t, "x = 4"), env)
+	require.EqualError(t, err, "cannot assign to x (declared let)")
+}
+
+func TestEvalConstValues(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`const m = {"a": 1}; m["b"] = 2`, `cannot assign to m["b"] (m declared const)`},
+		{"let l = [1]; l[0] = 2", "cannot assign to l[0] (l declared let)"},
+		{"let l = [1]; l[0]++", "cannot assign to l[0] (l declared let)"},
+		{"let l = [1]; l << 2", "cannot append to l (l declared let)"},
+		{"let l = [1]; 0 >> l", "cannot prepend to l (l declared let)"},
+		{"const c = [[1]]; c[0][0] = 2", "cannot assign to c[0][0] (c declared const)"},
+		{"const c = ([1], 2); c[0][0] = 2", "cannot assign to c[0][0] (c declared const)"},
+		{"const c = [[1]]; l = [c[0]]; l[0][0] = 2", "cannot assign to l[0][0] (c declared const)"},
+		{"const c = [[1]]; t = (c[0],); t[0][0] = 2", "cannot assign to t[0][0] (c declared const)"},
+		{"const c = [[1]]; c[:1][0] = [2]", "cannot assign to c[:1][0] (c declared const)"},
+		{"const c = [1]; fn f(l) { l[0] = 2 }; f(c)", "cannot assign to l[0] (c declared const)"},
+		{`const c = {"a": [1]}; for k, v in c { v << 2 }`, "cannot append to v (c declared const)"},
+		{"x = 1; x[0] = 2", "cannot assign to x[0] (int is immutable)"},
+		{"t = (1, 2); t[0] = 2", "cannot assign to t[0] (tuple is immutable)"},
+	}
+
+	for _, test := range tests {
+		_, err := Eval(parseFile(t, test.input), object.NewEnvironment())
+		require.EqualError(t, err, test.err, test.input)
+	}
+
+	// constants hold copies of their values, and variables
+	// are assigned mutable copies of constant values
+	env := object.NewEnvironment()
+	_, err := Eval(parseFile(t, `l = [[1]]
+let c = l
+l[0][0] = 2
+x = c
+x[0][0] = 3
+x << [4]
+let ct = ([1], c[:1])
+t = ct
+t[0][0] = 6
+t[1][0] << 7`), env)
+	require.NoError(t, err)
 
-	obj, err := Eval(program)
-	if err != nil {
-		t.Fatal(err)
+	for name, expected := range map[string]string{
+		"l":  "[[2]]",
+		"c":  "[[1]]",
+		"x":  "[[3], [4]]",
+		"t":  "([6], [[1, 7]])",
+		"ct": "([1], [[1]])",
+	} {
+		val, ok := env.Get(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, val.String(), name)
 	}
+}
+
+func TestEvalConstIota(t *testing.T) {
+	env := object.NewEnvironment()
+	_, err := Eval(parseFile(t, `const (
+	a = 'a' + iota
+	b
+	c, d = iota, "d"
+	e, f
+)`), env)
+	require.NoError(t, err)
+
+	for name, expected := range map[string]object.Object{
+		"a": object.Char('a'),
+		"b": object.Char('b'),
+		"c": object.Int(2),
+		"d": object.String("d"),
+		"e": object.Int(3),
+		"f": object.String("d"),
+	} {
+		val, ok := env.Get(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, val, name)
+	}
+	_, ok := env.Get("iota")
+	require.False(t, ok)
+}
+
+func TestEvalContainers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"[1, 2] + [3]", &object.List{Elements: []object.Object{object.Int(1), object.Int(2), object.Int(3)}}},
+		{"(1, 'a')", &object.Tuple{Elements: []object.Object{object.Int(1), object.Char('a')}}},
+		{"[1, 2][1]", object.Int(2)},
+		{"(1, 'a')[1]", object.Char('a')},
+		{`"héllo"[1]`, object.Char('é')},
+		{`"héllo"[1:3]`, object.String("él")},
+		{"[1, 2, 3][1:]", &object.List{Elements: []object.Object{object.Int(2), object.Int(3)}}},
+		{"len([1, 2, 3])", object.Int(3)},
+		{`len("héllo")`, object.Int(5)},
+		{"cap([1, 2, 3][:1:2])", object.Int(2)},
+		{"range(2, 8, 3)", &object.List{Elements: []object.Object{object.Int(2), object.Int(5)}}},
+		{"range(3, 0, -2)", &object.List{Elements: []object.Object{object.Int(3), object.Int(1)}}},
+		{"2 in [1, 2]", TRUE},
+		{"2 not in (1, 2)", FALSE},
+		{"[1, [2]] == [1, [2]]", TRUE},
+		{"[1] != [2]", TRUE},
+		{`{"a": 1, "b": 2}["b"]`, object.Int(2)},
+		{`{"a": 1}["b"]`, NIL},
+		{`{"a": 1, "a": 2}`, &object.Map{Keys: []object.Object{object.String("a")}, Values: []object.Object{object.Int(2)}}},
+		{`"a" in {"a": 1}`, TRUE},
+		{`{1: 'a', 2: 'b'} == {2: 'b', 1: 'a'}`, TRUE},
+		{`len({(1, 2): "x"})`, object.Int(1)},
+		{"l = [1]; l << 2; 0 >> l", &object.List{Elements: []object.Object{object.Int(0), object.Int(1), object.Int(2)}}},
+		{"l = [1, 2]; l[0] = 3; l[1] += 1; l[0]++; l", &object.List{Elements: []object.Object{object.Int(4), object.Int(3)}}},
+		{`m = {"a": 1}; m["b"] = 2; m["a"] *= 5; m`, &object.Map{
+			Keys:   []object.Object{object.String("a"), object.String("b")},
+			Values: []object.Object{object.Int(5), object.Int(2)},
+		}},
+		{"l = [1]; l2 = l; l2[0] = 2; l[0]", object.Int(2)},
+		{"var l list[int]; l << 1; len(l)", object.Int(1)},
+		{`var m map[string]int; m["a"] = 1; len(m)`, object.Int(1)},
+		{`x = 0; for k, v in {"a": 1, "b": 2} { x += v }; x`, object.Int(3)},
+		{`s = ""; for k in {"a": 1, "b": 2} { s += k }; s`, object.String("ab")},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"fn f(x int) int { return x * 2 }; f(21)", object.Int(42)},
+		{"fn(x int) int { return x * 2 }(21)", object.Int(42)},
+		{"fn fib(n int) int { if n < 2 { return n }; return fib(n-1) + fib(n-2) }; fib(15)", object.Int(610)},
+		{"fn f(x, y=10) { return x - y }; f(1)", object.Int(-9)},
+		{"fn f(x, y=10) { return x - y }; f(y=1, x=3)", object.Int(2)},
+		{"fn f(x, y=x * 2) { return y }; f(4)", object.Int(8)},
+		{"fn f(x, ...rest) { return x, rest }; f(1, 2, 3)", &object.Tuple{Elements: []object.Object{
+			object.Int(1),
+			&object.List{Elements: []object.Object{object.Int(2), object.Int(3)}},
+		}}},
+		{"fn f(...rest int) { return len(rest) }; f([1, 2]...)", object.Int(2)},
+		{"fn f() { return 1, 2 }; a, b = f(); b", object.Int(2)},
+		{"fn f() { }; f()", nil},
+		{"fn adder(n int) { return fn(x int) int { return x + n } }; add2 = adder(2); add2(3)", object.Int(5)},
+		{"n = 0; fn inc() { n++ }; inc(); inc(); n", object.Int(2)},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalControlFlow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"x = 0; if true { x = 1 } else { x = 2 }; x", object.Int(1)},
+		{"x = 0; if y = 3; y > 5 { x = 1 } else if y > 2 { x = 2 } else { x = 3 }; x", object.Int(2)},
+		{"x = 0; for i = 0; i < 5; i++ { x += i }; x", object.Int(10)},
+		{"x = 0; for x < 3 { x++ }; x", object.Int(3)},
+		{"x = 0; for { x++; if x == 4 { break } }; x", object.Int(4)},
+		{"x = 0; for false { x = 1 } else { x = 2 }; x", object.Int(2)},
+		{"x = 0; for i = 0; i < 2; i++ { x = 1 } else { x = 2 }; x", object.Int(1)},
+		{"x = 0; for i in range(5) { if i % 2 == 0 { continue }; x += i }; x", object.Int(4)},
+		{"x = 0; for i in range(10) if i > 6 { x += i }; x", object.Int(24)},
+		{"x = 0; for i in [1, 2] if i > 6 { x += i } else { x = -1 }; x", object.Int(-1)},
+		{"x = 0; for a, b in [(1, 2), (3, 4)] { x += a * b }; x", object.Int(14)},
+		{`x = 'a'; for c in "abc" if c != 'c' { x = c }; x`, object.Char('b')},
+		{"x = 0; switch 2 { case 1: x = 1; case 2, 3: x = 2; default: x = 3 }; x", object.Int(2)},
+		{"x = 0; switch 4 { case 1: x = 1; default: x = 3 }; x", object.Int(3)},
+		{"x = 0; switch { case x > 0: x = 1; case x == 0: x = 2; fallthrough; case false: x += 5 }; x", object.Int(7)},
+		{"x = 0; for i in range(3) { switch i { case 1: break }; x++ }; x", object.Int(3)},
+		{"x = 0; if true { y = 1; x = y }; x", object.Int(1)},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, expectEval(t, test.input), test.input)
+	}
+}
+
+func TestEvalPrint(t *testing.T) {
+	var out strings.Builder
+	env := object.NewEnvironment()
+	env.SetOutput(&out)
+
+	_, err := Eval(parseFile(t, `print("a", 1, 'c', [1, "b"], (2,))
+print()
+l = [1, 2]
+print(l...)
+fn f() { print("in f") }
+f()`), env)
+	require.NoError(t, err)
+	require.Equal(t, "a 1 'c' [1, \"b\"] (2,)\n\n1 2\nin f\n", out.String())
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"x", "undefined: x"},
+		{"1 / 0", "integer divide by zero"},
+		{`1 + "a"`, "mismatched types int and string"},
+		{`-"a"`, "operator - not defined on string"},
+		{"9223372036854775808", "integer literal 9223372036854775808 overflows int"},
+		{"9223372036854775807 + 1", "integer overflow"},
+		{"-9223372036854775807 - 2", "integer overflow"},
+		{"4611686018427387904 * 2", "integer overflow"},
+		{"2 ** 63", "integer overflow"},
+		{"2 ** -1", "negative exponent in integer exponentiation"},
+		{"-(-9223372036854775807 - 1)", "integer overflow"},
+		{"(-9223372036854775807 - 1) / -1", "integer overflow"},
+		{"1 % 0", "integer divide by zero"},
+		{"1 << -1", "negative shift amount"},
+		{"1 << 63", "integer overflow"},
+		{"1 << 70", "integer overflow"},
+		{"1.5 % 1.0", "operator % not defined on float"},
+		{"1 + 1.5", "mismatched types int and float"},
+		{"'a' - 'b'", "char overflow"},
+		{`"a" * "b"`, "operator * not defined on string"},
+		{`1 in "a"`, "operator in not defined on int and string"},
+		{"true < false", "operator < not defined on bool"},
+		{"~1.5", "operator ~ not defined on float"},
+		{"1i < 2i", "operator < not defined on complex"},
+		{"1.0 + 2i", "mismatched types float and complex"},
+		{"not 1", "operator not not defined on int"},
+		{"[1, 2][2]", "index out of range [2] with length 2"},
+		{`"ab"[-1]`, "index out of range [-1] with length 2"},
+		{"[1, 2][1:3]", "slice bounds out of range [1:3:2] with capacity 2"},
+		{"[1] + (1,)", "mismatched types list and tuple"},
+		{"range(1, 2, 0)", "range step must not be zero"},
+		{"x = 1; x()", "cannot call non-function int"},
+		{"fn f(x) {}; f()", "not enough arguments in call to f"},
+		{"fn f() {}; f(1)", "too many arguments in call to f"},
+		{"fn(x) {}(y=1)", "unknown parameter y in call to function literal"},
+		{"fn f(x) {}; f(1, x=2)", "duplicate argument for parameter x in call to f"},
+		{"fn f(x) { return 1 / x }; f(0)", "integer divide by zero"},
+		{"a, b = [1, 2, 3]", "cannot unpack 3 elements into 2 variables"},
+		{"for a, b in [1] {}", "cannot unpack int into 2 variables"},
+		{"for x in 1 {}", "cannot iterate over int"},
+		{"x = 1; print(x...)", "cannot use ... with int"},
+		{"len(1)", "invalid argument: int for len"},
+	}
+
+	for _, test := range tests {
+		_, err := Eval(parseFile(t, test.input), object.NewEnvironment())
+		require.EqualError(t, err, test.err, test.input)
+	}
+}
+
+func expectEval(t *testing.T, input string) object.Object {
+	obj, err := Eval(parseFile(t, input), object.NewEnvironment())
+	require.NoError(t, err)
+
+	return obj
+}
+
+func parseFile(t *testing.T, input string) *ast.File {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", input, parser.DeclarationErrors)
+	require.NoError(t, err)
 
-	fmt.Println(obj)
+	return f
 }
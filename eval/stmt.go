@@ -0,0 +1,289 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/object"
+	"github.com/capnspacehook/rose/token"
+)
+
+// A branch is the error of evaluating a break, continue or
+// fallthrough statement. It is passed up to the statement that
+// control is transferred to.
+type branch struct {
+	pos token.Pos
+	tok token.Token
+}
+
+func (b *branch) Error() string {
+	return fmt.Sprintf("%s statement out of place", b.tok)
+}
+
+// A returnValue is the error of evaluating a return statement. It
+// is passed up to the call of the enclosing function.
+type returnValue struct {
+	pos token.Pos
+	val object.Object // nil if no values are returned
+}
+
+func (r *returnValue) Error() string {
+	return "return is not in a function"
+}
+
+// evalBlock evaluates the statements list in a new environment
+// nested in env.
+func evalBlock(list []ast.Stmt, env *object.Environment) error {
+	_, err := evalStmts(list, object.NewEnclosedEnvironment(env))
+	return err
+}
+
+// evalLoopBody evaluates the body of a loop. It reports whether
+// the loop was ended by a break statement.
+func evalLoopBody(body *ast.BlockStmt, env *object.Environment) (bool, error) {
+	err := evalBlock(body.List, env)
+	if b, isBranch := err.(*branch); isBranch {
+		switch b.tok {
+		case token.BREAK:
+			return true, nil
+		case token.CONTINUE:
+			return false, nil
+		}
+	}
+
+	return false, err
+}
+
+func evalReturnStmt(s *ast.ReturnStmt, env *object.Environment) error {
+	vals, err := evalExprs(s.Results, env)
+	if err != nil {
+		return err
+	}
+
+	ret := &returnValue{pos: s.Pos()}
+	switch len(vals) {
+	case 0:
+	case 1:
+		ret.val = vals[0]
+	default:
+		// multiple values are returned as a tuple
+		ret.val = &object.Tuple{Elements: vals}
+	}
+	return ret
+}
+
+func evalIfStmt(s *ast.IfStmt, env *object.Environment) error {
+	env = object.NewEnclosedEnvironment(env)
+	if s.Init != nil {
+		if _, err := Eval(s.Init, env); err != nil {
+			return err
+		}
+	}
+
+	cond, err := Eval(s.Cond, env)
+	if err != nil {
+		return err
+	}
+	if cond.Truthy() {
+		return evalBlock(s.Body.List, env)
+	}
+	if s.Else != nil {
+		_, err = Eval(s.Else, env)
+	}
+
+	return err
+}
+
+// evalSwitchStmt evaluates the expression switch s. The body of the
+// first case clause with an expression equal to the tag, or else of
+// the default clause, is evaluated. A switch without a tag switches
+// on true.
+func evalSwitchStmt(s *ast.SwitchStmt, env *object.Environment) error {
+	env = object.NewEnclosedEnvironment(env)
+	if s.Init != nil {
+		if _, err := Eval(s.Init, env); err != nil {
+			return err
+		}
+	}
+
+	var tag object.Object = TRUE
+	if s.Tag != nil {
+		var err error
+		if tag, err = Eval(s.Tag, env); err != nil {
+			return err
+		}
+	}
+
+	clauses := s.Body.List
+	match := -1
+	for i := 0; i < len(clauses) && match < 0; i++ {
+		clause := clauses[i].(*ast.CaseClause)
+		if clause.List == nil {
+			continue
+		}
+		for _, e := range clause.List {
+			val, err := Eval(e, env)
+			if err != nil {
+				return err
+			}
+			if tag.Equals(val) {
+				match = i
+				break
+			}
+		}
+	}
+	if match < 0 {
+		for i, clause := range clauses {
+			if clause.(*ast.CaseClause).List == nil {
+				match = i
+			}
+		}
+		if match < 0 {
+			return nil
+		}
+	}
+
+	for i := match; i < len(clauses); i++ {
+		err := evalBlock(clauses[i].(*ast.CaseClause).Body, env)
+		if b, isBranch := err.(*branch); isBranch {
+			switch b.tok {
+			case token.FALLTHROUGH:
+				continue
+			case token.BREAK:
+				return nil
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// evalForStmt evaluates the for statement s. The else branch is
+// evaluated if the condition is false before the first iteration.
+func evalForStmt(s *ast.ForStmt, env *object.Environment) error {
+	env = object.NewEnclosedEnvironment(env)
+	if s.Init != nil {
+		if _, err := Eval(s.Init, env); err != nil {
+			return err
+		}
+	}
+
+	for first := true; ; first = false {
+		if s.Cond != nil {
+			cond, err := Eval(s.Cond, env)
+			if err != nil {
+				return err
+			}
+			if !cond.Truthy() {
+				if first && s.Else != nil {
+					return evalBlock(s.Else.List, env)
+				}
+				return nil
+			}
+		}
+
+		done, err := evalLoopBody(s.Body, env)
+		if done || err != nil {
+			return err
+		}
+		if s.Post != nil {
+			if _, err := Eval(s.Post, env); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// evalForInStmt evaluates the for in statement s. Each element is
+// bound to the iteration variables in a new environment, unpacked
+// if there are several variables. The else branch is evaluated if
+// no element passes the filter.
+func evalForInStmt(s *ast.ForInStmt, env *object.Environment) error {
+	x, err := Eval(s.X, env)
+	if err != nil {
+		return err
+	}
+	elems, err := iterElems(s.X.Pos(), x, len(s.Vars))
+	if err != nil {
+		return err
+	}
+
+	empty := true
+	for _, elem := range elems {
+		iterEnv := object.NewEnclosedEnvironment(env)
+		vals := []object.Object{elem}
+		if len(s.Vars) > 1 {
+			if vals, err = unpack(s.X.Pos(), elem, len(s.Vars)); err != nil {
+				return err
+			}
+		}
+		for i, v := range s.Vars {
+			if v.Name != "_" {
+				iterEnv.Set(v.Name, vals[i])
+			}
+		}
+
+		if s.Filter != nil {
+			ok, err := Eval(s.Filter, iterEnv)
+			if err != nil {
+				return err
+			}
+			if !ok.Truthy() {
+				continue
+			}
+		}
+		empty = false
+
+		done, err := evalLoopBody(s.Body, iterEnv)
+		if done || err != nil {
+			return err
+		}
+	}
+	if empty && s.Else != nil {
+		return evalBlock(s.Else.List, env)
+	}
+
+	return nil
+}
+
+// iterElems returns the elements of x that a for in statement with
+// n variables iterates over. A string is iterated over by char, and
+// a map by key, or by key and value if there are two variables.
+func iterElems(pos token.Pos, x object.Object, n int) ([]object.Object, error) {
+	switch x := x.(type) {
+	case *object.Map:
+		if n == 2 {
+			elems := make([]object.Object, len(x.Keys))
+			for i, key := range x.Keys {
+				elems[i] = &object.Tuple{Elements: []object.Object{key, x.Values[i]}}
+			}
+			return elems, nil
+		}
+	case object.String:
+		var elems []object.Object
+		for _, r := range string(x) {
+			elems = append(elems, object.Char(r))
+		}
+		return elems, nil
+	}
+	if c, isContainer := x.(object.Container); isContainer {
+		// changes to x do not affect the iteration
+		return append([]object.Object(nil), c.Elems()...), nil
+	}
+
+	return nil, newError(pos, "cannot iterate over %s", x.Type())
+}
+
+// unpack returns the n elements of the container x.
+func unpack(pos token.Pos, x object.Object, n int) ([]object.Object, error) {
+	c, ok := x.(object.Container)
+	if !ok {
+		return nil, newError(pos, "cannot unpack %s into %d variables", x.Type(), n)
+	}
+	if c.Len() != n {
+		return nil, newError(pos, "cannot unpack %d elements into %d variables", c.Len(), n)
+	}
+
+	return c.Elems(), nil
+}
@@ -2,7 +2,4 @@ module github.com/capnspacehook/rose
 
 go 1.15
 
-require (
-	github.com/capnspacehook/pretty v0.2.3
-	github.com/stretchr/testify v1.6.1
-)
+require github.com/stretchr/testify v1.6.1
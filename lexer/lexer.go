@@ -1,22 +1,39 @@
+// Package lexer implements a scanner for Rose source text.
+// It takes a []byte as source which can then be tokenized
+// through repeated calls to the Lex method.
 package lexer
 
 import (
 	"fmt"
-	"io"
 	"strings"
-	"text/scanner"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/capnspacehook/rose/token"
 )
 
+// A Lexer holds the scanner's internal state while processing
+// a given text. It can be allocated as part of another data
+// structure but must be initialized via Init before use.
 type Lexer struct {
-	file       *token.File  // source file handle
-	errh       ErrorHandler // error reporting
-	insertSemi bool         // insert a semicolon before next newline
+	// immutable state
+	file         *token.File  // source file handle
+	src          []byte       // source
+	errh         ErrorHandler // error reporting; or nil
+	scanComments bool         // if set, comments are returned as COMMENT tokens
 
-	strBuf  strings.Builder
-	scanner scanner.Scanner // scanner that does much of the heavy lifting
+	// scanning state
+	ch         rune // current character
+	offset     int  // character offset
+	rdOffset   int  // reading offset (position after current character)
+	insertSemi bool // insert a semicolon before next newline
+
+	// interp holds the brace nesting level of each string
+	// interpolation being scanned, innermost last
+	interp []int
+
+	// public state - ok to modify
+	ErrorCount int // number of errors encountered
 }
 
 // An ErrorHandler may be provided to Lexer.Init. If a syntax error is
@@ -25,279 +42,925 @@ type Lexer struct {
 // the offending token.
 type ErrorHandler func(pos token.Position, msg string)
 
-func (lx *Lexer) Init(file *token.File, src io.Reader, errh ErrorHandler, scanComments bool) {
-	// explicitly initialize all fields since a scanner may be reused
+const bom = 0xFEFF // byte order mark, only permitted as very first character
+
+// Read the next Unicode char into lx.ch.
+// lx.ch < 0 means end-of-file.
+func (lx *Lexer) next() {
+	if lx.rdOffset < len(lx.src) {
+		lx.offset = lx.rdOffset
+		if lx.ch == '\n' {
+			lx.file.AddLine(lx.offset)
+		}
+		r, w := rune(lx.src[lx.rdOffset]), 1
+		switch {
+		case r == 0:
+			lx.error(lx.offset, "illegal character NUL")
+		case r >= utf8.RuneSelf:
+			// not ASCII
+			r, w = utf8.DecodeRune(lx.src[lx.rdOffset:])
+			if r == utf8.RuneError && w == 1 {
+				lx.error(lx.offset, "illegal UTF-8 encoding")
+			} else if r == bom && lx.offset > 0 {
+				lx.error(lx.offset, "illegal byte order mark")
+			}
+		}
+		lx.rdOffset += w
+		lx.ch = r
+	} else {
+		lx.offset = len(lx.src)
+		if lx.ch == '\n' {
+			lx.file.AddLine(lx.offset)
+		}
+		lx.ch = -1 // eof
+	}
+}
+
+// peek returns the byte following the most recently read character without
+// advancing the lexer. If the lexer is at EOF, peek returns 0.
+func (lx *Lexer) peek() byte {
+	if lx.rdOffset < len(lx.src) {
+		return lx.src[lx.rdOffset]
+	}
+	return 0
+}
+
+// Init prepares the lexer lx to tokenize the text src by setting the
+// lexer at the beginning of src. The lexer uses the file set file
+// for position information and it adds line information for each
+// line. It is ok to re-use the same file when re-scanning the same
+// file as line information which is already present is ignored.
+// Init causes a panic if the file size does not match the src size.
+//
+// Calls to Lex will invoke the error handler errh if they encounter
+// a syntax error and errh is not nil. Also, for each error encountered,
+// the Lexer field ErrorCount is incremented by one. If scanComments is
+// set, comments are returned as COMMENT tokens, otherwise they are
+// skipped.
+func (lx *Lexer) Init(file *token.File, src []byte, errh ErrorHandler, scanComments bool) {
+	// explicitly initialize all fields since a lexer may be reused
+	if file.Size() != len(src) {
+		panic(fmt.Sprintf("file size (%d) does not match src len (%d)", file.Size(), len(src)))
+	}
 	lx.file = file
+	lx.src = src
+	lx.errh = errh
+	lx.scanComments = scanComments
+
+	lx.ch = ' '
+	lx.offset = 0
+	lx.rdOffset = 0
 	lx.insertSemi = false
+	lx.interp = lx.interp[:0]
+	lx.ErrorCount = 0
 
-	lx.scanner.Init(src)
-	lx.scanner.Filename = file.Name()
-	lx.scanner.Mode = scanner.ScanInts | scanner.ScanFloats | scanner.ScanChars | scanner.ScanStrings | scanner.ScanRawStrings
-	lx.scanner.Whitespace = 1<<'\t' | 1<<'\r' | 1<<' '
-	if errh != nil {
-		lx.scanner.Error = func(s *scanner.Scanner, msg string) {
-			errh(token.Position(s.Pos()), msg)
-		}
+	lx.next()
+	if lx.ch == bom {
+		lx.next() // ignore BOM at file beginning
 	}
 }
 
-func (lx *Lexer) Lex() (pos token.Pos, tok token.Token, lit string) {
-lexAgain:
-	ch := lx.scanner.Scan()
-	pos = lx.currentPos()
+func (lx *Lexer) error(offs int, msg string) {
+	if lx.errh != nil {
+		lx.errh(lx.file.Position(lx.file.Pos(offs)), msg)
+	}
+	lx.ErrorCount++
+}
 
-	insertSemi := false
-	switch ch {
-	case scanner.Int:
-		insertSemi = true
-		tok = token.INT
-		lit = lx.scanner.TokenText()
-	case scanner.Float:
-		insertSemi = true
-		tok = token.FLOAT
-		lit = lx.scanner.TokenText()
-	case scanner.Char:
-		insertSemi = true
-		tok = token.CHAR
-		lit = lx.scanner.TokenText()
-	case scanner.String: // TODO: (capnspacehook) handle string expressions
-		insertSemi = true
-		tok = token.STRING
-		lit = lx.scanner.TokenText()
-	case scanner.RawString:
-		insertSemi = true
-		tok = token.RAW_STRING
-		lit = lx.scanner.TokenText()
-	case '+':
-		switch lx.scanner.Peek() {
-		case '+':
-			insertSemi = true
-			lx.scanner.Next()
-			tok = token.INC
-		case '=':
-			lx.scanner.Next()
-			tok = token.ADD_ASSIGN
-		default:
-			tok = token.ADD
+func (lx *Lexer) errorf(offs int, format string, args ...interface{}) {
+	lx.error(offs, fmt.Sprintf(format, args...))
+}
+
+func (lx *Lexer) scanComment() string {
+	// initial '/' already consumed; lx.ch == '/' || lx.ch == '*'
+	offs := lx.offset - 1 // position of initial '/'
+	numCR := 0
+
+	if lx.ch == '/' {
+		//-style comment
+		// (the final '\n' is not considered part of the comment)
+		lx.next()
+		for lx.ch != '\n' && lx.ch >= 0 {
+			if lx.ch == '\r' {
+				numCR++
+			}
+			lx.next()
 		}
-	case '-':
-		switch lx.scanner.Peek() {
-		case '-':
-			insertSemi = true
-			lx.scanner.Next()
-			tok = token.DEC
-		case '=':
-			lx.scanner.Next()
-			tok = token.SUB_ASSIGN
-		default:
-			tok = token.SUB
+		goto exit
+	}
+
+	/*-style comment */
+	lx.next()
+	for lx.ch >= 0 {
+		ch := lx.ch
+		if ch == '\r' {
+			numCR++
 		}
-	case '*':
-		switch lx.scanner.Peek() {
-		case '*':
-			lx.scanner.Next()
-			if lx.scanner.Peek() == '=' {
-				lx.scanner.Next()
-				tok = token.EXP_ASSIGN
-			} else {
-				tok = token.EXP
+		lx.next()
+		if ch == '*' && lx.ch == '/' {
+			lx.next()
+			goto exit
+		}
+	}
+
+	lx.error(offs, "comment not terminated")
+
+exit:
+	lit := lx.src[offs:lx.offset]
+	if numCR > 0 {
+		lit = stripCR(lit, lit[1] == '*')
+	}
+	return string(lit)
+}
+
+// findLineEnd reports whether the comment starting at the already
+// consumed '/', and any comments directly following it, end the
+// current line. The lexer state is left unchanged.
+func (lx *Lexer) findLineEnd() bool {
+	// initial '/' already consumed
+
+	defer func(offs int) {
+		// reset lexer state to where it was upon calling findLineEnd
+		lx.ch = '/'
+		lx.offset = offs
+		lx.rdOffset = offs + 1
+		lx.next() // consume initial '/' again
+	}(lx.offset - 1)
+
+	// read ahead until a newline, EOF, or non-comment token is found
+	for lx.ch == '/' || lx.ch == '*' {
+		if lx.ch == '/' {
+			//-style comment always contains a newline
+			return true
+		}
+		/*-style comment: look for newline */
+		lx.next()
+		for lx.ch >= 0 {
+			ch := lx.ch
+			if ch == '\n' {
+				return true
+			}
+			lx.next()
+			if ch == '*' && lx.ch == '/' {
+				lx.next()
+				break
 			}
-		case '=':
-			lx.scanner.Next()
-			tok = token.MUL_ASSIGN
-		default:
-			tok = token.MUL
 		}
-	case '/':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.QUO_ASSIGN
-		default:
-			tok = token.QUO
+		lx.skipWhitespace() // lx.insertSemi is set
+		if lx.ch < 0 || lx.ch == '\n' {
+			return true
 		}
-	case '%':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.REM_ASSIGN
-		default:
-			tok = token.REM
+		if lx.ch != '/' {
+			// non-comment token
+			return false
 		}
-	case '&':
-		switch lx.scanner.Peek() {
-		case '^':
-			lx.scanner.Next()
-			if lx.scanner.Peek() == '=' {
-				lx.scanner.Next()
-				tok = token.AND_NOT_ASSIGN
-			} else {
-				tok = token.AND_NOT
+		lx.next() // consume '/'
+	}
+
+	return false
+}
+
+func lower(ch rune) rune     { return ('a' - 'A') | ch } // returns lower-case ch iff ch is ASCII letter
+func isDecimal(ch rune) bool { return '0' <= ch && ch <= '9' }
+func isHex(ch rune) bool     { return '0' <= ch && ch <= '9' || 'a' <= lower(ch) && lower(ch) <= 'f' }
+
+func isLetter(ch rune) bool {
+	return 'a' <= lower(ch) && lower(ch) <= 'z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
+	return isDecimal(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
+
+func (lx *Lexer) scanIdentifier() string {
+	offs := lx.offset
+	for isLetter(lx.ch) || isDigit(lx.ch) {
+		lx.next()
+	}
+	return string(lx.src[offs:lx.offset])
+}
+
+// digits accepts the sequence { digit | '_' }. If base <= 10,
+// digits accepts any decimal digit but records the offset (relative
+// to the source start) of the first invalid digit >= base in *invalid
+// if *invalid < 0. digits returns a bitset describing whether the
+// sequence contained digits (bit 0 is set), or separators '_' (bit 1
+// is set).
+func (lx *Lexer) digits(base int, invalid *int) (digsep int) {
+	if base <= 10 {
+		max := rune('0' + base)
+		for isDecimal(lx.ch) || lx.ch == '_' {
+			ds := 1
+			if lx.ch == '_' {
+				ds = 2
+			} else if lx.ch >= max && *invalid < 0 {
+				*invalid = lx.offset // record invalid rune offset
 			}
-		case '=':
-			lx.scanner.Next()
-			tok = token.AND_ASSIGN
-		default:
-			tok = token.AND
+			digsep |= ds
+			lx.next()
 		}
-	case '|':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.OR_ASSIGN
-		default:
-			tok = token.OR
+	} else {
+		for isHex(lx.ch) || lx.ch == '_' {
+			ds := 1
+			if lx.ch == '_' {
+				ds = 2
+			}
+			digsep |= ds
+			lx.next()
 		}
-	case '^':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.XOR_ASSIGN
-		default:
-			tok = token.XOR
+	}
+	return
+}
+
+// scanNumber scans an integer, floating-point or imaginary literal
+// starting with the current character, a decimal digit or '.'.
+func (lx *Lexer) scanNumber() (token.Token, string) {
+	offs := lx.offset
+	tok := token.ILLEGAL
+
+	base := 10        // number base
+	prefix := rune(0) // one of 0 (decimal), '0' (0-octal), 'x', 'o', or 'b'
+	digsep := 0       // bit 0: digit present, bit 1: '_' present
+	invalid := -1     // index of invalid digit in literal, or < 0
+
+	// integer part
+	if lx.ch != '.' {
+		tok = token.INT
+		if lx.ch == '0' {
+			lx.next()
+			switch lower(lx.ch) {
+			case 'x':
+				lx.next()
+				base, prefix = 16, 'x'
+			case 'o':
+				lx.next()
+				base, prefix = 8, 'o'
+			case 'b':
+				lx.next()
+				base, prefix = 2, 'b'
+			default:
+				base, prefix = 8, '0'
+				digsep = 1 // leading 0
+			}
 		}
-	case '~':
-		tok = token.INVT
-	case '<':
-		switch lx.scanner.Peek() {
-		case '<':
-			lx.scanner.Next()
-			if lx.scanner.Peek() == '=' {
-				lx.scanner.Next()
-				tok = token.SHL_ASSIGN
-			} else {
-				tok = token.SHL
+		digsep |= lx.digits(base, &invalid)
+	}
+
+	// fractional part
+	if lx.ch == '.' {
+		tok = token.FLOAT
+		if prefix == 'o' || prefix == 'b' {
+			lx.error(lx.offset, "invalid radix point in "+litname(prefix))
+		}
+		lx.next()
+		digsep |= lx.digits(base, &invalid)
+	}
+
+	if digsep&1 == 0 {
+		lx.error(lx.offset, litname(prefix)+" has no digits")
+	}
+
+	// exponent
+	if e := lower(lx.ch); e == 'e' || e == 'p' {
+		switch {
+		case e == 'e' && prefix != 0 && prefix != '0':
+			lx.errorf(lx.offset, "%q exponent requires decimal mantissa", lx.ch)
+		case e == 'p' && prefix != 'x':
+			lx.errorf(lx.offset, "%q exponent requires hexadecimal mantissa", lx.ch)
+		}
+		lx.next()
+		tok = token.FLOAT
+		if lx.ch == '+' || lx.ch == '-' {
+			lx.next()
+		}
+		ds := lx.digits(10, nil)
+		digsep |= ds
+		if ds&1 == 0 {
+			lx.error(lx.offset, "exponent has no digits")
+		}
+	} else if prefix == 'x' && tok == token.FLOAT {
+		lx.error(lx.offset, "hexadecimal mantissa requires a 'p' exponent")
+	}
+
+	// suffix 'i'
+	if lx.ch == 'i' {
+		tok = token.IMAG
+		lx.next()
+	}
+
+	lit := string(lx.src[offs:lx.offset])
+	if tok == token.INT && invalid >= 0 {
+		lx.errorf(invalid, "invalid digit %q in %s", lit[invalid-offs], litname(prefix))
+	}
+	if digsep&2 != 0 {
+		if i := invalidSep(lit); i >= 0 {
+			lx.error(offs+i, "'_' must separate successive digits")
+		}
+	}
+
+	return tok, lit
+}
+
+func litname(prefix rune) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
+	}
+	return "decimal literal"
+}
+
+// invalidSep returns the index of the first invalid separator in x, or -1.
+func invalidSep(x string) int {
+	x1 := ' ' // prefix char, we only care if it's 'x'
+	d := '.'  // digit, one of '_', '0' (a digit), or '.' (anything else)
+	i := 0
+
+	// a prefix counts as a digit
+	if len(x) >= 2 && x[0] == '0' {
+		x1 = lower(rune(x[1]))
+		if x1 == 'x' || x1 == 'o' || x1 == 'b' {
+			d = '0'
+			i = 2
+		}
+	}
+
+	// mantissa and exponent
+	for ; i < len(x); i++ {
+		p := d // previous digit
+		d = rune(x[i])
+		switch {
+		case d == '_':
+			if p != '0' {
+				return i
 			}
-		case '-':
-			lx.scanner.Next()
-			tok = token.ARROW
-		case '=':
-			lx.scanner.Next()
-			tok = token.LEQ
+		case isDecimal(d) || x1 == 'x' && isHex(d):
+			d = '0'
 		default:
-			tok = token.LSS
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
 		}
-	case '>':
-		switch lx.scanner.Peek() {
-		case '>':
-			lx.scanner.Next()
-			if lx.scanner.Peek() == '=' {
-				lx.scanner.Next()
-				tok = token.SHR_ASSIGN
+	}
+	if d == '_' {
+		return len(x) - 1
+	}
+
+	return -1
+}
+
+func digitVal(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= lower(ch) && lower(ch) <= 'f':
+		return int(lower(ch) - 'a' + 10)
+	}
+	return 16 // larger than any legal digit val
+}
+
+// scanEscape parses an escape sequence where quote is the accepted
+// escaped quote. In case of a syntax error, it stops at the offending
+// character (without consuming it) and returns false. Otherwise
+// it returns true.
+func (lx *Lexer) scanEscape(quote rune) bool {
+	offs := lx.offset
+
+	var n int
+	var base, max uint32
+	switch lx.ch {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', quote:
+		lx.next()
+		return true
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base, max = 3, 8, 255
+	case 'x':
+		lx.next()
+		n, base, max = 2, 16, 255
+	case 'u':
+		lx.next()
+		n, base, max = 4, 16, unicode.MaxRune
+	case 'U':
+		lx.next()
+		n, base, max = 8, 16, unicode.MaxRune
+	default:
+		if lx.ch != '\n' && lx.ch >= 0 {
+			lx.error(offs, "unknown escape sequence")
+		}
+		return false
+	}
+
+	var x uint32
+	for n > 0 {
+		d := uint32(digitVal(lx.ch))
+		if d >= base {
+			if lx.ch != '\n' && lx.ch >= 0 && lx.ch != quote {
+				lx.errorf(lx.offset, "illegal character %#U in escape sequence", lx.ch)
 			} else {
-				tok = token.SHR
+				lx.error(lx.offset, "escape sequence not terminated")
 			}
-		case '=':
-			lx.scanner.Next()
-			tok = token.GEQ
-		default:
-			tok = token.GTR
+			return false
 		}
-	case '=':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.EQL
-		default:
-			tok = token.ASSIGN
-		}
-	case '(':
-		tok = token.LPAREN
-	case '[':
-		tok = token.LBRACK
-	case '{':
-		tok = token.LBRACE
-	case ',':
-		tok = token.COMMA
-	case '.':
-		switch lx.scanner.Peek() {
-		case '.':
-			lx.scanner.Next()
-			if lx.scanner.Peek() == '.' {
-				lx.scanner.Next()
-				tok = token.ELLIPSIS
+		x = x*base + d
+		lx.next()
+		n--
+	}
+
+	if x > max || 0xD800 <= x && x < 0xE000 {
+		lx.error(offs, "escape sequence is invalid Unicode code point")
+		return false
+	}
+
+	return true
+}
+
+func (lx *Lexer) scanChar() string {
+	// '\'' opening already consumed
+	offs := lx.offset - 1
+
+	valid := true
+	n := 0
+	for {
+		ch := lx.ch
+		if ch == '\n' || ch < 0 {
+			// only report error if we don't have one already
+			if valid {
+				lx.error(offs, "char literal not terminated")
+				valid = false
 			}
-		default:
-			tok = token.PERIOD
+			break
 		}
-	case ')':
-		insertSemi = true
-		tok = token.RPAREN
-	case ']':
-		insertSemi = true
-		tok = token.RBRACK
-	case '}':
-		insertSemi = true
-		tok = token.RBRACE
-	case ';':
-		insertSemi = false
-		tok = token.SEMI
-		lit = ";"
-	case ':':
-		tok = token.COLON
-	case '?':
-		tok = token.QUES
-	case '!':
-		switch lx.scanner.Peek() {
-		case '=':
-			lx.scanner.Next()
-			tok = token.NEQ
-		default:
-			tok = token.EXCLM
+		lx.next()
+		if ch == '\'' {
+			break
 		}
-	case '\n':
-		if lx.insertSemi {
-			lx.insertSemi = false
-			return pos, token.SEMI, "\n"
+		n++
+		if ch == '\\' {
+			if !lx.scanEscape('\'') {
+				valid = false
+			}
+			// continue to read to closing quote
 		}
+	}
+
+	if valid && n != 1 {
+		lx.error(offs, "illegal char literal")
+	}
+
+	return string(lx.src[offs:lx.offset])
+}
+
+// scanString scans a string literal or, if the literal contains
+// interpolated expressions, the segment of it that starts with the
+// already consumed quote character up to and including the next
+// unescaped '{' or the closing '"'. A quote of '}' denotes the end of
+// an interpolated expression. Literal braces are written as "{{" and
+// "}}".
+func (lx *Lexer) scanString(quote rune) (tok token.Token, lit string) {
+	// opening quote already consumed
+	offs := lx.offset - 1
+	if quote == '}' {
+		lx.interp = lx.interp[:len(lx.interp)-1]
+	}
 
-		goto lexAgain
-	case scanner.EOF:
-		if lx.insertSemi {
-			lx.insertSemi = false
-			return pos, token.SEMI, ""
+	for {
+		ch := lx.ch
+		if ch == '\n' || ch < 0 {
+			lx.error(lx.offset, "literal not terminated")
+			break
 		}
+		chOffs := lx.offset
+		lx.next()
 
-		tok = token.EOF
-	default:
-		if lx.isIdentRune(ch, true) {
-			insertSemi = true
-			lit = lx.scanIdentifier(ch)
-			if len(lit) > 1 {
-				// keywords are longer than one letter - avoid lookup otherwise
-				switch tok = token.Lookup(lit); tok {
-				case token.IDENT, token.BREAK, token.CONTINUE, token.FALLTHROUGH, token.RETURN:
-					insertSemi = true
-				}
-			} else {
-				insertSemi = true
-				tok = token.IDENT
+		switch ch {
+		case '"':
+			lit = string(lx.src[offs:lx.offset])
+			if quote == '}' {
+				return token.STRING_TAIL, lit
 			}
-		} else {
-			lx.scanner.Error(&lx.scanner, fmt.Sprintf("illegal character %#U", ch))
+			return token.STRING, lit
+		case '{':
+			if lx.ch == '{' {
+				lx.next()
+				continue
+			}
+			lx.interp = append(lx.interp, 0)
+			lit = string(lx.src[offs:lx.offset])
+			if quote == '}' {
+				return token.STRING_MID, lit
+			}
+			return token.STRING_HEAD, lit
+		case '}':
+			if lx.ch == '}' {
+				lx.next()
+				continue
+			}
+			lx.error(chOffs, "unbalanced '}' in string literal; use '}}' for a literal brace")
+		case '\\':
+			lx.scanEscape('"')
 		}
 	}
 
-	lx.insertSemi = insertSemi
+	// unterminated literal; any enclosing interpolations
+	// cannot be terminated on this line either
+	lx.interp = lx.interp[:0]
+	lit = string(lx.src[offs:lx.offset])
+	if quote == '}' {
+		return token.STRING_TAIL, lit
+	}
+	return token.STRING, lit
+}
 
-	return
+var braceUnescaper = strings.NewReplacer("{{", "{", "}}", "}")
+
+// UnescapeBraces returns the text lit of a string literal or string
+// segment with its escaped braces "{{" and "}}" replaced by the
+// literal braces they denote.
+func UnescapeBraces(lit string) string {
+	return braceUnescaper.Replace(lit)
 }
 
-func (lx *Lexer) currentPos() token.Pos {
-	return lx.file.Pos(lx.scanner.Offset)
+func stripCR(b []byte, comment bool) []byte {
+	c := make([]byte, len(b))
+	i := 0
+	for j, ch := range b {
+		// In a /*-style comment, don't strip \r from *\r/ (incl.
+		// sequences of \r from *\r\r...\r/) since the resulting
+		// */ would terminate the comment too early unless the \r
+		// is immediately following the opening /* in which case
+		// it's ok because /*/ is not closed yet.
+		if ch != '\r' || comment && i > len("/*") && c[i-1] == '*' && j+1 < len(b) && b[j+1] == '/' {
+			c[i] = ch
+			i++
+		}
+	}
+	return c[:i]
 }
 
-func (lx *Lexer) scanIdentifier(ch rune) string {
-	// we know the zero'th rune is OK; start scanning at the next one
-	lx.strBuf.WriteRune(ch)
-	for lx.isIdentRune(lx.scanner.Peek(), false) {
-		lx.strBuf.WriteRune(lx.scanner.Scan())
+func (lx *Lexer) scanRawString() string {
+	// '`' opening already consumed
+	offs := lx.offset - 1
+
+	hasCR := false
+	for {
+		ch := lx.ch
+		if ch < 0 {
+			lx.error(offs, "raw string literal not terminated")
+			break
+		}
+		lx.next()
+		if ch == '`' {
+			break
+		}
+		if ch == '\r' {
+			hasCR = true
+		}
+	}
+
+	lit := lx.src[offs:lx.offset]
+	if hasCR {
+		lit = stripCR(lit, false)
+	}
+
+	return string(lit)
+}
+
+// skipWhitespace skips blanks, and newlines unless a semicolon would
+// be inserted or a string interpolation is being scanned.
+func (lx *Lexer) skipWhitespace() {
+	for lx.ch == ' ' || lx.ch == '\t' || lx.ch == '\n' && !lx.insertSemi && len(lx.interp) == 0 || lx.ch == '\r' {
+		lx.next()
+	}
+}
+
+// skipLine skips all characters up to but not including the next newline.
+func (lx *Lexer) skipLine() {
+	for lx.ch != '\n' && lx.ch >= 0 {
+		lx.next()
 	}
-	defer lx.strBuf.Reset()
+}
 
-	return lx.strBuf.String()
+// Peek returns the next token that is not a comment without
+// advancing the lexer. Errors encountered while scanning it are
+// not reported.
+func (lx *Lexer) Peek() token.Token {
+	s := *lx
+	s.errh = nil
+	s.interp = append([]int(nil), lx.interp...)
+	for {
+		if _, tok, _ := s.Lex(); tok != token.COMMENT {
+			return tok
+		}
+	}
 }
 
-func (lx *Lexer) isIdentRune(ch rune, firstRune bool) bool {
-	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) && !firstRune
+// Lex scans the next token and returns the token position, the token,
+// and its literal string if applicable. The source end is indicated by
+// token.EOF.
+//
+// If the returned token is a literal (token.IDENT, token.INT, token.FLOAT,
+// token.IMAG, token.CHAR, token.STRING, token.RAW_STRING or a string
+// segment) or token.COMMENT, the literal string has the corresponding
+// value.
+//
+// If the returned token is token.SEMI, the corresponding literal string
+// is ";" if the semicolon was present in the source, and "\n" if the
+// semicolon was inserted because of a newline or at EOF.
+//
+// For more tolerant parsing, Lex will return a valid token if possible
+// even if a syntax error was encountered. Thus, even if the resulting
+// token sequence contains no illegal tokens, a client may not assume
+// that no error occurred. Instead it must check the lexer's ErrorCount
+// or the number of calls of the error handler, if there was one
+// installed.
+func (lx *Lexer) Lex() (pos token.Pos, tok token.Token, lit string) {
+lexAgain:
+	lx.skipWhitespace()
+
+	// current token start
+	pos = lx.file.Pos(lx.offset)
+
+	// determine token value
+	insertSemi := false
+	switch ch := lx.ch; {
+	case isLetter(ch):
+		lit = lx.scanIdentifier()
+		if len(lit) > 1 {
+			// keywords are longer than one letter - avoid lookup otherwise
+			tok = token.Lookup(lit)
+			switch tok {
+			case token.IDENT, token.BREAK, token.CONTINUE, token.FALLTHROUGH, token.RETURN:
+				insertSemi = true
+			}
+		} else {
+			insertSemi = true
+			tok = token.IDENT
+		}
+	case isDecimal(ch) || ch == '.' && isDecimal(rune(lx.peek())):
+		insertSemi = true
+		tok, lit = lx.scanNumber()
+	default:
+		lx.next() // always make progress
+		switch ch {
+		case -1:
+			if len(lx.interp) > 0 {
+				lx.error(lx.file.Offset(pos), "string interpolation not terminated")
+				lx.interp = lx.interp[:0]
+			}
+			if lx.insertSemi {
+				lx.insertSemi = false // EOF consumed
+				return pos, token.SEMI, ""
+			}
+			tok = token.EOF
+		case '\n':
+			// we only reach here if lx.insertSemi was
+			// set in the first place and exited early
+			// from lx.skipWhitespace(), or a string
+			// interpolation is not terminated
+			if len(lx.interp) > 0 {
+				lx.error(lx.file.Offset(pos), "string interpolation not terminated")
+				lx.interp = lx.interp[:0]
+			}
+			if lx.insertSemi {
+				lx.insertSemi = false // newline consumed
+				return pos, token.SEMI, "\n"
+			}
+			goto lexAgain
+		case '"':
+			tok, lit = lx.scanString(ch)
+			insertSemi = tok == token.STRING || tok == token.STRING_TAIL
+		case '\'':
+			insertSemi = true
+			tok = token.CHAR
+			lit = lx.scanChar()
+		case '`':
+			insertSemi = true
+			tok = token.RAW_STRING
+			lit = lx.scanRawString()
+		case '+':
+			switch lx.ch {
+			case '+':
+				insertSemi = true
+				lx.next()
+				tok = token.INC
+			case '=':
+				lx.next()
+				tok = token.ADD_ASSIGN
+			default:
+				tok = token.ADD
+			}
+		case '-':
+			switch lx.ch {
+			case '-':
+				insertSemi = true
+				lx.next()
+				tok = token.DEC
+			case '=':
+				lx.next()
+				tok = token.SUB_ASSIGN
+			default:
+				tok = token.SUB
+			}
+		case '*':
+			switch lx.ch {
+			case '*':
+				lx.next()
+				if lx.ch == '=' {
+					lx.next()
+					tok = token.EXP_ASSIGN
+				} else {
+					tok = token.EXP
+				}
+			case '=':
+				lx.next()
+				tok = token.MUL_ASSIGN
+			default:
+				tok = token.MUL
+			}
+		case '/':
+			if lx.ch == '/' || lx.ch == '*' {
+				// comment
+				if len(lx.interp) > 0 {
+					lx.error(lx.file.Offset(pos), "comment not allowed in string interpolation")
+					lx.skipLine()
+					lx.interp = lx.interp[:0]
+					goto lexAgain
+				}
+				if lx.insertSemi && lx.findLineEnd() {
+					// reset position to the beginning of the comment
+					lx.ch = '/'
+					lx.offset = lx.file.Offset(pos)
+					lx.rdOffset = lx.offset + 1
+					lx.insertSemi = false // newline consumed
+					return pos, token.SEMI, "\n"
+				}
+				comment := lx.scanComment()
+				if !lx.scanComments {
+					// skip comment
+					lx.insertSemi = false // newline consumed
+					goto lexAgain
+				}
+				tok = token.COMMENT
+				lit = comment
+			} else if lx.ch == '=' {
+				lx.next()
+				tok = token.QUO_ASSIGN
+			} else {
+				tok = token.QUO
+			}
+		case '%':
+			switch lx.ch {
+			case '=':
+				lx.next()
+				tok = token.REM_ASSIGN
+			default:
+				tok = token.REM
+			}
+		case '&':
+			switch lx.ch {
+			case '^':
+				lx.next()
+				if lx.ch == '=' {
+					lx.next()
+					tok = token.AND_NOT_ASSIGN
+				} else {
+					tok = token.AND_NOT
+				}
+			case '=':
+				lx.next()
+				tok = token.AND_ASSIGN
+			default:
+				tok = token.AND
+			}
+		case '|':
+			switch lx.ch {
+			case '=':
+				lx.next()
+				tok = token.OR_ASSIGN
+			default:
+				tok = token.OR
+			}
+		case '^':
+			switch lx.ch {
+			case '=':
+				lx.next()
+				tok = token.XOR_ASSIGN
+			default:
+				tok = token.XOR
+			}
+		case '~':
+			tok = token.INVT
+		case '<':
+			switch lx.ch {
+			case '<':
+				lx.next()
+				if lx.ch == '=' {
+					lx.next()
+					tok = token.SHL_ASSIGN
+				} else {
+					tok = token.SHL
+				}
+			case '-':
+				lx.next()
+				tok = token.ARROW
+			case '=':
+				lx.next()
+				tok = token.LEQ
+			default:
+				tok = token.LSS
+			}
+		case '>':
+			switch lx.ch {
+			case '>':
+				lx.next()
+				if lx.ch == '=' {
+					lx.next()
+					tok = token.SHR_ASSIGN
+				} else {
+					tok = token.SHR
+				}
+			case '=':
+				lx.next()
+				tok = token.GEQ
+			default:
+				tok = token.GTR
+			}
+		case '=':
+			switch lx.ch {
+			case '=':
+				lx.next()
+				tok = token.EQL
+			default:
+				tok = token.ASSIGN
+			}
+		case '(':
+			tok = token.LPAREN
+		case '[':
+			tok = token.LBRACK
+		case '{':
+			if n := len(lx.interp); n > 0 {
+				lx.interp[n-1]++
+			}
+			tok = token.LBRACE
+		case ',':
+			tok = token.COMMA
+		case '.':
+			// fractions starting with a '.' are handled by outer switch
+			tok = token.PERIOD
+			if lx.ch == '.' && lx.peek() == '.' {
+				lx.next()
+				lx.next() // consume last '.'
+				tok = token.ELLIPSIS
+			}
+		case ')':
+			insertSemi = true
+			tok = token.RPAREN
+		case ']':
+			insertSemi = true
+			tok = token.RBRACK
+		case '}':
+			if n := len(lx.interp); n > 0 {
+				if lx.interp[n-1] == 0 {
+					// end of an interpolated expression
+					tok, lit = lx.scanString(ch)
+					insertSemi = tok == token.STRING_TAIL
+					break
+				}
+				lx.interp[n-1]--
+			}
+			insertSemi = true
+			tok = token.RBRACE
+		case ';':
+			tok = token.SEMI
+			lit = ";"
+		case ':':
+			tok = token.COLON
+		case '?':
+			tok = token.QUES
+		case '!':
+			switch lx.ch {
+			case '=':
+				lx.next()
+				tok = token.NEQ
+			default:
+				tok = token.EXCLM
+			}
+		default:
+			// next reports unexpected BOMs - don't repeat
+			if ch != bom {
+				lx.errorf(lx.file.Offset(pos), "illegal character %#U", ch)
+			}
+			insertSemi = lx.insertSemi // preserve insertSemi info
+			tok = token.ILLEGAL
+			lit = string(ch)
+		}
+	}
+
+	lx.insertSemi = insertSemi
+
+	return
 }
@@ -1,10 +1,11 @@
 package lexer
 
 import (
+	"fmt"
 	"strings"
 	"testing"
-	"text/scanner"
 
+	"github.com/capnspacehook/rose/diagnostics"
 	"github.com/capnspacehook/rose/token"
 )
 
@@ -25,6 +26,11 @@ if 5 < 9 {
 
 [1, 2, 3]
 {1: "one", 2: "two"};
+ok = a and
+	b or
+	not c
+x = y in
+	ys
 l[2:5]
 `
 
@@ -103,6 +109,21 @@ l[2:5]
 		{token.STRING, `"two"`},
 		{token.RBRACE, ""},
 		{token.SEMI, ";"},
+		{token.IDENT, "ok"},
+		{token.ASSIGN, ""},
+		{token.IDENT, "a"},
+		{token.LAND, "and"},
+		{token.IDENT, "b"},
+		{token.LOR, "or"},
+		{token.NOT, "not"},
+		{token.IDENT, "c"},
+		{token.SEMI, "\n"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, ""},
+		{token.IDENT, "y"},
+		{token.IN, "in"},
+		{token.IDENT, "ys"},
+		{token.SEMI, "\n"},
 		{token.IDENT, "l"},
 		{token.LBRACK, ""},
 		{token.INT, "2"},
@@ -114,11 +135,11 @@ l[2:5]
 	}
 
 	var l Lexer
-	var errs ErrorList
+	var errs diagnostics.List
 	fs := token.NewFileSet()
-	eh := func(pos token.Position, msg string) { errs.Add(scanner.Position(pos), msg) }
+	eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
 
-	l.Init(fs.AddFile("", -1, len(input)), strings.NewReader(input), eh, true)
+	l.Init(fs.AddFile("", -1, len(input)), []byte(input), eh, true)
 	for _, test := range tests {
 		pos, tok, lit := l.Lex()
 		if tok != test.tok {
@@ -134,3 +155,383 @@ l[2:5]
 		t.Error(err)
 	}
 }
+
+func TestLexInterpolatedStrings(t *testing.T) {
+	input := `"Hi {name}, {{braces}} {m["k"]} {f("{x}")}"
+"{ {1} }"
+`
+
+	tests := []struct {
+		tok token.Token
+		lit string
+	}{
+		{token.STRING_HEAD, `"Hi {`},
+		{token.IDENT, "name"},
+		{token.STRING_MID, `}, {{braces}} {`},
+		{token.IDENT, "m"},
+		{token.LBRACK, ""},
+		{token.STRING, `"k"`},
+		{token.RBRACK, ""},
+		{token.STRING_MID, `} {`},
+		{token.IDENT, "f"},
+		{token.LPAREN, ""},
+		{token.STRING_HEAD, `"{`},
+		{token.IDENT, "x"},
+		{token.STRING_TAIL, `}"`},
+		{token.RPAREN, ""},
+		{token.STRING_TAIL, `}"`},
+		{token.SEMI, "\n"},
+		{token.STRING_HEAD, `"{`},
+		{token.LBRACE, ""},
+		{token.INT, "1"},
+		{token.RBRACE, ""},
+		{token.STRING_TAIL, `}"`},
+		{token.SEMI, "\n"},
+		{token.EOF, ""},
+	}
+
+	var l Lexer
+	var errs diagnostics.List
+	fs := token.NewFileSet()
+	eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+	l.Init(fs.AddFile("", -1, len(input)), []byte(input), eh, true)
+	for _, test := range tests {
+		pos, tok, lit := l.Lex()
+		if tok != test.tok {
+			t.Fatalf("%s: token wrong; expected=%q, got=%q", fs.Position(pos), test.tok, tok)
+		}
+
+		if lit != test.lit {
+			t.Fatalf("%s: literal wrong; expected=%q, got=%q", fs.Position(pos), test.lit, lit)
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnescapeBraces(t *testing.T) {
+	tests := []struct {
+		lit, expected string
+	}{
+		{`"a"`, `"a"`},
+		{`"{{Braces}} {{{{galore}}}}"`, `"{Braces} {{galore}}"`},
+		{`}, {{x}} {`, `}, {x} {`},
+		{`"a}}b"`, `"a}b"`},
+	}
+	for _, test := range tests {
+		if got := UnescapeBraces(test.lit); got != test.expected {
+			t.Errorf("%s: expected=%s, got=%s", test.lit, test.expected, got)
+		}
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	input := `// doc
+a = 1 // line
+b /* inline */ = 2 /* multi
+line */ c
+/* general */ d /* end */
+e
+`
+
+	tests := []struct {
+		tok token.Token
+		lit string
+	}{
+		{token.COMMENT, "// doc"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, ""},
+		{token.INT, "1"},
+		{token.SEMI, "\n"},
+		{token.COMMENT, "// line"},
+		{token.IDENT, "b"},
+		{token.COMMENT, "/* inline */"},
+		{token.ASSIGN, ""},
+		{token.INT, "2"},
+		{token.SEMI, "\n"},
+		{token.COMMENT, "/* multi\nline */"},
+		{token.IDENT, "c"},
+		{token.SEMI, "\n"},
+		{token.COMMENT, "/* general */"},
+		{token.IDENT, "d"},
+		{token.SEMI, "\n"},
+		{token.COMMENT, "/* end */"},
+		{token.IDENT, "e"},
+		{token.SEMI, "\n"},
+		{token.EOF, ""},
+	}
+
+	for _, scanComments := range []bool{true, false} {
+		var l Lexer
+		var errs diagnostics.List
+		fs := token.NewFileSet()
+		eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+		l.Init(fs.AddFile("", -1, len(input)), []byte(input), eh, scanComments)
+		for _, test := range tests {
+			if test.tok == token.COMMENT && !scanComments {
+				continue
+			}
+			pos, tok, lit := l.Lex()
+			if tok != test.tok {
+				t.Fatalf("%s: token wrong; expected=%q, got=%q", fs.Position(pos), test.tok, tok)
+			}
+
+			if lit != test.lit {
+				t.Fatalf("%s: literal wrong; expected=%q, got=%q", fs.Position(pos), test.lit, lit)
+			}
+		}
+
+		if err := errs.Err(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLexNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		tok   token.Token
+	}{
+		{"0", token.INT},
+		{"1_000_000", token.INT},
+		{"0x1F", token.INT},
+		{"0X_dead_BEEF", token.INT},
+		{"0o17", token.INT},
+		{"0O_7", token.INT},
+		{"017", token.INT},
+		{"0b1010", token.INT},
+		{"0B_1_0", token.INT},
+		{"1.5", token.FLOAT},
+		{"1.", token.FLOAT},
+		{".25", token.FLOAT},
+		{"1e10", token.FLOAT},
+		{"6.02E+23", token.FLOAT},
+		{"1_0.0_1e-1_0", token.FLOAT},
+		{"0189.5", token.FLOAT},
+		{"0x1p-2", token.FLOAT},
+		{"0x1.8P+1", token.FLOAT},
+		{"0x.8p0", token.FLOAT},
+		{"2i", token.IMAG},
+		{"2.4i", token.IMAG},
+		{"0x10i", token.IMAG},
+		{"1e3i", token.IMAG},
+		{"0123i", token.IMAG},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		var errs diagnostics.List
+		fs := token.NewFileSet()
+		eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+		l.Init(fs.AddFile("", -1, len(test.input)), []byte(test.input), eh, true)
+		_, tok, lit := l.Lex()
+		if tok != test.tok {
+			t.Errorf("%q: token wrong; expected=%q, got=%q", test.input, test.tok, tok)
+		}
+		if lit != test.input {
+			t.Errorf("%q: literal wrong; got=%q", test.input, lit)
+		}
+		if _, tok, _ = l.Lex(); tok != token.SEMI {
+			t.Errorf("%q: expected end of literal, got=%q", test.input, tok)
+		}
+		if err := errs.Err(); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.input, err)
+		}
+	}
+
+	for tok, name := range map[token.Token]string{token.INT: "INT", token.FLOAT: "FLOAT", token.IMAG: "IMAG"} {
+		if tok.String() != name {
+			t.Errorf("token string wrong; expected=%q, got=%q", name, tok.String())
+		}
+	}
+}
+
+func TestLexNumberErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"0x", "<input>:1:3: hexadecimal literal has no digits"},
+		{"0b", "<input>:1:3: binary literal has no digits"},
+		{"0b102", "<input>:1:5: invalid digit '2' in binary literal"},
+		{"0o78", "<input>:1:4: invalid digit '8' in octal literal"},
+		{"0128", "<input>:1:4: invalid digit '8' in octal literal"},
+		{"0b1.0", "<input>:1:4: invalid radix point in binary literal"},
+		{"0x1.8", "<input>:1:6: hexadecimal mantissa requires a 'p' exponent"},
+		{"1p2", "<input>:1:2: 'p' exponent requires hexadecimal mantissa"},
+		{"0x1p", "<input>:1:5: exponent has no digits"},
+		{"0b1e2", "<input>:1:4: 'e' exponent requires decimal mantissa"},
+		{"1e+", "<input>:1:4: exponent has no digits"},
+		{"1__0", "<input>:1:3: '_' must separate successive digits"},
+		{"1_", "<input>:1:2: '_' must separate successive digits"},
+		{"0x_1_", "<input>:1:5: '_' must separate successive digits"},
+		{"1_.5", "<input>:1:2: '_' must separate successive digits"},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		var errs diagnostics.List
+		fs := token.NewFileSet()
+		eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+		l.Init(fs.AddFile("", -1, len(test.input)), []byte(test.input), eh, true)
+		for _, tok, _ := l.Lex(); tok != token.EOF; _, tok, _ = l.Lex() {
+		}
+
+		if len(errs) == 0 {
+			t.Fatalf("%q: expected error %q", test.input, test.err)
+		}
+		if errs[0].Error() != test.err {
+			t.Errorf("%q: error wrong; expected=%q, got=%q", test.input, test.err, errs[0].Error())
+		}
+	}
+}
+
+func TestLexStringErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`"abc`, "<input>:1:5: literal not terminated"},
+		{`"a } b"`, "<input>:1:4: unbalanced '}' in string literal; use '}}' for a literal brace"},
+		{`"a {b"`, "<input>:1:7: literal not terminated"},
+		{`"a {b`, "<input>:1:6: string interpolation not terminated"},
+		{`"a {b // c}"`, "<input>:1:7: comment not allowed in string interpolation"},
+		{`"a {b /* c */}"`, "<input>:1:7: comment not allowed in string interpolation"},
+		{"a /* b", "<input>:1:3: comment not terminated"},
+		{`"\q"`, "<input>:1:3: unknown escape sequence"},
+		{`"\x4"`, "<input>:1:5: escape sequence not terminated"},
+		{"'ab'", "<input>:1:1: illegal char literal"},
+		{"'a", "<input>:1:1: char literal not terminated"},
+		{"`a", "<input>:1:1: raw string literal not terminated"},
+		{"a # b", "<input>:1:3: illegal character U+0023 '#'"},
+		{"a \uFEFF", "<input>:1:3: illegal byte order mark"},
+		{"a \xff", "<input>:1:3: illegal UTF-8 encoding"},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		var errs diagnostics.List
+		fs := token.NewFileSet()
+		eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+		l.Init(fs.AddFile("", -1, len(test.input)), []byte(test.input), eh, true)
+		for _, tok, _ := l.Lex(); tok != token.EOF; _, tok, _ = l.Lex() {
+		}
+
+		if len(errs) == 0 {
+			t.Fatalf("%q: expected error %q", test.input, test.err)
+		}
+		if errs[0].Error() != test.err {
+			t.Errorf("%q: error wrong; expected=%q, got=%q", test.input, test.err, errs[0].Error())
+		}
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	input := "\uFEFFa = 1\n\n/* c */ fn(b) {\n\t`x\ny` + 'z'\n}"
+
+	tests := []struct {
+		tok token.Token
+		pos string
+		end string
+		lit string
+	}{
+		{token.IDENT, "1:4", "1:5", "a"},
+		{token.ASSIGN, "1:6", "1:7", ""},
+		{token.INT, "1:8", "1:9", "1"},
+		{token.SEMI, "1:9", "2:1", "\n"},
+		{token.COMMENT, "3:1", "3:8", "/* c */"},
+		{token.FN, "3:9", "3:11", "fn"},
+		{token.LPAREN, "3:11", "3:12", ""},
+		{token.IDENT, "3:12", "3:13", "b"},
+		{token.RPAREN, "3:13", "3:14", ""},
+		{token.LBRACE, "3:15", "3:16", ""},
+		{token.RAW_STRING, "4:2", "5:3", "`x\ny`"},
+		{token.ADD, "5:4", "5:5", ""},
+		{token.CHAR, "5:6", "5:9", "'z'"},
+		{token.SEMI, "5:9", "6:1", "\n"},
+		{token.RBRACE, "6:1", "6:2", ""},
+		{token.SEMI, "6:2", "6:2", ""},
+		{token.EOF, "6:2", "6:2", ""},
+	}
+
+	var l Lexer
+	var errs diagnostics.List
+	fs := token.NewFileSet()
+	eh := func(pos token.Position, msg string) { errs.Add(pos, msg) }
+
+	file := fs.AddFile("", -1, len(input))
+	l.Init(file, []byte(input), eh, true)
+	for _, test := range tests {
+		pos, tok, lit := l.Lex()
+		if tok != test.tok {
+			t.Fatalf("%s: token wrong; expected=%q, got=%q", fs.Position(pos), test.tok, tok)
+		}
+		if lit != test.lit {
+			t.Fatalf("%s: literal wrong; expected=%q, got=%q", fs.Position(pos), test.lit, lit)
+		}
+
+		// the end of a token is its position plus the length of its
+		// source text, which is the literal or the operator itself
+		n := len(lit)
+		if lit == "" && tok != token.SEMI && tok != token.EOF {
+			n = len(tok.String())
+		}
+		if p := fs.Position(pos); fmt.Sprintf("%d:%d", p.Line, p.Column) != test.pos {
+			t.Errorf("%q: position wrong; expected=%s, got=%s", test.lit, test.pos, p)
+		}
+		if p := fs.Position(pos + token.Pos(n)); fmt.Sprintf("%d:%d", p.Line, p.Column) != test.end {
+			t.Errorf("%q: end wrong; expected=%s, got=%s", test.lit, test.end, p)
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		t.Error(err)
+	}
+	if file.LineCount() != 6 {
+		t.Errorf("line count wrong; expected=6, got=%d", file.LineCount())
+	}
+}
+
+// benchSource is a large Rose source file made up of many copies
+// of the input of TestLex.
+var benchSource = []byte(strings.Repeat(`// arithmetic adds x and y.
+fn arithmetic(x, y int) int {
+	return x + y /* sum */
+}
+
+const c = "constant {arithmetic(1, 2)}"
+a, b = nil, 99.53
+if 5 < 9 && 0x1F != 0b1010 {
+	return 'a'
+} else {
+	return `+"`raw`"+`
+}
+
+[1, 2, 3]
+{1: "one", 2: "two"}
+l[2:5]
+`, 2000))
+
+func BenchmarkLex(b *testing.B) {
+	var l Lexer
+	fs := token.NewFileSet()
+	file := fs.AddFile("", -1, len(benchSource))
+	b.SetBytes(int64(len(benchSource)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Init(file, benchSource, nil, true)
+		for {
+			_, tok, _ := l.Lex()
+			if tok == token.EOF {
+				break
+			}
+		}
+	}
+}
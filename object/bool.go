@@ -1,8 +1,13 @@
 package object
 
-func (b Bool) Type() ObjectType       { return BOOL_OBJ }
-func (b Bool) Truthy() bool           { return bool(b) }
-func (b Bool) Equals(rhs Object) bool { return bool(b) == bool(rhs.(Bool)) }
+import "github.com/capnspacehook/rose/token"
+
+func (b Bool) Type() ObjectType { return BOOL_OBJ }
+func (b Bool) Truthy() bool     { return bool(b) }
+func (b Bool) Equals(rhs Object) bool {
+	r, ok := rhs.(Bool)
+	return ok && b == r
+}
 func (b Bool) String() string {
 	if bool(b) {
 		return "true"
@@ -10,3 +15,33 @@ func (b Bool) String() string {
 
 	return "false"
 }
+
+// UnaryOp applies the unary operator op to b.
+func (b Bool) UnaryOp(op token.Token) (Object, error) {
+	if op == token.NOT {
+		return !b, nil
+	}
+
+	return nil, errUndefinedOp(op, b)
+}
+
+// BinaryOp applies the binary operator op to b and rhs. The logical
+// operators are evaluated by the evaluator as they short-circuit.
+func (b Bool) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(Bool)
+	if !ok {
+		if op == token.IN || op == token.NOT_IN {
+			return nil, errUndefinedIn(op, b, rhs)
+		}
+		return nil, errMismatchedTypes(b, rhs)
+	}
+
+	switch op {
+	case token.EQL:
+		return Bool(b == r), nil
+	case token.NEQ:
+		return Bool(b != r), nil
+	}
+
+	return nil, errUndefinedOp(op, b)
+}
@@ -1,10 +1,68 @@
 package object
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/capnspacehook/rose/token"
 )
 
-func (c Char) Type() ObjectType       { return CHAR_OBJ }
-func (c Char) Truthy() bool           { return rune(c) != 0 }
-func (c Char) Equals(rhs Object) bool { return rune(c) == rune(rhs.(Char)) }
-func (c Char) String() string         { return strconv.QuoteRuneToGraphic(rune(c)) }
+var errCharOverflow = errors.New("char overflow")
+
+func (c Char) Type() ObjectType { return CHAR_OBJ }
+func (c Char) Truthy() bool     { return rune(c) != 0 }
+func (c Char) Equals(rhs Object) bool {
+	r, ok := rhs.(Char)
+	return ok && c == r
+}
+func (c Char) String() string { return strconv.QuoteRuneToGraphic(rune(c)) }
+
+func (c Char) LessThan(rhs Object) bool {
+	r, ok := rhs.(Char)
+	return ok && c < r
+}
+
+// BinaryOp applies the binary operator op to c and rhs. A char may be
+// offset by a char or an int with + and -, as long as the result is a
+// valid Unicode code point. The in operators test whether a string
+// contains c.
+func (c Char) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	switch op {
+	case token.IN, token.NOT_IN:
+		s, ok := rhs.(String)
+		if !ok {
+			return nil, errUndefinedIn(op, c, rhs)
+		}
+		return Bool(strings.ContainsRune(string(s), rune(c)) == (op == token.IN)), nil
+	case token.ADD, token.SUB:
+		var offset int64
+		switch r := rhs.(type) {
+		case Char:
+			offset = int64(r)
+		case Int:
+			offset = int64(r)
+		default:
+			return nil, errMismatchedTypes(c, rhs)
+		}
+		if op == token.SUB {
+			offset = -offset
+		}
+		res := int64(c) + offset
+		if res < 0 || res > unicode.MaxRune {
+			return nil, errCharOverflow
+		}
+		return Char(res), nil
+	}
+
+	r, ok := rhs.(Char)
+	if !ok {
+		return nil, errMismatchedTypes(c, rhs)
+	}
+	if res, ok := compare(op, cmpInt(int64(c), int64(r))); ok {
+		return res, nil
+	}
+
+	return nil, errUndefinedOp(op, c)
+}
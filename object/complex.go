@@ -0,0 +1,60 @@
+package object
+
+import (
+	"math/cmplx"
+	"strconv"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+func (c Complex) Type() ObjectType { return COMPLEX_OBJ }
+func (c Complex) Truthy() bool     { return complex128(c) != 0 }
+func (c Complex) Equals(rhs Object) bool {
+	r, ok := rhs.(Complex)
+	return ok && c == r
+}
+func (c Complex) String() string { return strconv.FormatComplex(complex128(c), 'g', -1, 128) }
+
+// UnaryOp applies the unary operator op to c.
+func (c Complex) UnaryOp(op token.Token) (Object, error) {
+	switch op {
+	case token.ADD:
+		return c, nil
+	case token.SUB:
+		return -c, nil
+	}
+
+	return nil, errUndefinedOp(op, c)
+}
+
+// BinaryOp applies the binary operator op to c and rhs following
+// IEEE 754 semantics for the real and imaginary parts. Complex
+// numbers are not ordered.
+func (c Complex) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(Complex)
+	if !ok {
+		if op == token.IN || op == token.NOT_IN {
+			return nil, errUndefinedIn(op, c, rhs)
+		}
+		return nil, errMismatchedTypes(c, rhs)
+	}
+
+	switch op {
+	case token.ADD:
+		return c + r, nil
+	case token.SUB:
+		return c - r, nil
+	case token.MUL:
+		return c * r, nil
+	case token.QUO:
+		return c / r, nil
+	case token.EXP:
+		return Complex(cmplx.Pow(complex128(c), complex128(r))), nil
+	case token.EQL:
+		return Bool(c == r), nil
+	case token.NEQ:
+		return Bool(c != r), nil
+	}
+
+	return nil, errUndefinedOp(op, c)
+}
@@ -0,0 +1,108 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+// An Environment binds names to the values of variables and constants
+// at runtime. Environments are nested; a name that is not bound in an
+// environment is looked up in its outer environment.
+type Environment struct {
+	store  map[string]Object
+	consts map[string]token.Token // keywords declaring the names bound by SetConst
+	outer  *Environment
+	out    io.Writer // output of print; or nil
+}
+
+// NewEnvironment creates a new top-level environment.
+func NewEnvironment() *Environment {
+	return &Environment{
+		store:  make(map[string]Object),
+		consts: make(map[string]token.Token),
+	}
+}
+
+// NewEnclosedEnvironment creates a new environment nested in outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get returns the value bound to name in env or any of its
+// enclosing environments.
+func (env *Environment) Get(name string) (Object, bool) {
+	for e := env; e != nil; e = e.outer {
+		if obj, ok := e.store[name]; ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// Set binds name to val in env.
+func (env *Environment) Set(name string, val Object) {
+	env.store[name] = val
+	delete(env.consts, name)
+}
+
+// SetConst binds name, declared with the keyword tok (token.CONST
+// or token.LET), to val in env. Unlike bindings created by Set, the
+// binding cannot be changed by Assign.
+func (env *Environment) SetConst(name string, val Object, tok token.Token) {
+	env.store[name] = val
+	env.consts[name] = tok
+}
+
+// Merge binds the names bound in other to the same values in env.
+// Names bound by SetConst in other cannot be changed in env either.
+// Afterwards other shares the bindings of env, so that functions
+// declared in other see changes to the merged names.
+func (env *Environment) Merge(other *Environment) {
+	for name, val := range other.store {
+		env.store[name] = val
+		if tok, ok := other.consts[name]; ok {
+			env.consts[name] = tok
+		} else {
+			delete(env.consts, name)
+		}
+	}
+	other.store, other.consts = env.store, env.consts
+}
+
+// SetOutput sets the writer that print writes to in env and
+// the environments nested in it.
+func (env *Environment) SetOutput(w io.Writer) {
+	env.out = w
+}
+
+// Output returns the writer that print writes to in env. It is
+// os.Stdout unless set by SetOutput.
+func (env *Environment) Output() io.Writer {
+	for e := env; e != nil; e = e.outer {
+		if e.out != nil {
+			return e.out
+		}
+	}
+	return os.Stdout
+}
+
+// Assign changes the value of name in the innermost environment
+// binding it to val. It returns an error if name is not bound or
+// is bound to a constant.
+func (env *Environment) Assign(name string, val Object) error {
+	for e := env; e != nil; e = e.outer {
+		if _, ok := e.store[name]; ok {
+			if tok, ok := e.consts[name]; ok {
+				return fmt.Errorf("cannot assign to %s (declared %s)", name, tok)
+			}
+			e.store[name] = val
+			return nil
+		}
+	}
+	return fmt.Errorf("undefined: %s", name)
+}
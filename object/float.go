@@ -1,10 +1,73 @@
 package object
 
 import (
+	"math"
 	"strconv"
+
+	"github.com/capnspacehook/rose/token"
 )
 
-func (f Float) Type() ObjectType       { return FLOAT_OBJ }
-func (f Float) Truthy() bool           { return float64(f) != 0 }
-func (f Float) Equals(rhs Object) bool { return float64(f) == float64(rhs.(Float)) }
-func (f Float) String() string         { return strconv.FormatFloat(float64(f), 'g', -1, 64) }
+func (f Float) Type() ObjectType { return FLOAT_OBJ }
+func (f Float) Truthy() bool     { return float64(f) != 0 }
+func (f Float) Equals(rhs Object) bool {
+	r, ok := rhs.(Float)
+	return ok && f == r
+}
+func (f Float) String() string { return strconv.FormatFloat(float64(f), 'g', -1, 64) }
+
+func (f Float) LessThan(rhs Object) bool {
+	r, ok := rhs.(Float)
+	return ok && f < r
+}
+
+// UnaryOp applies the unary operator op to f.
+func (f Float) UnaryOp(op token.Token) (Object, error) {
+	switch op {
+	case token.ADD:
+		return f, nil
+	case token.SUB:
+		return -f, nil
+	}
+
+	return nil, errUndefinedOp(op, f)
+}
+
+// BinaryOp applies the binary operator op to f and rhs following
+// IEEE 754 semantics; division by zero results in an infinity.
+func (f Float) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(Float)
+	if !ok {
+		if op == token.IN || op == token.NOT_IN {
+			return nil, errUndefinedIn(op, f, rhs)
+		}
+		return nil, errMismatchedTypes(f, rhs)
+	}
+
+	switch op {
+	case token.ADD:
+		return f + r, nil
+	case token.SUB:
+		return f - r, nil
+	case token.MUL:
+		return f * r, nil
+	case token.QUO:
+		return f / r, nil
+	case token.EXP:
+		return Float(math.Pow(float64(f), float64(r))), nil
+	case token.EQL:
+		// NaN is not equal to itself
+		return Bool(f == r), nil
+	case token.NEQ:
+		return Bool(f != r), nil
+	case token.LSS:
+		return Bool(f < r), nil
+	case token.LEQ:
+		return Bool(f <= r), nil
+	case token.GTR:
+		return Bool(f > r), nil
+	case token.GEQ:
+		return Bool(f >= r), nil
+	}
+
+	return nil, errUndefinedOp(op, f)
+}
@@ -0,0 +1,78 @@
+package object
+
+import "github.com/capnspacehook/rose/token"
+
+// A Constant identifies the constant that a frozen container is part
+// of. Frozen containers cannot be changed.
+type Constant struct {
+	Name string
+	Tok  token.Token // CONST or LET
+}
+
+// Freeze returns a deep copy of x in which all containers are frozen
+// as part of the constant c. Values that hold no containers are
+// returned as is.
+func Freeze(x Object, c *Constant) Object {
+	switch x := x.(type) {
+	case *List:
+		return &List{Elements: freezeElems(x.Elements, c), Const: c}
+	case *Tuple:
+		return &Tuple{Elements: freezeElems(x.Elements, c), Const: c}
+	case *Map:
+		return &Map{Keys: freezeElems(x.Keys, c), Values: freezeElems(x.Values, c), Const: c}
+	}
+
+	return x
+}
+
+func freezeElems(elems []Object, c *Constant) []Object {
+	frozen := make([]Object, len(elems))
+	for i, elem := range elems {
+		frozen[i] = Freeze(elem, c)
+	}
+	return frozen
+}
+
+// Thaw returns a mutable deep copy of x if x is frozen and x
+// otherwise.
+func Thaw(x Object) Object {
+	switch x := x.(type) {
+	case *List:
+		if x.Const != nil {
+			return &List{Elements: thawElems(x.Elements)}
+		}
+	case *Tuple:
+		if x.Const != nil {
+			return &Tuple{Elements: thawElems(x.Elements)}
+		}
+	case *Map:
+		if x.Const != nil {
+			return &Map{Keys: thawElems(x.Keys), Values: thawElems(x.Values)}
+		}
+	}
+
+	return x
+}
+
+func thawElems(elems []Object) []Object {
+	thawed := make([]Object, len(elems))
+	for i, elem := range elems {
+		thawed[i] = Thaw(elem)
+	}
+	return thawed
+}
+
+// FrozenBy returns the constant that x is part of, or nil if x
+// is not part of a constant.
+func FrozenBy(x Object) *Constant {
+	switch x := x.(type) {
+	case *List:
+		return x.Const
+	case *Tuple:
+		return x.Const
+	case *Map:
+		return x.Const
+	}
+
+	return nil
+}
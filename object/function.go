@@ -0,0 +1,38 @@
+package object
+
+import "github.com/capnspacehook/rose/ast"
+
+// A Function is a function declared by a function declaration or
+// literal. It is evaluated in a new environment enclosed by Env,
+// the environment it was declared in.
+type Function struct {
+	Name   string         // function name; or "" for a function literal
+	Params *ast.FieldList // parameters; or nil
+	Body   *ast.BlockStmt
+	Env    *Environment
+}
+
+// A BuiltinFunction implements a builtin function. It is called
+// with the evaluated arguments and the environment of the call.
+type BuiltinFunction func(env *Environment, args []Object) (Object, error)
+
+// A Builtin is a predeclared function.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (f *Function) Type() ObjectType       { return FUNCTION_OBJ }
+func (f *Function) Truthy() bool           { return true }
+func (f *Function) Equals(rhs Object) bool { return f == rhs }
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<fn>"
+	}
+	return "<fn " + f.Name + ">"
+}
+
+func (b *Builtin) Type() ObjectType       { return BUILTIN_OBJ }
+func (b *Builtin) Truthy() bool           { return true }
+func (b *Builtin) Equals(rhs Object) bool { return b == rhs }
+func (b *Builtin) String() string         { return "<builtin " + b.Name + ">" }
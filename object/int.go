@@ -1,10 +1,165 @@
 package object
 
 import (
+	"math"
 	"strconv"
+
+	"github.com/capnspacehook/rose/token"
 )
 
-func (i Int) Type() ObjectType       { return INTEGER_OBJ }
-func (i Int) Truthy() bool           { return int64(i) != 0 }
-func (i Int) Equals(rhs Object) bool { return int64(i) == int64(rhs.(Int)) }
-func (i Int) String() string         { return strconv.FormatInt(int64(i), 10) }
+func (i Int) Type() ObjectType { return INTEGER_OBJ }
+func (i Int) Truthy() bool     { return int64(i) != 0 }
+func (i Int) Equals(rhs Object) bool {
+	r, ok := rhs.(Int)
+	return ok && i == r
+}
+func (i Int) String() string { return strconv.FormatInt(int64(i), 10) }
+
+func (i Int) LessThan(rhs Object) bool {
+	r, ok := rhs.(Int)
+	return ok && i < r
+}
+
+// UnaryOp applies the unary operator op to i.
+func (i Int) UnaryOp(op token.Token) (Object, error) {
+	switch op {
+	case token.ADD:
+		return i, nil
+	case token.SUB:
+		if i == math.MinInt64 {
+			return nil, errOverflow
+		}
+		return -i, nil
+	case token.INVT:
+		return ^i, nil
+	}
+
+	return nil, errUndefinedOp(op, i)
+}
+
+// BinaryOp applies the binary operator op to i and rhs. Arithmetic
+// operations and left shifts that overflow and integer division by
+// zero fail.
+func (i Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(Int)
+	if !ok {
+		if op == token.IN || op == token.NOT_IN {
+			return nil, errUndefinedIn(op, i, rhs)
+		}
+		return nil, errMismatchedTypes(i, rhs)
+	}
+
+	switch op {
+	case token.ADD:
+		s := i + r
+		if (s > i) != (r > 0) {
+			return nil, errOverflow
+		}
+		return s, nil
+	case token.SUB:
+		d := i - r
+		if (d < i) != (r > 0) {
+			return nil, errOverflow
+		}
+		return d, nil
+	case token.MUL:
+		return mulInt(i, r)
+	case token.QUO:
+		if r == 0 {
+			return nil, errDivByZero
+		}
+		if i == math.MinInt64 && r == -1 {
+			return nil, errOverflow
+		}
+		return i / r, nil
+	case token.REM:
+		if r == 0 {
+			return nil, errDivByZero
+		}
+		return i % r, nil
+	case token.EXP:
+		return expInt(i, r)
+	case token.AND:
+		return i & r, nil
+	case token.OR:
+		return i | r, nil
+	case token.XOR:
+		return i ^ r, nil
+	case token.AND_NOT:
+		return i &^ r, nil
+	case token.SHL:
+		if r < 0 {
+			return nil, errNegativeShift
+		}
+		if r >= 64 {
+			if i != 0 {
+				return nil, errOverflow
+			}
+			return i, nil
+		}
+		s := i << uint(r)
+		if s>>uint(r) != i {
+			return nil, errOverflow
+		}
+		return s, nil
+	case token.SHR:
+		if r < 0 {
+			return nil, errNegativeShift
+		}
+		if r >= 64 {
+			r = 63
+		}
+		return i >> uint(r), nil
+	}
+
+	if res, ok := compare(op, cmpInt(int64(i), int64(r))); ok {
+		return res, nil
+	}
+
+	return nil, errUndefinedOp(op, i)
+}
+
+func cmpInt(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func mulInt(x, y Int) (Int, error) {
+	if x == 0 || y == 0 {
+		return 0, nil
+	}
+	p := x * y
+	if p/y != x || x == -1 && y == math.MinInt64 || y == -1 && x == math.MinInt64 {
+		return 0, errOverflow
+	}
+	return p, nil
+}
+
+// expInt computes x**y by repeated squaring.
+func expInt(x, y Int) (Object, error) {
+	if y < 0 {
+		return nil, errNegativeExponent
+	}
+
+	res := Int(1)
+	for {
+		var err error
+		if y&1 == 1 {
+			if res, err = mulInt(res, x); err != nil {
+				return nil, err
+			}
+		}
+		y >>= 1
+		if y == 0 {
+			return res, nil
+		}
+		if x, err = mulInt(x, x); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+// A List is a mutable sequence of elements. Lists are passed by
+// reference.
+type List struct {
+	Elements []Object
+	Const    *Constant // constant the list is part of; or nil
+}
+
+// A Tuple is an immutable sequence of elements.
+type Tuple struct {
+	Elements []Object
+	Const    *Constant // constant the tuple is part of; or nil
+}
+
+func (l *List) Type() ObjectType { return LIST_OBJ }
+func (l *List) Truthy() bool     { return len(l.Elements) > 0 }
+func (l *List) Equals(rhs Object) bool {
+	r, ok := rhs.(*List)
+	return ok && equalElems(l.Elements, r.Elements)
+}
+func (l *List) String() string { return "[" + joinElems(l.Elements) + "]" }
+
+func (l *List) Len() int               { return len(l.Elements) }
+func (l *List) Elems() []Object        { return l.Elements }
+func (l *List) Contains(x Object) bool { return containsElem(l.Elements, x) }
+
+// Set sets the element at index i of l to val. It returns an error
+// if l is frozen.
+func (l *List) Set(i int, val Object) error {
+	if l.Const != nil {
+		return ErrFrozen
+	}
+	l.Elements[i] = val
+	return nil
+}
+
+// Prepend inserts val before the first element of l. It returns an
+// error if l is frozen.
+func (l *List) Prepend(val Object) error {
+	if l.Const != nil {
+		return ErrFrozen
+	}
+	l.Elements = append([]Object{val}, l.Elements...)
+	return nil
+}
+
+// BinaryOp applies the binary operator op to l and rhs. Lists are
+// concatenated with + and are equal if their elements are equal.
+// l << rhs appends rhs to l and results in l.
+func (l *List) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	if op == token.SHL {
+		if l.Const != nil {
+			return nil, ErrFrozen
+		}
+		l.Elements = append(l.Elements, rhs)
+		return l, nil
+	}
+
+	r, ok := rhs.(*List)
+	if !ok {
+		return nil, errMismatchedTypes(l, rhs)
+	}
+
+	switch op {
+	case token.ADD:
+		elems := make([]Object, 0, len(l.Elements)+len(r.Elements))
+		elems = append(elems, l.Elements...)
+		return &List{Elements: append(elems, r.Elements...)}, nil
+	case token.EQL:
+		return Bool(l.Equals(r)), nil
+	case token.NEQ:
+		return Bool(!l.Equals(r)), nil
+	}
+
+	return nil, errUndefinedOp(op, l)
+}
+
+func (t *Tuple) Type() ObjectType { return TUPLE_OBJ }
+func (t *Tuple) Truthy() bool     { return len(t.Elements) > 0 }
+func (t *Tuple) Equals(rhs Object) bool {
+	r, ok := rhs.(*Tuple)
+	return ok && equalElems(t.Elements, r.Elements)
+}
+func (t *Tuple) String() string {
+	if len(t.Elements) == 1 {
+		return "(" + joinElems(t.Elements) + ",)"
+	}
+	return "(" + joinElems(t.Elements) + ")"
+}
+
+func (t *Tuple) Len() int               { return len(t.Elements) }
+func (t *Tuple) Elems() []Object        { return t.Elements }
+func (t *Tuple) Contains(x Object) bool { return containsElem(t.Elements, x) }
+
+func equalElems(x, y []Object) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !x[i].Equals(y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsElem(elems []Object, x Object) bool {
+	for _, elem := range elems {
+		if elem.Equals(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinElems returns the comma separated elements of a container.
+// Strings are quoted so that their boundaries are visible.
+func joinElems(elems []Object) string {
+	var b strings.Builder
+	for i, elem := range elems {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(Inspect(elem))
+	}
+	return b.String()
+}
+
+// Inspect returns the representation of x as an element of
+// a container. It is the same as x.String() except that strings
+// are quoted.
+func Inspect(x Object) string {
+	if s, ok := x.(String); ok {
+		return strconv.Quote(string(s))
+	}
+	return x.String()
+}
@@ -0,0 +1,104 @@
+package object
+
+import (
+	"strings"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+// A Map is a mutable collection of key-value pairs with unique keys.
+// Keys are compared with Equals and iterated over in insertion
+// order. Maps are passed by reference.
+type Map struct {
+	Keys   []Object
+	Values []Object
+	Const  *Constant // constant the map is part of; or nil
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Truthy() bool     { return len(m.Keys) > 0 }
+func (m *Map) Equals(rhs Object) bool {
+	r, ok := rhs.(*Map)
+	if !ok || len(m.Keys) != len(r.Keys) {
+		return false
+	}
+	for i, key := range m.Keys {
+		val, ok := r.Get(key)
+		if !ok || !m.Values[i].Equals(val) {
+			return false
+		}
+	}
+	return true
+}
+func (m *Map) String() string {
+	if len(m.Keys) == 0 {
+		return "{:}"
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range m.Keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(Inspect(key))
+		b.WriteString(": ")
+		b.WriteString(Inspect(m.Values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (m *Map) Len() int               { return len(m.Keys) }
+func (m *Map) Elems() []Object        { return m.Keys }
+func (m *Map) Contains(x Object) bool { return m.index(x) >= 0 }
+
+// Get returns the value of key in m and reports whether key is in m.
+func (m *Map) Get(key Object) (Object, bool) {
+	if i := m.index(key); i >= 0 {
+		return m.Values[i], true
+	}
+	return nil, false
+}
+
+// Set sets the value of key in m to val. It returns an error if
+// m is frozen.
+func (m *Map) Set(key, val Object) error {
+	if m.Const != nil {
+		return ErrFrozen
+	}
+	if i := m.index(key); i >= 0 {
+		m.Values[i] = val
+		return nil
+	}
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, val)
+	return nil
+}
+
+func (m *Map) index(key Object) int {
+	for i, k := range m.Keys {
+		if k.Equals(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// BinaryOp applies the binary operator op to m and rhs. Maps are
+// equal if they hold equal values for the same keys.
+func (m *Map) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(*Map)
+	if !ok {
+		return nil, errMismatchedTypes(m, rhs)
+	}
+
+	switch op {
+	case token.EQL:
+		return Bool(m.Equals(r)), nil
+	case token.NEQ:
+		return Bool(!m.Equals(r)), nil
+	}
+
+	return nil, errUndefinedOp(op, m)
+}
@@ -1,6 +1,14 @@
 package object
 
-func (n Nil) Type() ObjectType       { return NIL_OBJ }
-func (n Nil) Truthy() bool           { return false }
-func (n Nil) Equals(rhs Object) bool { return rhs.(Nilable).IsNil() }
-func (n Nil) String() string         { return "<nil>" }
+func (n Nil) Type() ObjectType { return NIL_OBJ }
+func (n Nil) Truthy() bool     { return false }
+func (n Nil) Equals(rhs Object) bool {
+	switch rhs := rhs.(type) {
+	case Nil:
+		return true
+	case Nilable:
+		return rhs.IsNil()
+	}
+	return false
+}
+func (n Nil) String() string { return "<nil>" }
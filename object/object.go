@@ -1,16 +1,27 @@
 package object
 
-import "github.com/capnspacehook/rose/token"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/capnspacehook/rose/token"
+)
 
 type ObjectType string
 
 const (
-	NIL_OBJ     ObjectType = "nil"
-	BOOL_OBJ               = "bool"
-	INTEGER_OBJ            = "int"
-	FLOAT_OBJ              = "float"
-	CHAR_OBJ               = "char"
-	STRING_OBJ             = "string"
+	NIL_OBJ      ObjectType = "nil"
+	BOOL_OBJ                = "bool"
+	INTEGER_OBJ             = "int"
+	FLOAT_OBJ               = "float"
+	COMPLEX_OBJ             = "complex"
+	CHAR_OBJ                = "char"
+	STRING_OBJ              = "string"
+	LIST_OBJ                = "list"
+	TUPLE_OBJ               = "tuple"
+	MAP_OBJ                 = "map"
+	FUNCTION_OBJ            = "fn"
+	BUILTIN_OBJ             = "builtin"
 )
 
 type Object interface {
@@ -28,6 +39,8 @@ type Int int64
 
 type Float float64
 
+type Complex complex128
+
 type Char rune
 
 type String string
@@ -36,10 +49,79 @@ type Nilable interface {
 	IsNil() bool
 }
 
+// A BinaryOperable object can be the left operand of binary operators.
+// BinaryOp returns an error if op is not defined on the operands or
+// if the operation fails at runtime.
 type BinaryOperable interface {
-	BinaryOp(op token.TokenType, rhs Object) (Object, error)
+	BinaryOp(op token.Token, rhs Object) (Object, error)
+}
+
+// A UnaryOperable object can be the operand of unary operators.
+type UnaryOperable interface {
+	UnaryOp(op token.Token) (Object, error)
 }
 
 type Orderable interface {
 	LessThan(rhs Object) bool
 }
+
+// A Container object holds elements that can be iterated over in
+// for in statements and tested for membership with the in operators.
+type Container interface {
+	Object
+	Len() int
+	// Elems returns the elements of the container in iteration
+	// order. The result must not be modified.
+	Elems() []Object
+	// Contains reports whether x is an element of the container.
+	Contains(x Object) bool
+}
+
+var (
+	// ErrFrozen is returned when changing a frozen container.
+	ErrFrozen = errors.New("value is part of a constant")
+
+	errDivByZero        = errors.New("integer divide by zero")
+	errOverflow         = errors.New("integer overflow")
+	errNegativeShift    = errors.New("negative shift amount")
+	errNegativeExponent = errors.New("negative exponent in integer exponentiation")
+)
+
+func errMismatchedTypes(lhs, rhs Object) error {
+	return fmt.Errorf("mismatched types %s and %s", lhs.Type(), rhs.Type())
+}
+
+func errUndefinedOp(op token.Token, x Object) error {
+	return fmt.Errorf("operator %s not defined on %s", op, x.Type())
+}
+
+func errUndefinedIn(op token.Token, lhs, rhs Object) error {
+	return fmt.Errorf("operator %s not defined on %s and %s", op, lhs.Type(), rhs.Type())
+}
+
+// compare returns the result of the comparison operator op given
+// the result cmp of comparing two operands, which is negative,
+// zero or positive if the left operand is less than, equal to or
+// greater than the right operand. The second result is false if
+// op is not a comparison operator.
+func compare(op token.Token, cmp int) (Object, bool) {
+	var res bool
+	switch op {
+	case token.EQL:
+		res = cmp == 0
+	case token.NEQ:
+		res = cmp != 0
+	case token.LSS:
+		res = cmp < 0
+	case token.LEQ:
+		res = cmp <= 0
+	case token.GTR:
+		res = cmp > 0
+	case token.GEQ:
+		res = cmp >= 0
+	default:
+		return nil, false
+	}
+
+	return Bool(res), true
+}
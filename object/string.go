@@ -1,6 +1,48 @@
 package object
 
-func (s String) Type() ObjectType       { return STRING_OBJ }
-func (s String) Truthy() bool           { return len(string(s)) > 0 }
-func (s String) Equals(rhs Object) bool { return string(s) == string(rhs.(String)) }
-func (s String) String() string         { return string(s) }
+import (
+	"strings"
+
+	"github.com/capnspacehook/rose/token"
+)
+
+func (s String) Type() ObjectType { return STRING_OBJ }
+func (s String) Truthy() bool     { return len(string(s)) > 0 }
+func (s String) Equals(rhs Object) bool {
+	r, ok := rhs.(String)
+	return ok && s == r
+}
+func (s String) String() string { return string(s) }
+
+func (s String) LessThan(rhs Object) bool {
+	r, ok := rhs.(String)
+	return ok && s < r
+}
+
+// BinaryOp applies the binary operator op to s and rhs. Strings are
+// concatenated with + and compared lexically byte-wise. The in
+// operators test whether rhs contains s as a substring.
+func (s String) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	r, ok := rhs.(String)
+	if !ok {
+		if op == token.IN || op == token.NOT_IN {
+			return nil, errUndefinedIn(op, s, rhs)
+		}
+		return nil, errMismatchedTypes(s, rhs)
+	}
+
+	switch op {
+	case token.ADD:
+		return s + r, nil
+	case token.IN:
+		return Bool(strings.Contains(string(r), string(s))), nil
+	case token.NOT_IN:
+		return Bool(!strings.Contains(string(r), string(s))), nil
+	}
+
+	if res, ok := compare(op, strings.Compare(string(s), string(r))); ok {
+		return res, nil
+	}
+
+	return nil, errUndefinedOp(op, s)
+}
@@ -0,0 +1,369 @@
+// This file implements the evaluation of constant expressions.
+
+package parser
+
+import (
+	"fmt"
+	"go/constant"
+	gotoken "go/token"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/lexer"
+	"github.com/capnspacehook/rose/token"
+)
+
+// maxConstBits is the maximum size in bits of intermediate
+// integer constant values.
+const maxConstBits = 512
+
+// goTokens maps operators to the corresponding go/token operators
+// understood by go/constant.
+var goTokens = map[token.Token]gotoken.Token{
+	token.ADD:     gotoken.ADD,
+	token.SUB:     gotoken.SUB,
+	token.MUL:     gotoken.MUL,
+	token.QUO:     gotoken.QUO,
+	token.REM:     gotoken.REM,
+	token.AND:     gotoken.AND,
+	token.OR:      gotoken.OR,
+	token.XOR:     gotoken.XOR,
+	token.SHL:     gotoken.SHL,
+	token.SHR:     gotoken.SHR,
+	token.AND_NOT: gotoken.AND_NOT,
+	token.LAND:    gotoken.LAND,
+	token.LOR:     gotoken.LOR,
+	token.EQL:     gotoken.EQL,
+	token.NEQ:     gotoken.NEQ,
+	token.LSS:     gotoken.LSS,
+	token.LEQ:     gotoken.LEQ,
+	token.GTR:     gotoken.GTR,
+	token.GEQ:     gotoken.GEQ,
+}
+
+// A constError describes why an expression is not constant.
+type constError struct {
+	pos token.Pos
+	msg string
+}
+
+// foldConsts evaluates the values of the constants declared by
+// idents with the given iota and records them in their objects.
+// If the values are inherited from a previous spec, errors are
+// reported at the position of the respective identifier.
+func (p *Parser) foldConsts(idents []*ast.Ident, values []ast.Expr, iota int, inherited bool) {
+	for i, ident := range idents {
+		val := constant.MakeUnknown()
+		if i < len(values) {
+			v, err := constValue(values[i], constant.MakeInt64(int64(iota)))
+			if err == nil {
+				err = representable(values[i], v)
+			}
+			if err != nil {
+				if inherited {
+					err.pos = ident.Pos()
+				}
+				p.errorCode(err.pos, diagnostics.InvalidConstant, err.msg)
+			} else {
+				val = v
+			}
+		}
+		ident.Obj.Value = val
+	}
+}
+
+// representable returns an error if the integer, float or complex
+// value val of x cannot be represented by an int, float or complex,
+// respectively.
+func representable(x ast.Expr, val constant.Value) *constError {
+	switch val.Kind() {
+	case constant.Int:
+		if _, exact := constant.Int64Val(val); !exact {
+			return &constError{x.Pos(), fmt.Sprintf("constant %s overflows int", val)}
+		}
+	case constant.Float:
+		if f, _ := constant.Float64Val(val); math.IsInf(f, 0) {
+			return &constError{x.Pos(), fmt.Sprintf("constant %s overflows float", val)}
+		}
+	case constant.Complex:
+		re, _ := constant.Float64Val(constant.Real(val))
+		im, _ := constant.Float64Val(constant.Imag(val))
+		if math.IsInf(re, 0) || math.IsInf(im, 0) {
+			return &constError{x.Pos(), fmt.Sprintf("constant %s overflows complex", val)}
+		}
+	}
+	return nil
+}
+
+// ConstValue returns the value of the constant expression x, or nil
+// if x is not constant or its value cannot be computed. The values of
+// the constants x refers to are those folded when they were parsed.
+func ConstValue(x ast.Expr) constant.Value {
+	val, err := constValue(x, nil)
+	if err != nil || val.Kind() == constant.Unknown {
+		return nil
+	}
+	return val
+}
+
+// constValue evaluates the constant expression x. Constant expressions
+// consist of literals, constants and operators. Integers and floats are
+// evaluated with arbitrary precision and chars are represented by their
+// code points. If x is a container literal, or the operands of x are
+// of mismatched types, the value is of kind constant.Unknown; type errors
+// are reported by the type checker. If x is not constant, an error
+// describing the first operand that is not is returned. The value of
+// the predeclared identifier iota is iota, or undefined if iota is nil.
+func constValue(x ast.Expr, iota constant.Value) (constant.Value, *constError) {
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		return constant.MakeUnknown(), nil
+
+	case *ast.BasicLit:
+		var kind gotoken.Token
+		switch x.Kind {
+		case token.INT:
+			kind = gotoken.INT
+		case token.FLOAT:
+			kind = gotoken.FLOAT
+		case token.IMAG:
+			kind = gotoken.IMAG
+		case token.CHAR:
+			kind = gotoken.CHAR
+		case token.STRING, token.RAW_STRING:
+			kind = gotoken.STRING
+		default:
+			return constant.MakeUnknown(), nil
+		}
+		lit := x.Value
+		if x.Kind == token.STRING {
+			lit = lexer.UnescapeBraces(lit)
+		}
+		return constant.MakeFromLiteral(lit, kind, 0), nil
+
+	case *ast.Ident:
+		if x.Obj == nil || x.Obj == unresolved {
+			if b, isBool := boolConsts[x.Name]; isBool {
+				return constant.MakeBool(b), nil
+			}
+			if x.Name == "iota" {
+				if iota == nil {
+					return nil, &constError{x.Pos(), "cannot use iota outside constant declaration"}
+				}
+				return iota, nil
+			}
+		} else if x.Obj.Kind == ast.Con && x.Obj.Value != nil {
+			return x.Obj.Value, nil
+		}
+		return nil, &constError{x.Pos(), x.Name + " is not constant"}
+
+	case *ast.ParenExpr:
+		return constValue(x.Expr, iota)
+
+	case *ast.UnaryExpr:
+		val, err := constValue(x.Expr, iota)
+		if err != nil {
+			return nil, err
+		}
+		return unaryOp(x.Op, val), nil
+
+	case *ast.BinaryExpr:
+		lhs, err := constValue(x.Lhs, iota)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := constValue(x.Rhs, iota)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(lhs, x.Op, x.OpPos, rhs)
+
+	case *ast.CallExpr:
+		return nil, &constError{x.Pos(), "call to " + funcName(x.Fun) + " is not constant"}
+
+	case *ast.InterpolatedString:
+		return constElems(x.Parts, iota)
+
+	case *ast.ListLit:
+		return constElems(x.Elts, iota)
+	case *ast.SetLit:
+		return constElems(x.Elts, iota)
+	case *ast.TupleLit:
+		return constElems(x.Elts, iota)
+	case *ast.MapLit:
+		for _, elt := range x.Elts {
+			if _, err := constElems([]ast.Expr{elt.Key, elt.Value}, iota); err != nil {
+				return nil, err
+			}
+		}
+		return constant.MakeUnknown(), nil
+
+	case *ast.FuncLit:
+		return nil, &constError{x.Pos(), "function literal is not constant"}
+	case *ast.SelectorExpr:
+		return nil, &constError{x.Pos(), "selector expression is not constant"}
+	case *ast.IndexExpr:
+		return nil, &constError{x.Pos(), "index expression is not constant"}
+	case *ast.SliceExpr:
+		return nil, &constError{x.Pos(), "slice expression is not constant"}
+	case *ast.TypeAssertExpr:
+		return nil, &constError{x.Pos(), "type assertion is not constant"}
+	}
+
+	// types are not values; this is reported by the type checker
+	return constant.MakeUnknown(), nil
+}
+
+// constElems checks that the container elements elts are constant.
+func constElems(elts []ast.Expr, iota constant.Value) (constant.Value, *constError) {
+	for _, elt := range elts {
+		if _, err := constValue(elt, iota); err != nil {
+			return nil, err
+		}
+	}
+	return constant.MakeUnknown(), nil
+}
+
+// funcName returns the name of the called function fun.
+func funcName(fun ast.Expr) string {
+	switch fun := unparen(fun).(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		if x := funcName(fun.X); x != "function" {
+			return x + "." + fun.Sel.Name
+		}
+	}
+	return "function"
+}
+
+func isNumeric(val constant.Value) bool {
+	k := val.Kind()
+	return k == constant.Int || k == constant.Float || k == constant.Complex
+}
+
+func unaryOp(op token.Token, val constant.Value) constant.Value {
+	switch {
+	case (op == token.ADD || op == token.SUB) && isNumeric(val):
+		return constant.UnaryOp(goTokens[op], val, 0)
+	case op == token.INVT && val.Kind() == constant.Int:
+		return constant.UnaryOp(gotoken.XOR, val, 0)
+	case op == token.NOT && val.Kind() == constant.Bool:
+		return constant.UnaryOp(gotoken.NOT, val, 0)
+	}
+	return constant.MakeUnknown()
+}
+
+func binaryOp(x constant.Value, op token.Token, opPos token.Pos, y constant.Value) (constant.Value, *constError) {
+	xk, yk := x.Kind(), y.Kind()
+	if xk == constant.Unknown || yk == constant.Unknown {
+		return constant.MakeUnknown(), nil
+	}
+	// ints and floats are never converted implicitly
+	sameNumeric := xk == yk && isNumeric(x)
+
+	switch op {
+	case token.LAND, token.LOR:
+		if xk == constant.Bool && yk == constant.Bool {
+			return constant.BinaryOp(x, goTokens[op], y), nil
+		}
+
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		ordered := sameNumeric && xk != constant.Complex || xk == constant.String && yk == constant.String
+		if ordered || (op == token.EQL || op == token.NEQ) && xk == yk {
+			return constant.MakeBool(constant.Compare(x, goTokens[op], y)), nil
+		}
+
+	case token.IN, token.NOT_IN:
+		if yk == constant.String && (xk == constant.String || xk == constant.Int) {
+			var s string
+			if xk == constant.String {
+				s = constant.StringVal(x)
+			} else if r, exact := constant.Int64Val(x); exact {
+				s = string(rune(r))
+			}
+			in := strings.Contains(constant.StringVal(y), s)
+			return constant.MakeBool(in == (op == token.IN)), nil
+		}
+
+	case token.SHL, token.SHR:
+		if xk == constant.Int && yk == constant.Int {
+			if constant.Sign(y) < 0 {
+				return nil, &constError{opPos, fmt.Sprintf("invalid negative shift count %s", y)}
+			}
+			s, exact := constant.Uint64Val(y)
+			if !exact || s > maxConstBits && op == token.SHL && constant.Sign(x) != 0 {
+				return nil, &constError{opPos, fmt.Sprintf("invalid shift count %s", y)}
+			}
+			if s > maxConstBits {
+				// all bits are shifted out
+				s = maxConstBits
+			}
+			return constant.Shift(x, goTokens[op], uint(s)), nil
+		}
+
+	case token.EXP:
+		if sameNumeric && xk != constant.Complex {
+			return exp(x, opPos, y)
+		}
+
+	case token.ADD:
+		if sameNumeric || xk == constant.String && yk == constant.String {
+			return constant.BinaryOp(x, gotoken.ADD, y), nil
+		}
+
+	case token.SUB, token.MUL:
+		if sameNumeric {
+			return constant.BinaryOp(x, goTokens[op], y), nil
+		}
+
+	case token.QUO, token.REM:
+		if sameNumeric && (op == token.QUO || xk == constant.Int) {
+			if constant.Sign(y) == 0 {
+				return nil, &constError{opPos, "invalid operation: division by zero"}
+			}
+			gop := goTokens[op]
+			if op == token.QUO && xk == constant.Int {
+				gop = gotoken.QUO_ASSIGN // force integer division
+			}
+			return constant.BinaryOp(x, gop, y), nil
+		}
+
+	case token.AND, token.OR, token.XOR, token.AND_NOT:
+		if xk == constant.Int && yk == constant.Int {
+			return constant.BinaryOp(x, goTokens[op], y), nil
+		}
+	}
+
+	// the operator is not defined on the operands;
+	// this is reported by the type checker
+	return constant.MakeUnknown(), nil
+}
+
+// exp returns x ** y for ints or floats x and y.
+func exp(x constant.Value, opPos token.Pos, y constant.Value) (constant.Value, *constError) {
+	if x.Kind() == constant.Float {
+		xf, _ := constant.Float64Val(x)
+		yf, _ := constant.Float64Val(y)
+		val := constant.MakeFloat64(math.Pow(xf, yf))
+		if val.Kind() == constant.Unknown {
+			// the result is infinite or not a number
+			return nil, &constError{opPos, fmt.Sprintf("constant %s ** %s overflows float", x, y)}
+		}
+		return val, nil
+	}
+
+	if constant.Sign(y) < 0 {
+		return nil, &constError{opPos, "negative exponent in constant exponentiation"}
+	}
+	base, _ := new(big.Int).SetString(x.ExactString(), 10)
+	n, exact := constant.Int64Val(y)
+	if bits := int64(base.BitLen()); bits > 1 && (!exact || n > maxConstBits || (bits-1)*n > maxConstBits) {
+		return nil, &constError{opPos, fmt.Sprintf("constant %s ** %s overflows", x, y)}
+	}
+
+	return constant.Make(new(big.Int).Exp(base, big.NewInt(n), nil)), nil
+}
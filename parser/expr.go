@@ -29,7 +29,13 @@ func (p *Parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
 		if oprec < prec1 {
 			return x
 		}
-		pos := p.expect(op)
+		var pos token.Pos
+		if op == token.NOT_IN {
+			pos = p.expect(token.NOT)
+			p.expect(token.IN)
+		} else {
+			pos = p.expect(op)
+		}
 		if lhs {
 			p.resolve(x)
 			lhs = false
@@ -108,21 +114,19 @@ func (p *Parser) checkExpr(x ast.Expr) ast.Expr {
 	case *ast.BadExpr:
 	case *ast.Ident:
 	case *ast.BasicLit:
-	//case *ast.FuncLit:
-	//case *ast.CompositeLit:
+	case *ast.InterpolatedString:
+	case *ast.FuncLit:
+	case *ast.ListLit:
+	case *ast.SetLit:
+	case *ast.MapLit:
+	case *ast.TupleLit:
 	case *ast.ParenExpr:
 		panic("unreachable")
-	/*case *ast.SelectorExpr:
+	case *ast.SelectorExpr:
 	case *ast.IndexExpr:
 	case *ast.SliceExpr:
 	case *ast.TypeAssertExpr:
-		// If t.Type == nil we have a type assertion of the form
-		// y.(type), which is only allowed in type switch expressions.
-		// It's hard to exclude those but for the case where we are in
-		// a type switch. Instead be lenient and test this in the type
-		// checker.
 	case *ast.CallExpr:
-	case *ast.StarExpr:*/
 	case *ast.UnaryExpr:
 	case *ast.BinaryExpr:
 	default:
@@ -148,26 +152,24 @@ func (p *Parser) parsePrimaryExpr(lhs bool) ast.Expr {
 	}
 
 	x := p.parseOperand(lhs)
-	/*L:
+L:
 	for {
 		switch p.tok {
 		case token.PERIOD:
-		p.next()
-		if lhs {
-			p.resolve(x)
-		}
-		switch p.tok {
-		case token.IDENT:
-			x = p.parseSelector(p.checkExprOrType(x))
-		case token.LPAREN:
-			x = p.parseTypeAssertion(p.checkExpr(x))
-		default:
-			pos := p.pos
-			p.errorExpected(pos, "selector or type assertion")
-			p.next() // make progress
-			sel := &ast.Ident{NamePos: pos, Name: "_"}
-			x = &ast.SelectorExpr{X: x, Sel: sel}
-		}
+			p.next()
+			if lhs {
+				p.resolve(x)
+			}
+			switch p.tok {
+			case token.IDENT:
+				x = p.parseSelector(p.checkExprOrType(x))
+			default:
+				pos := p.pos
+				p.errorExpected(pos, "selector")
+				p.next() // make progress
+				sel := &ast.Ident{NamePos: pos, Name: "_"}
+				x = &ast.SelectorExpr{X: x, Sel: sel}
+			}
 		case token.LBRACK:
 			if lhs {
 				p.resolve(x)
@@ -177,25 +179,174 @@ func (p *Parser) parsePrimaryExpr(lhs bool) ast.Expr {
 			if lhs {
 				p.resolve(x)
 			}
-			x = p.parseCallOrConversion(p.checkExprOrType(x))
-		case token.LBRACE:
-			if isLiteralType(x) && (p.exprLev >= 0 || !isTypeName(x)) {
-				if lhs {
-					p.resolve(x)
-				}
-				x = p.parseLiteralValue(x)
-			} else {
-				break L
+			x = p.parseCall(p.checkExprOrType(x))
+		case token.AS:
+			if lhs {
+				p.resolve(x)
 			}
+			x = p.parseTypeAssertion(p.checkExpr(x))
 		default:
 			break L
 		}
 		lhs = false // no need to try to resolve again
-	}*/
+	}
 
 	return x
 }
 
+func (p *Parser) parseFuncTypeOrLit() ast.Expr {
+	if p.trace {
+		defer un(trace(p, "FuncTypeOrLit"))
+	}
+
+	typ, scope := p.parseFuncType()
+	if p.tok != token.LBRACE {
+		// function type only
+		return typ
+	}
+
+	p.exprLev++
+	body := p.parseBody(scope)
+	p.exprLev--
+
+	return &ast.FuncLit{Type: typ, Body: body}
+}
+
+func (p *Parser) parseSelector(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "Selector"))
+	}
+
+	sel := p.parseIdent()
+
+	return &ast.SelectorExpr{X: x, Sel: sel}
+}
+
+func (p *Parser) parseTypeAssertion(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "TypeAssertion"))
+	}
+
+	as := p.expect(token.AS)
+	typ := p.parseType()
+
+	return &ast.TypeAssertExpr{X: x, As: as, Type: typ}
+}
+
+func (p *Parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "IndexOrSlice"))
+	}
+
+	const N = 3 // change the 3 to 2 to disable 3-index slices
+	lbrack := p.expect(token.LBRACK)
+	p.exprLev++
+	var index [N]ast.Expr
+	var colons [N - 1]token.Pos
+	if p.tok != token.COLON {
+		index[0] = p.parseRhs()
+	}
+	ncolons := 0
+	for p.tok == token.COLON && ncolons < len(colons) {
+		colons[ncolons] = p.pos
+		ncolons++
+		p.next()
+		if p.tok != token.COLON && p.tok != token.RBRACK && p.tok != token.EOF {
+			index[ncolons] = p.parseRhs()
+		}
+	}
+	p.exprLev--
+	rbrack := p.expect(token.RBRACK)
+
+	if ncolons > 0 {
+		// slice expression
+		slice3 := false
+		if ncolons == 2 {
+			slice3 = true
+			// Check presence of 2nd and 3rd index here rather than during type-checking
+			// so erroneous programs are rejected by the parser alone.
+			if index[1] == nil {
+				p.error(colons[0], "2nd index required in 3-index slice")
+				index[1] = &ast.BadExpr{From: colons[0] + 1, To: colons[1]}
+			}
+			if index[2] == nil {
+				p.error(colons[1], "3rd index required in 3-index slice")
+				index[2] = &ast.BadExpr{From: colons[1] + 1, To: rbrack}
+			}
+		}
+		return &ast.SliceExpr{X: x, Lbrack: lbrack, Low: index[0], High: index[1], Max: index[2], Slice3: slice3, Rbrack: rbrack}
+	}
+
+	if index[0] == nil {
+		p.errorExpected(p.pos, "operand")
+		index[0] = &ast.BadExpr{From: p.pos, To: p.pos}
+	}
+
+	return &ast.IndexExpr{X: x, Lbrack: lbrack, Index: index[0], Rbrack: rbrack}
+}
+
+func (p *Parser) parseCall(fun ast.Expr) *ast.CallExpr {
+	if p.trace {
+		defer un(trace(p, "Call"))
+	}
+
+	lparen := p.expect(token.LPAREN)
+	p.exprLev++
+	var list []ast.Expr
+	var ellipsis token.Pos
+	var kwarg bool // seen a keyword argument
+	for p.tok != token.RPAREN && p.tok != token.EOF && !ellipsis.IsValid() {
+		arg := p.parseCallArg()
+		if _, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			kwarg = true
+		} else if kwarg {
+			p.error(arg.Pos(), "positional argument follows keyword argument")
+		}
+		list = append(list, arg)
+		if p.tok == token.ELLIPSIS {
+			ellipsis = p.pos
+			p.next()
+		}
+		if !p.atComma("argument list", token.RPAREN) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	rparen := p.expectClosing(token.RPAREN, "argument list")
+
+	return &ast.CallExpr{Fun: fun, Lparen: lparen, Args: list, Ellipsis: ellipsis, Rparen: rparen}
+}
+
+// parseCallArg parses a positional argument or a keyword
+// argument of the form name=value.
+func (p *Parser) parseCallArg() ast.Expr {
+	if p.trace {
+		defer un(trace(p, "CallArg"))
+	}
+
+	// the argument is not parsed as a rhs expression so
+	// that "=" is not mistaken for "=="
+	old := p.inRhs
+	p.inRhs = false
+	x := p.parseExpr(true)
+	p.inRhs = old
+
+	if p.tok == token.ASSIGN {
+		pos := p.pos
+		p.next()
+		name, isIdent := x.(*ast.Ident)
+		if !isIdent {
+			p.errorExpected(x.Pos(), "parameter name")
+			name = &ast.Ident{NamePos: x.Pos(), Name: "_"}
+		}
+		return &ast.KeywordArg{Name: name, Assign: pos, Value: p.parseRhs()}
+	}
+	p.resolve(x)
+
+	return p.checkExprOrType(x)
+}
+
 // If lhs is set, result list elements which are identifiers are not resolved.
 func (p *Parser) parseExprList(lhs bool) (list []ast.Expr) {
 	if p.trace {
@@ -216,6 +367,13 @@ func (p *Parser) parseLhsList() []ast.Expr {
 	p.inRhs = false
 	list := p.parseExprList(true)
 	switch p.tok {
+	case token.ASSIGN:
+		// lhs of an assignment which may implicitly declare
+		// variables, but they don't enter scope until later:
+		// caller must call p.assignVarDecl at appropriate time
+	case token.IN:
+		// lhs of a for-in clause: loop variables
+		// are declared by the caller
 	case token.COLON:
 		// lhs of a label declaration or a communication clause of a select
 		// statement (parseLhsList is not called when parsing the case clause
@@ -235,6 +393,14 @@ func (p *Parser) parseLhsList() []ast.Expr {
 	return list
 }
 
+func (p *Parser) parseRhs() ast.Expr {
+	old := p.inRhs
+	p.inRhs = true
+	x := p.checkExpr(p.parseExpr(false))
+	p.inRhs = old
+	return x
+}
+
 func (p *Parser) parseRhsList() []ast.Expr {
 	old := p.inRhs
 	p.inRhs = true
@@ -243,6 +409,17 @@ func (p *Parser) parseRhsList() []ast.Expr {
 	return list
 }
 
+// parseAssignRhs parses the right side of an assignment to n
+// operands. A list of values assigned to a single operand is a
+// tuple literal without parentheses.
+func (p *Parser) parseAssignRhs(n int) []ast.Expr {
+	list := p.parseRhsList()
+	if n == 1 && len(list) > 1 {
+		return []ast.Expr{&ast.TupleLit{Elts: list}}
+	}
+	return list
+}
+
 // parseOperand may return an expression or a raw type (incl. array
 // types of the form [...]T. Callers must verify the result.
 // If lhs is set and the result is an identifier, it is not resolved.
@@ -254,27 +431,34 @@ func (p *Parser) parseOperand(lhs bool) ast.Expr {
 	switch p.tok {
 	case token.IDENT:
 		x := p.parseIdent()
+		if p.tok == token.LBRACK && containerTypes[x.Name] && !p.isDeclared(x.Name) {
+			// container type for a conversion or make
+			return p.parseContainerType(x)
+		}
 		if !lhs {
 			p.resolve(x)
 		}
 		return x
 
-	case token.INT, token.FLOAT, token.CHAR, token.STRING, token.RAW_STRING:
+	case token.STRING_HEAD:
+		return p.parseInterpolatedString()
+
+	case token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING, token.RAW_STRING:
 		x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
 		p.next()
 		return x
 
 	case token.LPAREN:
-		lparen := p.pos
-		p.next()
-		p.exprLev++
-		x := p.parseRhsOrType() // types may be parenthesized: (some type)
-		p.exprLev--
-		rparen := p.expect(token.RPAREN)
-		return &ast.ParenExpr{Lparen: lparen, Expr: x, Rparen: rparen}
+		return p.parseParenOrTuple()
+
+	case token.LBRACK:
+		return p.parseListLit()
 
-		/*case token.FUNC:
-		return p.parseFuncTypeOrLit()*/
+	case token.LBRACE:
+		return p.parseSetOrMapLit()
+
+	case token.FN:
+		return p.parseFuncTypeOrLit()
 	}
 
 	/*if typ := p.tryIdentOrType(); typ != nil {
@@ -291,10 +475,162 @@ func (p *Parser) parseOperand(lhs bool) ast.Expr {
 	return &ast.BadExpr{From: pos, To: p.pos}
 }
 
+func (p *Parser) parseInterpolatedString() *ast.InterpolatedString {
+	if p.trace {
+		defer un(trace(p, "InterpolatedString"))
+	}
+
+	var parts []ast.Expr
+	for {
+		parts = append(parts, &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit})
+		if p.tok == token.STRING_TAIL {
+			p.next()
+			break
+		}
+		p.next()
+
+		if p.tok == token.STRING_MID || p.tok == token.STRING_TAIL {
+			p.error(p.pos, "empty expression in string interpolation")
+		} else {
+			p.exprLev++
+			parts = append(parts, p.parseRhs())
+			p.exprLev--
+		}
+		if p.tok != token.STRING_MID && p.tok != token.STRING_TAIL {
+			p.errorExpected(p.pos, "'}'")
+			break
+		}
+	}
+
+	return &ast.InterpolatedString{Parts: parts}
+}
+
+// parseParenOrTuple parses a parenthesized expression or type, or a
+// tuple literal: (), (x,) or (x, y).
+func (p *Parser) parseParenOrTuple() ast.Expr {
+	if p.trace {
+		defer un(trace(p, "ParenOrTuple"))
+	}
+
+	lparen := p.expect(token.LPAREN)
+	p.exprLev++
+	if p.tok == token.RPAREN {
+		// empty tuple
+		p.exprLev--
+		rparen := p.expect(token.RPAREN)
+		return &ast.TupleLit{Lparen: lparen, Rparen: rparen}
+	}
+
+	x := p.parseRhsOrType() // types may be parenthesized: (some type)
+	if p.tok != token.COMMA {
+		p.exprLev--
+		rparen := p.expect(token.RPAREN)
+		return &ast.ParenExpr{Lparen: lparen, Expr: x, Rparen: rparen}
+	}
+
+	list := []ast.Expr{p.checkExpr(x)}
+	for p.tok == token.COMMA {
+		p.next()
+		if p.tok == token.RPAREN {
+			break // trailing comma
+		}
+		list = append(list, p.parseRhs())
+	}
+	p.exprLev--
+	rparen := p.expectClosing(token.RPAREN, "tuple literal")
+
+	return &ast.TupleLit{Lparen: lparen, Elts: list, Rparen: rparen}
+}
+
+func (p *Parser) parseListLit() *ast.ListLit {
+	if p.trace {
+		defer un(trace(p, "ListLit"))
+	}
+
+	lbrack := p.expect(token.LBRACK)
+	p.exprLev++
+	var list []ast.Expr
+	for p.tok != token.RBRACK && p.tok != token.EOF {
+		list = append(list, p.parseRhs())
+		if !p.atComma("list literal", token.RBRACK) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	rbrack := p.expectClosing(token.RBRACK, "list literal")
+
+	return &ast.ListLit{Lbrack: lbrack, Elts: list, Rbrack: rbrack}
+}
+
+// parseSetOrMapLit parses a set or map literal. The literal is a map
+// literal if its first element is a key-value pair or if it is the
+// empty map literal {:}, otherwise it is a set literal.
+func (p *Parser) parseSetOrMapLit() ast.Expr {
+	if p.trace {
+		defer un(trace(p, "SetOrMapLit"))
+	}
+
+	lbrace := p.expect(token.LBRACE)
+	p.exprLev++
+	defer func() { p.exprLev-- }()
+
+	if p.tok == token.COLON {
+		// empty map
+		p.next()
+		rbrace := p.expectClosing(token.RBRACE, "map literal")
+		return &ast.MapLit{Lbrace: lbrace, Rbrace: rbrace}
+	}
+	if p.tok == token.RBRACE {
+		// empty set
+		rbrace := p.expect(token.RBRACE)
+		return &ast.SetLit{Lbrace: lbrace, Rbrace: rbrace}
+	}
+
+	x := p.parseRhs()
+	if p.tok == token.COLON {
+		var list []*ast.KeyValueExpr
+		for {
+			colon := p.expect(token.COLON)
+			list = append(list, &ast.KeyValueExpr{Key: x, Colon: colon, Value: p.parseRhs()})
+			if !p.atComma("map literal", token.RBRACE) {
+				break
+			}
+			p.next()
+			if p.tok == token.RBRACE || p.tok == token.EOF {
+				break
+			}
+			x = p.parseRhs()
+		}
+		rbrace := p.expectClosing(token.RBRACE, "map literal")
+
+		return &ast.MapLit{Lbrace: lbrace, Elts: list, Rbrace: rbrace}
+	}
+
+	list := []ast.Expr{x}
+	for p.atComma("set literal", token.RBRACE) {
+		p.next()
+		if p.tok == token.RBRACE || p.tok == token.EOF {
+			break
+		}
+		list = append(list, p.parseRhs())
+	}
+	rbrace := p.expectClosing(token.RBRACE, "set literal")
+
+	return &ast.SetLit{Lbrace: lbrace, Elts: list, Rbrace: rbrace}
+}
+
 func (p *Parser) tokPrec() (token.Token, int) {
 	tok := p.tok
-	if p.inRhs && tok == token.ASSIGN {
+	switch {
+	case p.inRhs && tok == token.ASSIGN:
 		tok = token.EQL
+	case tok == token.NOT:
+		// "not" following an operand can only start "not in"
+		tok = token.NOT_IN
+	case tok == token.IN && p.inForHeader && p.exprLev < 0:
+		// "in" starts the in clause of a for-in statement
+		return tok, token.LowestPrec
 	}
 	return tok, tok.Precedence()
 }
@@ -0,0 +1,129 @@
+// This file contains the exported entry points for invoking the parser.
+
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/token"
+)
+
+// If src != nil, readSource converts src to a []byte if possible;
+// otherwise it returns an error. If src == nil, readSource returns
+// the result of reading the file specified by filename.
+func readSource(filename string, src interface{}) ([]byte, error) {
+	if src != nil {
+		switch s := src.(type) {
+		case string:
+			return []byte(s), nil
+		case []byte:
+			return s, nil
+		case *bytes.Buffer:
+			// is io.Reader, but src is already available in []byte form
+			if s != nil {
+				return s.Bytes(), nil
+			}
+		case io.Reader:
+			return ioutil.ReadAll(s)
+		}
+		return nil, errors.New("invalid source")
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// A Mode value is a set of flags (or 0).
+// They control optional parser functionality.
+type Mode uint
+
+const (
+	ParseComments     Mode = 1 << iota // parse comments and add them to AST
+	Trace                              // print a trace of parsed productions
+	DeclarationErrors                  // report declaration errors
+	AllErrors                          // report all errors (not just the first 10 on different lines)
+)
+
+// ParseFile parses the source code of a single Rose source file and
+// returns the corresponding ast.File node. The source code may be
+// provided via the filename of the source file, or via the src
+// parameter.
+//
+// If src != nil, ParseFile parses the source from src and the filename
+// is only used when recording position information. The type of the
+// argument for the src parameter must be string, []byte, or io.Reader.
+// If src == nil, ParseFile parses the file specified by filename.
+//
+// The mode parameter controls optional parser functionality. Position
+// information is recorded in the file set fset, which must not be nil.
+//
+// If the source couldn't be read, the returned AST is nil and the error
+// indicates the specific failure. If the source was read but syntax
+// errors were found, the result is a partial AST (with ast.Bad* nodes
+// representing the fragments of erroneous source code). Multiple errors
+// are returned via a diagnostics.List which is sorted by source position.
+func ParseFile(fset *token.FileSet, filename string, src interface{}, mode Mode) (f *ast.File, err error) {
+	if fset == nil {
+		panic("parser.ParseFile: no token.FileSet provided (fset == nil)")
+	}
+	return parseFile(fset, filename, src, mode, nil)
+}
+
+// ParseFileInScope is like ParseFile, but the statements of the file
+// are parsed in scope instead of a new file scope: the objects declared
+// at the top level of the file are inserted into scope, and identifiers
+// are resolved to the objects of scope if they are not declared in the
+// file. ParseFileInScope allows a program to be parsed piece by piece,
+// as in an interactive session.
+func ParseFileInScope(fset *token.FileSet, filename string, src interface{}, mode Mode, scope *ast.Scope) (f *ast.File, err error) {
+	if fset == nil {
+		panic("parser.ParseFileInScope: no token.FileSet provided (fset == nil)")
+	}
+	if scope == nil {
+		panic("parser.ParseFileInScope: no scope provided (scope == nil)")
+	}
+	return parseFile(fset, filename, src, mode, scope)
+}
+
+// parseFile parses a file in scope, or in a new file scope if scope
+// is nil.
+func parseFile(fset *token.FileSet, filename string, src interface{}, mode Mode, scope *ast.Scope) (f *ast.File, err error) {
+	// get source
+	text, err := readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Parser
+	defer func() {
+		if e := recover(); e != nil {
+			// resume same panic if it's not a bailout
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+		}
+
+		// set result values
+		if f == nil {
+			// source is not a valid Rose source file - satisfy
+			// ParseFile API and return a valid (but) empty
+			// *ast.File
+			f = &ast.File{
+				Name:  new(ast.Ident),
+				Scope: ast.NewScope(nil),
+			}
+		}
+
+		p.errors.Sort()
+		err = p.errors.Err()
+	}()
+
+	// parse source
+	p.init(fset, filename, text, mode)
+	p.topScope = scope
+	f = p.parseFile()
+
+	return
+}
@@ -2,10 +2,9 @@ package parser
 
 import (
 	"fmt"
-	"io"
-	"text/scanner"
 
 	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
 	"github.com/capnspacehook/rose/lexer"
 	"github.com/capnspacehook/rose/token"
 )
@@ -18,21 +17,36 @@ var typeNames = map[string]bool{
 	"string": true,
 }
 
+// containerTypes are the names of the predeclared container
+// types which are followed by their element types in brackets.
+var containerTypes = map[string]bool{
+	"list":  true,
+	"map":   true,
+	"set":   true,
+	"tuple": true,
+}
+
 var boolConsts = map[string]bool{
 	"false": false,
 	"true":  true,
 }
 
 type Parser struct {
+	fset   *token.FileSet
 	file   *token.File
-	errors lexer.ErrorList
+	errors diagnostics.List
 	lexer  lexer.Lexer
 
 	// Tracing/debugging
-	//mode   Mode // parsing mode
+	mode   Mode // parsing mode
 	trace  bool // == (mode & Trace != 0)
 	indent int  // indentation used for tracing output
 
+	// Comments
+	comments    []*ast.CommentGroup
+	leadComment *ast.CommentGroup // last lead comment
+	lineComment *ast.CommentGroup // last line comment
+
 	// Next token
 	pos token.Pos   // token position
 	tok token.Token // one token look-ahead
@@ -46,8 +60,15 @@ type Parser struct {
 	syncCnt int       // number of parser.advance calls without progress
 
 	// Non-syntactic parser control
-	exprLev int  // < 0: in control clause, >= 0: in expression
-	inRhs   bool // if set, the parser is parsing a rhs expression
+	exprLev     int  // < 0: in control clause, >= 0: in expression
+	inRhs       bool // if set, the parser is parsing a rhs expression
+	inForHeader bool // if set, "in" is not an operator outside of parentheses
+	funcLev     int  // function nesting level
+	loopLev     int  // for loop nesting level of the innermost function
+	switchLev   int  // switch nesting level of the innermost function
+
+	// Constant declarations
+	lastConst *ast.ValueSpec // last const spec with values in the current group; or nil
 
 	// Ordinary identifier scopes
 	pkgScope   *ast.Scope   // pkgScope.Outer == nil
@@ -77,17 +98,43 @@ func (p *Parser) declare(decl, data interface{}, scope *ast.Scope, kind ast.ObjK
 		obj.Data = data
 		ident.Obj = obj
 		if ident.Name != "_" {
-			if alt := scope.Insert(obj); alt != nil /*&& p.mode&DeclarationErrors != 0*/ {
-				prevDecl := ""
+			if alt := scope.Insert(obj); alt != nil && p.mode&DeclarationErrors != 0 {
+				d := &diagnostics.Diagnostic{
+					Code: diagnostics.Redeclared,
+					Span: diagnostics.Span{Pos: ident.Pos(), End: ident.End()},
+					Msg:  ident.Name + " redeclared in this block",
+				}
 				if pos := alt.Pos(); pos.IsValid() {
-					prevDecl = fmt.Sprintf("\n\tprevious declaration at %s", p.file.Position(pos))
+					d.Related = []diagnostics.Related{{
+						Pos:  p.fset.Position(pos), // may be in a previously parsed file
+						Span: diagnostics.Span{Pos: pos, End: pos + token.Pos(len(alt.Name))},
+						Msg:  "previous declaration",
+					}}
 				}
-				p.error(ident.Pos(), fmt.Sprintf("%s redeclared in this block%s", ident.Name, prevDecl))
+				p.report(d)
 			}
 		}
 	}
 }
 
+// assignVarDecl resolves the identifiers on the left side of the
+// assignment decl. Identifiers that do not denote an object in any
+// enclosing scope are implicitly declared as variables in the current
+// scope.
+func (p *Parser) assignVarDecl(decl *ast.AssignStmt, list []ast.Expr) {
+	for _, x := range list {
+		ident, isIdent := x.(*ast.Ident)
+		if !isIdent {
+			p.resolve(x)
+			continue
+		}
+		p.tryResolve(ident, false)
+		if ident.Obj == nil {
+			p.declare(decl, nil, p.topScope, ast.Var, ident)
+		}
+	}
+}
+
 // The unresolved object is a sentinel to mark identifiers that have been added
 // to the list of unresolved identifiers. The sentinel is only used for verifying
 // internal consistency.
@@ -129,6 +176,34 @@ func (p *Parser) resolve(x ast.Expr) {
 	p.tryResolve(x, true)
 }
 
+// isDeclared reports whether name denotes an object
+// declared in the current or any enclosing scope.
+func (p *Parser) isDeclared(name string) bool {
+	for s := p.topScope; s != nil; s = s.Outer {
+		if s.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) init(fset *token.FileSet, filename string, src []byte, mode Mode) {
+	p.fset = fset
+	p.file = fset.AddFile(filename, -1, len(src))
+	eh := func(pos token.Position, msg string) {
+		p.errors.Report(&diagnostics.Diagnostic{
+			Code: diagnostics.SyntaxError,
+			Pos:  pos,
+			Span: diagnostics.Span{Pos: p.file.Pos(pos.Offset)},
+			Msg:  msg,
+		})
+	}
+	p.lexer.Init(p.file, src, eh, mode&ParseComments != 0)
+
+	p.mode = mode
+	p.trace = mode&Trace != 0 // for convenience (p.trace is used frequently)
+}
+
 // ----------------------------------------------------------------------------
 // Parsing support
 
@@ -186,23 +261,34 @@ func (p *Parser) next0() {
 // A bailout panic is raised to indicate early termination.
 type bailout struct{}
 
+// error reports the syntax error msg at pos.
 func (p *Parser) error(pos token.Pos, msg string) {
-	epos := p.file.Position(pos)
+	p.errorCode(pos, diagnostics.SyntaxError, msg)
+}
+
+// errorCode reports the error msg with the diagnostic code code at pos.
+func (p *Parser) errorCode(pos token.Pos, code, msg string) {
+	p.report(&diagnostics.Diagnostic{Code: code, Span: diagnostics.Span{Pos: pos}, Msg: msg})
+}
+
+// report records the error d, whose position is that of its primary span.
+func (p *Parser) report(d *diagnostics.Diagnostic) {
+	d.Pos = p.file.Position(d.Span.Pos)
 
 	// If AllErrors is not set, discard errors reported on the same line
 	// as the last recorded error and stop parsing if there are more than
 	// 10 errors.
-	//if p.mode&AllErrors == 0 {
-	n := len(p.errors)
-	if n > 0 && p.errors[n-1].Pos.Line == epos.Line {
-		return // discard - likely a spurious error
-	}
-	if n > 10 {
-		panic(bailout{})
+	if p.mode&AllErrors == 0 {
+		n := len(p.errors)
+		if n > 0 && p.errors[n-1].Pos.Line == d.Pos.Line {
+			return // discard - likely a spurious error
+		}
+		if n > 10 {
+			panic(bailout{})
+		}
 	}
-	//}
 
-	p.errors.Add(scanner.Position(epos), msg)
+	p.errors.Report(d)
 }
 
 func (p *Parser) errorExpected(pos token.Pos, msg string) {
@@ -226,8 +312,93 @@ func (p *Parser) errorExpected(pos token.Pos, msg string) {
 // ----------------------------------------------------------------------------
 // Parsing helpers
 
+// Consume a comment and return it and the line on which it ends.
+func (p *Parser) consumeComment() (comment *ast.Comment, endline int) {
+	// /*-style comments may end on a different line than where they start.
+	// Scan the comment for '\n' chars and adjust endline accordingly.
+	endline = p.file.Line(p.pos)
+	if p.lit[1] == '*' {
+		// don't use range here - no need to decode Unicode code points
+		for i := 0; i < len(p.lit); i++ {
+			if p.lit[i] == '\n' {
+				endline++
+			}
+		}
+	}
+
+	comment = &ast.Comment{Slash: p.pos, Text: p.lit}
+	p.next0()
+
+	return
+}
+
+// Consume a group of adjacent comments, add it to the parser's
+// comments list, and return it together with the line at which
+// the last comment in the group ends. A non-comment token or n
+// empty lines terminate a comment group.
+func (p *Parser) consumeCommentGroup(n int) (comments *ast.CommentGroup, endline int) {
+	var list []*ast.Comment
+	endline = p.file.Line(p.pos)
+	for p.tok == token.COMMENT && p.file.Line(p.pos) <= endline+n {
+		var comment *ast.Comment
+		comment, endline = p.consumeComment()
+		list = append(list, comment)
+	}
+
+	// add comment group to the comments list
+	comments = &ast.CommentGroup{List: list}
+	p.comments = append(p.comments, comments)
+
+	return
+}
+
+// Advance to the next non-comment token. In the process, collect
+// any comment groups encountered, and remember the last lead and
+// line comments.
+//
+// A lead comment is a comment group that starts and ends in a
+// line without any other tokens and that is followed by a non-comment
+// token on the line immediately after the comment group.
+//
+// A line comment is a comment group that follows a non-comment
+// token on the same line, and that has no tokens after it on the line
+// where it ends.
+//
+// Lead and line comments may be considered documentation that is
+// stored in the AST.
 func (p *Parser) next() {
-	p.pos, p.tok, p.lit = p.lexer.Lex()
+	p.leadComment = nil
+	p.lineComment = nil
+	prev := p.pos
+	p.next0()
+
+	if p.tok == token.COMMENT {
+		var comment *ast.CommentGroup
+		var endline int
+
+		if p.file.Line(p.pos) == p.file.Line(prev) {
+			// The comment is on same line as the previous token; it
+			// cannot be a lead comment but may be a line comment.
+			comment, endline = p.consumeCommentGroup(0)
+			if p.file.Line(p.pos) != endline || p.tok == token.EOF {
+				// The next token is on a different line, thus
+				// the last comment group is a line comment.
+				p.lineComment = comment
+			}
+		}
+
+		// consume successor comments, if any
+		endline = -1
+		for p.tok == token.COMMENT {
+			comment, endline = p.consumeCommentGroup(1)
+		}
+
+		if endline+1 == p.file.Line(p.pos) {
+			// The next token is following on the line immediately after the
+			// comment group, thus the last comment group is a lead comment.
+			p.leadComment = comment
+		}
+	}
 }
 
 func (p *Parser) expect(tok token.Token) token.Pos {
@@ -240,6 +411,16 @@ func (p *Parser) expect(tok token.Token) token.Pos {
 	return pos
 }
 
+// expectClosing is like expect but provides a better error message
+// for the common case of a missing comma before a newline.
+func (p *Parser) expectClosing(tok token.Token, context string) token.Pos {
+	if p.tok != tok && p.tok == token.SEMI && p.lit == "\n" {
+		p.missingComma("missing ',' before newline in " + context)
+		p.next()
+	}
+	return p.expect(tok)
+}
+
 func (p *Parser) expectSemi() {
 	// semicolon is optional before a closing ')' or '}'
 	if p.tok != token.RPAREN && p.tok != token.RBRACE {
@@ -252,6 +433,33 @@ func (p *Parser) expectSemi() {
 	}
 }
 
+func (p *Parser) atComma(context string, follow token.Token) bool {
+	if p.tok == token.COMMA {
+		return true
+	}
+	if p.tok != follow {
+		msg := "missing ','"
+		if p.tok == token.SEMI && p.lit == "\n" {
+			msg += " before newline"
+		}
+		p.missingComma(msg + " in " + context)
+		return true // "insert" comma and continue
+	}
+	return false
+}
+
+// missingComma reports the error msg at the current position
+// and suggests inserting the missing comma there.
+func (p *Parser) missingComma(msg string) {
+	span := diagnostics.Span{Pos: p.pos, End: p.pos}
+	p.report(&diagnostics.Diagnostic{
+		Code:  diagnostics.SyntaxError,
+		Span:  span,
+		Msg:   msg,
+		Fixes: []diagnostics.Fix{{Msg: "insert ','", Span: span, NewText: ","}},
+	})
+}
+
 func assert(cond bool, msg string) {
 	if !cond {
 		panic("rose/parser internal error: " + msg)
@@ -294,14 +502,15 @@ var stmtStart = map[token.Token]bool{
 	token.CONTINUE: true,
 	//token.DEFER:       true,
 	token.FALLTHROUGH: true,
-	//token.FOR:         true,
+	token.FN:          true,
+	token.FOR:         true,
 	//token.GO:          true,
 	//token.GOTO:        true,
 	token.IF:     true,
 	token.LET:    true,
 	token.RETURN: true,
 	//token.SELECT:      true,
-	//token.SWITCH:      true,
+	token.SWITCH: true,
 	//token.TYPE:        true,
 	token.VAR: true,
 }
@@ -411,24 +620,87 @@ func (p *Parser) parseTypeName() ast.Expr {
 	return ident
 }
 
+func (p *Parser) parseElemType() (lbrack token.Pos, elt ast.Expr, rbrack token.Pos) {
+	lbrack = p.expect(token.LBRACK)
+	elt = p.parseType()
+	rbrack = p.expect(token.RBRACK)
+	return
+}
+
+func (p *Parser) parseMapType(name *ast.Ident) *ast.MapType {
+	if p.trace {
+		defer un(trace(p, "MapType"))
+	}
+
+	lbrack, key, rbrack := p.parseElemType()
+	value := p.parseType()
+
+	return &ast.MapType{Map: name.Pos(), Lbrack: lbrack, Key: key, Rbrack: rbrack, Value: value}
+}
+
+func (p *Parser) parseTupleType(name *ast.Ident) *ast.TupleType {
+	if p.trace {
+		defer un(trace(p, "TupleType"))
+	}
+
+	lbrack := p.expect(token.LBRACK)
+	var list []ast.Expr
+	for p.tok != token.RBRACK && p.tok != token.EOF {
+		list = append(list, p.parseType())
+		if !p.atComma("tuple type", token.RBRACK) {
+			break
+		}
+		p.next()
+	}
+	rbrack := p.expectClosing(token.RBRACK, "tuple type")
+
+	return &ast.TupleType{Tuple: name.Pos(), Lbrack: lbrack, Elts: list, Rbrack: rbrack}
+}
+
+// parseContainerType parses the bracketed element types following
+// the name of a container type.
+func (p *Parser) parseContainerType(name *ast.Ident) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "ContainerType"))
+	}
+
+	switch name.Name {
+	case "list":
+		lbrack, elt, rbrack := p.parseElemType()
+		return &ast.ListType{List: name.Pos(), Lbrack: lbrack, Elt: elt, Rbrack: rbrack}
+	case "set":
+		lbrack, elt, rbrack := p.parseElemType()
+		return &ast.SetType{Set: name.Pos(), Lbrack: lbrack, Elt: elt, Rbrack: rbrack}
+	case "map":
+		return p.parseMapType(name)
+	case "tuple":
+		return p.parseTupleType(name)
+	}
+	panic("unreachable")
+}
+
 // If the result is an identifier, it is not resolved.
 func (p *Parser) tryIdentOrType() ast.Expr {
 	switch p.tok {
 	case token.IDENT:
-		return p.parseTypeName()
+		typ := p.parseTypeName()
+		if ident, isIdent := typ.(*ast.Ident); isIdent && p.tok == token.LBRACK && containerTypes[ident.Name] {
+			return p.parseContainerType(ident)
+		}
+		return typ
 	/*case token.LBRACK:
 		return p.parseArrayType()
 	case token.STRUCT:
 		return p.parseStructType()
-	case token.FUNC:
-		typ, _ := p.parseFuncType()
-		return typ
 	case token.INTERFACE:
 		return p.parseInterfaceType()
 	case token.MAP:
 		return p.parseMapType()
 	case token.CHAN, token.ARROW:
 		return p.parseChanType()*/
+	case token.FN:
+		typ, _ := p.parseFuncType()
+		return typ
 	case token.LPAREN:
 		lparen := p.pos
 		p.next()
@@ -449,40 +721,191 @@ func (p *Parser) tryType() ast.Expr {
 	return typ
 }
 
-func ParseFile(file *token.File, src io.Reader) (f *ast.File, err error) {
-	var p Parser
-	p.file = file
-	eh := func(pos token.Position, msg string) { p.errors.Add(scanner.Position(pos), msg) }
-	p.lexer.Init(p.file, src, eh, false)
+func (p *Parser) parseParameterList(scope *ast.Scope) (params []*ast.Field) {
+	if p.trace {
+		defer un(trace(p, "ParameterList"))
+	}
 
-	defer func() {
-		if e := recover(); e != nil {
-			// resume same panic if it's not a bailout
-			if _, ok := e.(bailout); !ok {
-				panic(e)
+	// Parameter types and default values are optional. Consecutive
+	// names without a type or default value share the type of the
+	// following parameter, like in "x, y int". Names that are not
+	// followed by a type are of type any.
+	var names []*ast.Ident // names without a type, default or "..."
+	var optional, variadic *ast.Field
+	flush := func() {
+		if len(names) > 0 {
+			params = append(params, &ast.Field{Names: names})
+			names = nil
+		}
+	}
+	for p.tok != token.RPAREN && p.tok != token.EOF {
+		var ellipsis token.Pos
+		if p.tok == token.ELLIPSIS {
+			ellipsis = p.pos
+			p.next()
+		}
+		name := p.parseIdent()
+		var typ ast.Expr
+		if p.tok != token.COMMA && p.tok != token.RPAREN && p.tok != token.ASSIGN {
+			typ = p.parseType()
+		}
+		var assign token.Pos
+		var def ast.Expr
+		if p.tok == token.ASSIGN {
+			assign = p.pos
+			p.next()
+			def = p.parseRhs()
+		}
+
+		if variadic != nil {
+			p.error(variadic.Pos(), "can only use ... with final parameter in list")
+			variadic = nil
+		}
+
+		var field *ast.Field
+		switch {
+		case ellipsis.IsValid():
+			flush()
+			field = &ast.Field{Names: []*ast.Ident{name}, Ellipsis: ellipsis, Type: typ}
+			variadic = field
+			if def != nil {
+				p.error(assign, "variadic parameter "+name.Name+" cannot have a default value")
+			}
+		case def != nil:
+			flush()
+			field = &ast.Field{Names: []*ast.Ident{name}, Type: typ, Assign: assign, Default: def}
+			optional = field
+		case typ != nil:
+			field = &ast.Field{Names: append(names, name), Type: typ}
+			names = nil
+		default:
+			names = append(names, name)
+		}
+		if field != nil {
+			if optional != nil && field != optional && !ellipsis.IsValid() {
+				p.error(field.Pos(), "positional parameter "+field.Names[0].Name+" follows optional parameter")
 			}
+			params = append(params, field)
+		} else if optional != nil {
+			p.error(name.Pos(), "positional parameter "+name.Name+" follows optional parameter")
+		}
+
+		if !p.atComma("parameter list", token.RPAREN) {
+			break
 		}
+		p.next()
+	}
+	flush()
+
+	// declare the parameters in the function scope
+	for _, field := range params {
+		p.declare(field, nil, scope, ast.Var, field.Names...)
+	}
+
+	return
+}
+
+func (p *Parser) parseParameters(scope *ast.Scope) *ast.FieldList {
+	if p.trace {
+		defer un(trace(p, "Parameters"))
+	}
+
+	var params []*ast.Field
+	lparen := p.expect(token.LPAREN)
+	if p.tok != token.RPAREN {
+		params = p.parseParameterList(scope)
+	}
+	rparen := p.expectClosing(token.RPAREN, "parameter list")
 
-		// set result values
-		if f == nil {
-			// source is not a valid Rose source file - satisfy
-			// ParseFile API and return a valid (but) empty
-			// *ast.File
-			f = &ast.File{
-				Name:  new(ast.Ident),
-				Scope: ast.NewScope(nil),
+	return &ast.FieldList{Opening: lparen, List: params, Closing: rparen}
+}
+
+func (p *Parser) parseResult() *ast.FieldList {
+	if p.trace {
+		defer un(trace(p, "Result"))
+	}
+
+	if p.tok == token.LPAREN {
+		// parenthesized list of result types
+		lparen := p.pos
+		p.next()
+		var list []*ast.Field
+		for p.tok != token.RPAREN && p.tok != token.EOF {
+			list = append(list, &ast.Field{Type: p.parseType()})
+			if !p.atComma("result list", token.RPAREN) {
+				break
 			}
+			p.next()
 		}
+		rparen := p.expectClosing(token.RPAREN, "result list")
 
-		p.errors.Sort()
-		err = p.errors.Err()
-	}()
+		return &ast.FieldList{Opening: lparen, List: list, Closing: rparen}
+	}
 
-	f = p.parseFile()
+	typ := p.tryType()
+	if typ != nil {
+		list := make([]*ast.Field, 1)
+		list[0] = &ast.Field{Type: typ}
+		return &ast.FieldList{List: list}
+	}
+
+	return nil
+}
+
+// parseSignature parses the parameters and results of a function.
+// The parentheses around the parameters may be omitted if the
+// function has no parameters and results.
+func (p *Parser) parseSignature(scope *ast.Scope) (params, results *ast.FieldList) {
+	if p.trace {
+		defer un(trace(p, "Signature"))
+	}
+
+	if p.tok != token.LPAREN {
+		return new(ast.FieldList), nil
+	}
+	params = p.parseParameters(scope)
+	results = p.parseResult()
 
 	return
 }
 
+func (p *Parser) parseFuncType() (*ast.FuncType, *ast.Scope) {
+	if p.trace {
+		defer un(trace(p, "FuncType"))
+	}
+
+	pos := p.expect(token.FN)
+	scope := ast.NewScope(p.topScope) // function scope
+	params, results := p.parseSignature(scope)
+
+	return &ast.FuncType{Func: pos, Params: params, Results: results}, scope
+}
+
+// ----------------------------------------------------------------------------
+// Blocks
+
+func (p *Parser) parseBody(scope *ast.Scope) *ast.BlockStmt {
+	if p.trace {
+		defer un(trace(p, "Body"))
+	}
+
+	// loops do not extend into function bodies
+	oldLoopLev, oldSwitchLev, oldInForHeader := p.loopLev, p.switchLev, p.inForHeader
+	p.loopLev, p.switchLev, p.inForHeader = 0, 0, false
+	p.funcLev++
+
+	lbrace := p.expect(token.LBRACE)
+	p.topScope = scope // open function scope
+	list := p.parseStmtList()
+	p.closeScope()
+	rbrace := p.expect(token.RBRACE)
+
+	p.funcLev--
+	p.loopLev, p.switchLev, p.inForHeader = oldLoopLev, oldSwitchLev, oldInForHeader
+
+	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
+}
+
 func (p *Parser) parseFile() *ast.File {
 	if p.trace {
 		defer un(trace(p, "File"))
@@ -496,15 +919,26 @@ func (p *Parser) parseFile() *ast.File {
 		return nil
 	}
 
-	p.openScope()
+	if p.topScope == nil {
+		// not parsing in the scope of a previous file
+		p.openScope()
+	}
 	var stmts []ast.Stmt
 	for p.tok != token.EOF {
 		stmts = append(stmts, p.parseStmt())
 	}
 	p.closeScope()
 
+	// identifiers that could not be resolved denote predeclared
+	// entities or are undeclared, which is left to the type checker
+	for _, ident := range p.unresolved {
+		assert(ident.Obj == unresolved, "object already resolved")
+		ident.Obj = nil // remove unresolved sentinel
+	}
+
 	return &ast.File{
 		Stmts:      stmts,
 		Unresolved: p.unresolved,
+		Comments:   p.comments,
 	}
 }
@@ -1,12 +1,17 @@
 package parser_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
 	"github.com/capnspacehook/rose/parser"
 	"github.com/capnspacehook/rose/token"
 
@@ -84,6 +89,148 @@ func TestConstDecls(t *testing.T) {
 	expectParseError(t, "const a = 'v', 0.9", "<input>:1:7: extra expression in const declaration")
 }
 
+func TestConstValues(t *testing.T) {
+	input := `const a = 1 << 62
+const b = a / 3 * 2 + a % 7
+const c = (1 << 100) >> 98
+const d = 2 ** 10 - -~3
+const e = 1.5 * 4.0
+const f = "ro" + "se"
+const g = 'a' + 1
+const h = a > b and not (f == "go" or "s" not in f)
+const i = 10 / 4
+const j = [a, b]
+const k = 0x_FF + 0b11 + 0o7 + 1_000
+const m = 1.5i * 2i
+const n = "x{{y}}" + "}}"`
+	f := parseFile(t, input)
+
+	expected := map[string]string{
+		"a": "4611686018427387904",
+		"b": "3074457345618258606",
+		"c": "4",
+		"d": "1020",
+		"e": "6",
+		"f": `"rose"`,
+		"g": "98",
+		"h": "true",
+		"i": "2",
+		"j": "unknown",
+		"k": "1265",
+		"m": "(-3 + 0i)",
+		"n": `"x{y}}"`,
+	}
+	objs := declaredObjects(f)
+	require.Len(t, objs, len(expected))
+	for name, val := range expected {
+		obj := objs[name]
+		require.NotNil(t, obj, name)
+		require.NotNil(t, obj.Value, name)
+		require.Equal(t, val, obj.Value.ExactString(), name)
+	}
+
+	// let and var declarations are not folded
+	objs = declaredObjects(parseFile(t, "let x = 1\nvar y int"))
+	require.Equal(t, ast.Let, objs["x"].Kind)
+	require.Nil(t, objs["x"].Value)
+	require.Equal(t, ast.Var, objs["y"].Kind)
+	require.Nil(t, objs["y"].Value)
+
+	expectParseError(t, "const x = f()", "<input>:1:11: call to f is not constant")
+	expectParseError(t, "const x = 1 + strings.len(\"a\")", "<input>:1:15: call to strings.len is not constant")
+	expectParseError(t, "let a = 1; const b = a + 1", "<input>:1:22: a is not constant")
+	expectParseError(t, "const x = y", "<input>:1:11: y is not constant")
+	expectParseError(t, "const x = [1, y]", "<input>:1:15: y is not constant")
+	expectParseError(t, "const x = l[0]", "<input>:1:11: index expression is not constant")
+	expectParseError(t, "const x = 1 / 0", "<input>:1:13: invalid operation: division by zero")
+	expectParseError(t, "const x = 1 << -1", "<input>:1:13: invalid negative shift count -1")
+	expectParseError(t, "const x = 1 << 63", "<input>:1:11: constant 9223372036854775808 overflows int")
+	expectParseError(t, "const x = 2 ** 1000", "<input>:1:13: constant 2 ** 1000 overflows")
+	expectParseError(t, "const x = 2 ** -1", "<input>:1:13: negative exponent in constant exponentiation")
+}
+
+func TestConstIota(t *testing.T) {
+	input := `const (
+	a = iota
+	b
+	_
+	d, e = iota * 10, 1 << iota
+	f, g
+)`
+	expectParse(t, input, func(p pfn) []ast.Stmt {
+		return stmts(
+			constDecl(p(1, 1), p(1, 7), p(7, 1),
+				valueSpec(
+					idents(ident(p(2, 2), "a")),
+					nil,
+					exprs(ident(p(2, 6), "iota")),
+				),
+				valueSpec(idents(ident(p(3, 2), "b")), nil, nil),
+				valueSpec(idents(ident(p(4, 2), "_")), nil, nil),
+				valueSpec(
+					idents(
+						ident(p(5, 2), "d"),
+						ident(p(5, 5), "e"),
+					),
+					nil,
+					exprs(
+						binaryExpr(ident(p(5, 9), "iota"), p(5, 14), token.MUL, intLit(p(5, 16), "10")),
+						binaryExpr(intLit(p(5, 20), "1"), p(5, 22), token.SHL, ident(p(5, 25), "iota")),
+					),
+				),
+				valueSpec(
+					idents(
+						ident(p(6, 2), "f"),
+						ident(p(6, 5), "g"),
+					),
+					nil, nil,
+				),
+			))
+	})
+
+	objs := declaredObjects(parseFile(t, input))
+	expected := map[string]string{
+		"a": "0",
+		"b": "1",
+		"_": "2",
+		"d": "30",
+		"e": "8",
+		"f": "40",
+		"g": "16",
+	}
+	for name, val := range expected {
+		require.Equal(t, val, objs[name].Value.ExactString(), name)
+		require.Equal(t, ast.Con, objs[name].Kind, name)
+	}
+
+	// iota is reset in every group; specs with a type
+	// or values start a new sequence
+	objs = declaredObjects(parseFile(t, `const (
+	x = "a"
+	y
+)
+const (
+	_ = iota
+	kb = 1 << (10 * iota)
+	mb
+	c = 'c' + iota
+	d
+)`))
+	require.Equal(t, `"a"`, objs["y"].Value.ExactString())
+	require.Equal(t, "1024", objs["kb"].Value.ExactString())
+	require.Equal(t, "1048576", objs["mb"].Value.ExactString())
+	require.Equal(t, "102", objs["c"].Value.ExactString())
+	require.Equal(t, "103", objs["d"].Value.ExactString())
+
+	expectParseError(t, "const (a, b = 1, 2; c)", "<input>:1:21: extra expression in const declaration")
+	expectParseError(t, "const (a = 1; b, c)", "<input>:1:15: missing value in const declaration")
+	expectParseError(t, "const (a, b = 1, 2; c, d, e)", "<input>:1:21: missing value in const declaration")
+	expectParseError(t, "const (a = 1; b int)", "<input>:1:15: missing constant value")
+	// errors in inherited values are reported at the constant
+	expectParseError(t, "const (a = 1 << (62 + iota); b; c)", "<input>:1:30: constant 9223372036854775808 overflows int")
+	expectParseError(t, "const (a = 10 / (1 - iota); b)", "<input>:1:29: invalid operation: division by zero")
+}
+
 func TestVarDecls(t *testing.T) {
 	expectParse(t, "var a float", func(p pfn) []ast.Stmt {
 		return stmts(
@@ -146,25 +293,898 @@ func TestVarDecls(t *testing.T) {
 	expectParseError(t, `var foo = "howdy"`, "<input>:1:5: initialization is not allowed in a var declaration")
 }
 
+func TestIfStmts(t *testing.T) {
+	expectParse(t, "if a > 5 {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			ifStmt(p(1, 1), nil,
+				binaryExpr(ident(p(1, 4), "a"), p(1, 6), token.GTR, intLit(p(1, 8), "5")),
+				blockStmt(p(1, 10), p(1, 11)),
+				nil,
+			))
+	})
+
+	expectParse(t, `if a = 4; a < 5 {
+	let b = a
+} else if a == 5 {
+} else {
+	var c int
+}`, func(p pfn) []ast.Stmt {
+		return stmts(
+			ifStmt(p(1, 1),
+				assignStmt(exprs(ident(p(1, 4), "a")), p(1, 6), token.ASSIGN, exprs(intLit(p(1, 8), "4"))),
+				binaryExpr(ident(p(1, 11), "a"), p(1, 13), token.LSS, intLit(p(1, 15), "5")),
+				blockStmt(p(1, 17), p(3, 1),
+					letDecl(p(2, 2), 0, 0, valueSpec(
+						idents(ident(p(2, 6), "b")), nil, exprs(ident(p(2, 10), "a")),
+					)),
+				),
+				ifStmt(p(3, 8), nil,
+					binaryExpr(ident(p(3, 11), "a"), p(3, 13), token.EQL, intLit(p(3, 16), "5")),
+					blockStmt(p(3, 18), p(4, 1)),
+					blockStmt(p(4, 8), p(6, 1),
+						varDecl(p(5, 2), 0, 0, valueSpec(
+							idents(ident(p(5, 6), "c")), ident(p(5, 8), "int"), nil,
+						)),
+					),
+				),
+			))
+	})
+
+	expectParseError(t, "if {}", "<input>:1:4: missing condition in if statement")
+	expectParseError(t, "if a = 5 {}", "<input>:1:4: expected boolean expression, found assignment")
+	expectParseError(t, "if a {} else const b = 1", "<input>:1:14: expected if statement or block, found 'const'")
+}
+
+func TestIfStmtScopes(t *testing.T) {
+	input := `if a = 4; a < 5 {
+	let b = a
+}
+let c = a
+let d = b`
+	f := parseFile(t, input)
+	require.Len(t, f.Unresolved, 2)
+	require.Equal(t, "a", f.Unresolved[0].Name)
+	require.Equal(t, "b", f.Unresolved[1].Name)
+	for _, ident := range f.Unresolved {
+		require.Nil(t, ident.Obj, ident.Name)
+	}
+
+	ifs := f.Stmts[0].(*ast.IfStmt)
+	a := ifs.Init.(*ast.AssignStmt).Lhs[0].(*ast.Ident)
+	require.NotNil(t, a.Obj)
+	require.Equal(t, ast.Var, a.Obj.Kind)
+	require.Same(t, a.Obj, ifs.Cond.(*ast.BinaryExpr).Lhs.(*ast.Ident).Obj)
+	b := ifs.Body.List[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	require.Same(t, a.Obj, b.Values[0].(*ast.Ident).Obj)
+}
+
+func TestForStmts(t *testing.T) {
+	expectParse(t, "for {\n\tbreak\n}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forStmt(p(1, 1), nil, nil, nil,
+				blockStmt(p(1, 5), p(3, 1),
+					branchStmt(p(2, 2), token.BREAK),
+				),
+				nil,
+			))
+	})
+
+	expectParse(t, "for i = 0; i < 5; i = i + 1 {\n\tcontinue\n}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forStmt(p(1, 1),
+				assignStmt(exprs(ident(p(1, 5), "i")), p(1, 7), token.ASSIGN, exprs(intLit(p(1, 9), "0"))),
+				binaryExpr(ident(p(1, 12), "i"), p(1, 14), token.LSS, intLit(p(1, 16), "5")),
+				assignStmt(exprs(ident(p(1, 19), "i")), p(1, 21), token.ASSIGN, exprs(
+					binaryExpr(ident(p(1, 23), "i"), p(1, 25), token.ADD, intLit(p(1, 27), "1")),
+				)),
+				blockStmt(p(1, 29), p(3, 1),
+					branchStmt(p(2, 2), token.CONTINUE),
+				),
+				nil,
+			))
+	})
+
+	expectParse(t, "for a == b {} else {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forStmt(p(1, 1), nil,
+				binaryExpr(ident(p(1, 5), "a"), p(1, 7), token.EQL, ident(p(1, 10), "b")),
+				nil,
+				blockStmt(p(1, 12), p(1, 13)),
+				blockStmt(p(1, 20), p(1, 21)),
+			))
+	})
+
+	expectParse(t, "for x in xs {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forInStmt(p(1, 1), idents(ident(p(1, 5), "x")), p(1, 7), ident(p(1, 10), "xs"), 0, nil,
+				blockStmt(p(1, 13), p(1, 14)),
+				nil,
+			))
+	})
+
+	expectParse(t, "for k, v in m if v > 70 {} else {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forInStmt(p(1, 1),
+				idents(ident(p(1, 5), "k"), ident(p(1, 8), "v")),
+				p(1, 10),
+				ident(p(1, 13), "m"),
+				p(1, 15),
+				binaryExpr(ident(p(1, 18), "v"), p(1, 20), token.GTR, intLit(p(1, 22), "70")),
+				blockStmt(p(1, 25), p(1, 26)),
+				blockStmt(p(1, 33), p(1, 34)),
+			))
+	})
+
+	expectParse(t, "for x in xs if x in ys and (x not in zs) {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			forInStmt(p(1, 1), idents(ident(p(1, 5), "x")), p(1, 7), ident(p(1, 10), "xs"), p(1, 13),
+				binaryExpr(
+					binaryExpr(ident(p(1, 16), "x"), p(1, 18), token.IN, ident(p(1, 21), "ys")),
+					p(1, 24), token.LAND,
+					parenExpr(p(1, 28), binaryExpr(ident(p(1, 29), "x"), p(1, 31), token.NOT_IN, ident(p(1, 38), "zs")), p(1, 40)),
+				),
+				blockStmt(p(1, 42), p(1, 43)),
+				nil,
+			))
+	})
+
+	expectParseError(t, "break", "<input>:1:1: break is not in a loop or switch")
+	expectParseError(t, "if true { continue }", "<input>:1:11: continue is not in a loop")
+	expectParseError(t, "for {} else { break }", "<input>:1:15: break is not in a loop or switch")
+	expectParseError(t, "for 1 + 2, x in y {}", "<input>:1:5: expected identifier")
+}
+
+func TestForStmtScopes(t *testing.T) {
+	input := `for x in xs if x > 1 {
+	let y = x
+}
+let z = x`
+	f := parseFile(t, input)
+	require.Len(t, f.Unresolved, 2)
+	require.Equal(t, "xs", f.Unresolved[0].Name)
+	require.Equal(t, "x", f.Unresolved[1].Name)
+
+	fs := f.Stmts[0].(*ast.ForInStmt)
+	x := fs.Vars[0]
+	require.NotNil(t, x.Obj)
+	require.Equal(t, ast.Var, x.Obj.Kind)
+	require.Same(t, x.Obj, fs.Filter.(*ast.BinaryExpr).Lhs.(*ast.Ident).Obj)
+	y := fs.Body.List[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	require.Same(t, x.Obj, y.Values[0].(*ast.Ident).Obj)
+}
+
+func TestFuncs(t *testing.T) {
+	expectParse(t, `fn add(x, y int) int {
+	let z = x
+}`, func(p pfn) []ast.Stmt {
+		return stmts(
+			funcDecl(ident(p(1, 4), "add"),
+				funcType(p(1, 1),
+					fieldList(p(1, 7), p(1, 16),
+						field(idents(ident(p(1, 8), "x"), ident(p(1, 11), "y")), 0, ident(p(1, 13), "int"), 0, nil),
+					),
+					fieldList(0, 0,
+						field(nil, 0, ident(p(1, 18), "int"), 0, nil),
+					),
+				),
+				blockStmt(p(1, 22), p(3, 1),
+					letDecl(p(2, 2), 0, 0, valueSpec(
+						idents(ident(p(2, 6), "z")), nil, exprs(ident(p(2, 10), "x")),
+					)),
+				),
+			))
+	})
+
+	expectParse(t, "fn printChars(s string, reverse bool=false) (string, int) {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			funcDecl(ident(p(1, 4), "printChars"),
+				funcType(p(1, 1),
+					fieldList(p(1, 14), p(1, 43),
+						field(idents(ident(p(1, 15), "s")), 0, ident(p(1, 17), "string"), 0, nil),
+						field(idents(ident(p(1, 25), "reverse")), 0, ident(p(1, 33), "bool"), p(1, 37), ident(p(1, 38), "false")),
+					),
+					fieldList(p(1, 45), p(1, 57),
+						field(nil, 0, ident(p(1, 46), "string"), 0, nil),
+						field(nil, 0, ident(p(1, 54), "int"), 0, nil),
+					),
+				),
+				blockStmt(p(1, 59), p(1, 60)),
+			))
+	})
+
+	expectParse(t, "fn fullFunc(x, y=2, ...z) {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			funcDecl(ident(p(1, 4), "fullFunc"),
+				funcType(p(1, 1),
+					fieldList(p(1, 12), p(1, 25),
+						field(idents(ident(p(1, 13), "x")), 0, nil, 0, nil),
+						field(idents(ident(p(1, 16), "y")), 0, nil, p(1, 17), intLit(p(1, 18), "2")),
+						field(idents(ident(p(1, 24), "z")), p(1, 21), nil, 0, nil),
+					),
+					nil,
+				),
+				blockStmt(p(1, 27), p(1, 28)),
+			))
+	})
+
+	expectParse(t, "let f, g = fn(i) {}, fn {}", func(p pfn) []ast.Stmt {
+		return stmts(
+			letDecl(p(1, 1), 0, 0, valueSpec(
+				idents(ident(p(1, 5), "f"), ident(p(1, 8), "g")), nil, exprs(
+					funcLit(
+						funcType(p(1, 12),
+							fieldList(p(1, 14), p(1, 16),
+								field(idents(ident(p(1, 15), "i")), 0, nil, 0, nil),
+							),
+							nil,
+						),
+						blockStmt(p(1, 18), p(1, 19)),
+					),
+					funcLit(
+						funcType(p(1, 22), fieldList(0, 0), nil),
+						blockStmt(p(1, 25), p(1, 26)),
+					),
+				),
+			)))
+	})
+
+	expectParse(t, "fn(x int) int { return x }(1)", func(p pfn) []ast.Stmt {
+		return stmts(
+			exprStmt(callExpr(
+				funcLit(
+					funcType(p(1, 1),
+						fieldList(p(1, 3), p(1, 9),
+							field(idents(ident(p(1, 4), "x")), 0, ident(p(1, 6), "int"), 0, nil),
+						),
+						fieldList(0, 0,
+							field(nil, 0, ident(p(1, 11), "int"), 0, nil),
+						),
+					),
+					blockStmt(p(1, 15), p(1, 26),
+						returnStmt(p(1, 17), ident(p(1, 24), "x")),
+					),
+				),
+				p(1, 27), exprs(intLit(p(1, 28), "1")), 0, p(1, 29),
+			)))
+	})
+
+	expectParse(t, "var each fn(seq, f fn(i int))", func(p pfn) []ast.Stmt {
+		return stmts(
+			varDecl(p(1, 1), 0, 0, valueSpec(
+				idents(ident(p(1, 5), "each")),
+				funcType(p(1, 10),
+					fieldList(p(1, 12), p(1, 29),
+						field(idents(ident(p(1, 13), "seq"), ident(p(1, 18), "f")),
+							0,
+							funcType(p(1, 20),
+								fieldList(p(1, 22), p(1, 28),
+									field(idents(ident(p(1, 23), "i")), 0, ident(p(1, 25), "int"), 0, nil),
+								),
+								nil,
+							),
+							0, nil),
+					),
+					nil,
+				),
+				nil,
+			)))
+	})
+
+	expectParse(t, "let r = printChars(alpha, reverse=true)(xs...)", func(p pfn) []ast.Stmt {
+		return stmts(
+			letDecl(p(1, 1), 0, 0, valueSpec(
+				idents(ident(p(1, 5), "r")), nil, exprs(
+					callExpr(
+						callExpr(ident(p(1, 9), "printChars"), p(1, 19), exprs(
+							ident(p(1, 20), "alpha"),
+							keywordArg(ident(p(1, 27), "reverse"), p(1, 34), ident(p(1, 35), "true")),
+						), 0, p(1, 39)),
+						p(1, 40), exprs(ident(p(1, 41), "xs")), p(1, 43), p(1, 46),
+					),
+				),
+			)))
+	})
+
+	expectParseError(t, "fn badFunc(...stuff, things) {}", "<input>:1:12: can only use ... with final parameter in list")
+	expectParseError(t, "fn f(a=1, b int) {}", "<input>:1:11: positional parameter b follows optional parameter")
+	expectParseError(t, "fn f(...a=1) {}", "<input>:1:10: variadic parameter a cannot have a default value")
+	expectParseError(t, "fn f {}", "<input>:1:6: expected '(', found '{'")
+	expectParseError(t, "let x = f(a=1, 2)", "<input>:1:16: positional argument follows keyword argument")
+	expectParseError(t, "let x = f(a+1=1)", "<input>:1:11: expected parameter name")
+}
+
+func TestFuncScopes(t *testing.T) {
+	input := `fn fib(n int, m=n) int {
+	let a = fib(n)
+}
+let b = n`
+	f := parseFile(t, input)
+	require.Len(t, f.Unresolved, 4)
+	require.Equal(t, "int", f.Unresolved[0].Name)
+	// default values are resolved outside of the function scope
+	require.Equal(t, "n", f.Unresolved[1].Name)
+	require.Equal(t, "int", f.Unresolved[2].Name)
+	require.Equal(t, "n", f.Unresolved[3].Name)
+
+	fd := f.Stmts[0].(*ast.DeclStmt).Decl.(*ast.FuncDecl)
+	require.Equal(t, ast.Fun, fd.Name.Obj.Kind)
+	n := fd.Type.Params.List[0].Names[0]
+	require.Equal(t, ast.Var, n.Obj.Kind)
+	a := fd.Body.List[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	call := a.Values[0].(*ast.CallExpr)
+	require.Same(t, fd.Name.Obj, call.Fun.(*ast.Ident).Obj)
+	require.Same(t, n.Obj, call.Args[0].(*ast.Ident).Obj)
+}
+
+func TestReturnStmts(t *testing.T) {
+	expectParse(t, `fn f(x) {
+	for {
+		return
+	}
+	return x, 5
+}`, func(p pfn) []ast.Stmt {
+		return stmts(
+			funcDecl(ident(p(1, 4), "f"),
+				funcType(p(1, 1),
+					fieldList(p(1, 5), p(1, 7),
+						field(idents(ident(p(1, 6), "x")), 0, nil, 0, nil),
+					),
+					nil,
+				),
+				blockStmt(p(1, 9), p(6, 1),
+					forStmt(p(2, 2), nil, nil, nil,
+						blockStmt(p(2, 6), p(4, 2),
+							returnStmt(p(3, 3)),
+						),
+						nil,
+					),
+					returnStmt(p(5, 2), ident(p(5, 9), "x"), intLit(p(5, 12), "5")),
+				),
+			))
+	})
+
+	expectParse(t, "let f = fn { return }", func(p pfn) []ast.Stmt {
+		return stmts(
+			letDecl(p(1, 1), 0, 0, valueSpec(
+				idents(ident(p(1, 5), "f")), nil, exprs(
+					funcLit(
+						funcType(p(1, 9), fieldList(0, 0), nil),
+						blockStmt(p(1, 12), p(1, 21),
+							returnStmt(p(1, 14)),
+						),
+					),
+				),
+			)))
+	})
+
+	expectParseError(t, "return 5", "<input>:1:1: return is not in a function")
+	expectParseError(t, "if true { return }", "<input>:1:11: return is not in a function")
+	expectParseError(t, "fallthrough", "<input>:1:1: fallthrough statement out of place")
+	expectParseError(t, "fn f() { fallthrough }", "<input>:1:10: fallthrough statement out of place")
+	expectParseError(t, "for { let f = fn { break } }", "<input>:1:20: break is not in a loop or switch")
+}
+
+func TestSimpleStmts(t *testing.T) {
+	expectParse(t, `x = 5
+a, b = x, "b"
+x++
+b--
+print(x)`, func(p pfn) []ast.Stmt {
+		return stmts(
+			assignStmt(exprs(ident(p(1, 1), "x")), p(1, 3), token.ASSIGN, exprs(intLit(p(1, 5), "5"))),
+			assignStmt(
+				exprs(ident(p(2, 1), "a"), ident(p(2, 4), "b")),
+				p(2, 6),
+				token.ASSIGN,
+				exprs(ident(p(2, 8), "x"), basicLit(p(2, 11), token.STRING, `"b"`)),
+			),
+			incDecStmt(ident(p(3, 1), "x"), p(3, 2), token.INC),
+			incDecStmt(ident(p(4, 1), "b"), p(4, 2), token.DEC),
+			exprStmt(callExpr(ident(p(5, 1), "print"), p(5, 6), exprs(ident(p(5, 7), "x")), 0, p(5, 8))),
+		)
+	})
+
+	for _, tok := range []token.Token{
+		token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN,
+		token.REM_ASSIGN, token.EXP_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN,
+		token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN,
+	} {
+		expectParse(t, "x "+tok.String()+" 2", func(p pfn) []ast.Stmt {
+			return stmts(
+				assignStmt(exprs(ident(p(1, 1), "x")), p(1, 3), tok, exprs(intLit(p(1, 4+len(tok.String())), "2"))),
+			)
+		})
+	}
+
+	expectParse(t, `a in b == c not in d
+if x not in y {}`, func(p pfn) []ast.Stmt {
+		return stmts(
+			exprStmt(binaryExpr(
+				binaryExpr(
+					binaryExpr(ident(p(1, 1), "a"), p(1, 3), token.IN, ident(p(1, 6), "b")),
+					p(1, 8), token.EQL, ident(p(1, 11), "c"),
+				),
+				p(1, 13), token.NOT_IN, ident(p(1, 20), "d"),
+			)),
+			ifStmt(p(2, 1), nil,
+				binaryExpr(ident(p(2, 4), "x"), p(2, 6), token.NOT_IN, ident(p(2, 13), "y")),
+				blockStmt(p(2, 15), p(2, 16)),
+				nil,
+			),
+		)
+	})
+
+	expectParseError(t, "a not b", "<input>:1:7: expected 'in', found b")
+	expectParseError(t, "a, b += 1, 2", "<input>:1:6: assignment operation += requires single-valued expressions")
+	expectParseError(t, "a, b", "<input>:1:1: expected 1 expression")
+	expectParseError(t, "x = 1 y = 2", "<input>:1:7: expected ';', found y")
+}
+
+func TestImplicitDecls(t *testing.T) {
+	input := `grade = 88
+grade = 90
+total += grade
+if true {
+	grade, passed = 70, true
+}
+print(passed)`
+	f := parseFile(t, input)
+	require.Len(t, f.Unresolved, 5)
+	require.Equal(t, "total", f.Unresolved[0].Name)
+	require.Equal(t, "true", f.Unresolved[1].Name)
+	require.Equal(t, "true", f.Unresolved[2].Name)
+	require.Equal(t, "print", f.Unresolved[3].Name)
+	require.Equal(t, "passed", f.Unresolved[4].Name)
+
+	grade := f.Stmts[0].(*ast.AssignStmt).Lhs[0].(*ast.Ident)
+	require.Equal(t, ast.Var, grade.Obj.Kind)
+	require.Same(t, f.Stmts[0], grade.Obj.Decl)
+	require.Same(t, grade.Obj, f.Stmts[1].(*ast.AssignStmt).Lhs[0].(*ast.Ident).Obj)
+	require.Same(t, grade.Obj, f.Stmts[2].(*ast.AssignStmt).Rhs[0].(*ast.Ident).Obj)
+
+	inner := f.Stmts[3].(*ast.IfStmt).Body.List[0].(*ast.AssignStmt)
+	require.Same(t, grade.Obj, inner.Lhs[0].(*ast.Ident).Obj)
+	passed := inner.Lhs[1].(*ast.Ident)
+	require.Equal(t, ast.Var, passed.Obj.Kind)
+	require.Same(t, inner, passed.Obj.Decl)
+}
+
+func TestCompositeLits(t *testing.T) {
+	expectParse(t, `[1, 2, 3]
+{"k": v, "j": 2,}
+{1, 2, 3}
+(a, b)
+(a,)
+()
+{:}
+{}
+[]`, func(p pfn) []ast.Stmt {
+		return stmts(
+			exprStmt(listLit(p(1, 1), p(1, 9), intLit(p(1, 2), "1"), intLit(p(1, 5), "2"), intLit(p(1, 8), "3"))),
+			exprStmt(mapLit(p(2, 1), p(2, 17),
+				keyValueExpr(basicLit(p(2, 2), token.STRING, `"k"`), p(2, 5), ident(p(2, 7), "v")),
+				keyValueExpr(basicLit(p(2, 10), token.STRING, `"j"`), p(2, 13), intLit(p(2, 15), "2")),
+			)),
+			exprStmt(setLit(p(3, 1), p(3, 9), intLit(p(3, 2), "1"), intLit(p(3, 5), "2"), intLit(p(3, 8), "3"))),
+			exprStmt(tupleLit(p(4, 1), p(4, 6), ident(p(4, 2), "a"), ident(p(4, 5), "b"))),
+			exprStmt(tupleLit(p(5, 1), p(5, 4), ident(p(5, 2), "a"))),
+			exprStmt(tupleLit(p(6, 1), p(6, 2))),
+			exprStmt(mapLit(p(7, 1), p(7, 3))),
+			exprStmt(setLit(p(8, 1), p(8, 2))),
+			exprStmt(listLit(p(9, 1), p(9, 2))),
+		)
+	})
+
+	expectParse(t, `x = 1, 2
+a, b = 1, 2
+z = (1 + 2)
+y = (1,)`, func(p pfn) []ast.Stmt {
+		return stmts(
+			assignStmt(exprs(ident(p(1, 1), "x")), p(1, 3), token.ASSIGN,
+				exprs(tupleLit(0, 0, intLit(p(1, 5), "1"), intLit(p(1, 8), "2")))),
+			assignStmt(exprs(ident(p(2, 1), "a"), ident(p(2, 4), "b")), p(2, 6), token.ASSIGN,
+				exprs(intLit(p(2, 8), "1"), intLit(p(2, 11), "2"))),
+			assignStmt(exprs(ident(p(3, 1), "z")), p(3, 3), token.ASSIGN,
+				exprs(parenExpr(p(3, 5), binaryExpr(intLit(p(3, 6), "1"), p(3, 8), token.ADD, intLit(p(3, 10), "2")), p(3, 11)))),
+			assignStmt(exprs(ident(p(4, 1), "y")), p(4, 3), token.ASSIGN,
+				exprs(tupleLit(p(4, 5), p(4, 8), intLit(p(4, 6), "1")))),
+		)
+	})
+
+	expectParseError(t, `{1: 2, 3}`, "<input>:1:9: expected ':', found '}'")
+	expectParseError(t, `[1, 2`, "<input>:1:6: missing ',' in list literal")
+	expectParseError(t, `{1 2}`, "<input>:1:4: missing ',' in set literal")
+}
+
+func TestContainerTypes(t *testing.T) {
+	expectParse(t, `var a list[int]
+var b map[string]list[float]
+var c set[float]
+var d tuple[int, string]
+e = list[int](x)`, func(p pfn) []ast.Stmt {
+		return stmts(
+			varDecl(p(1, 1), 0, 0, valueSpec(idents(ident(p(1, 5), "a")),
+				listType(p(1, 7), p(1, 11), ident(p(1, 12), "int"), p(1, 15)), nil)),
+			varDecl(p(2, 1), 0, 0, valueSpec(idents(ident(p(2, 5), "b")),
+				mapType(p(2, 7), p(2, 10), ident(p(2, 11), "string"), p(2, 17),
+					listType(p(2, 18), p(2, 22), ident(p(2, 23), "float"), p(2, 28))), nil)),
+			varDecl(p(3, 1), 0, 0, valueSpec(idents(ident(p(3, 5), "c")),
+				setType(p(3, 7), p(3, 10), ident(p(3, 11), "float"), p(3, 16)), nil)),
+			varDecl(p(4, 1), 0, 0, valueSpec(idents(ident(p(4, 5), "d")),
+				tupleType(p(4, 7), p(4, 12), p(4, 24), ident(p(4, 13), "int"), ident(p(4, 18), "string")), nil)),
+			assignStmt(exprs(ident(p(5, 1), "e")), p(5, 3), token.ASSIGN, exprs(
+				callExpr(listType(p(5, 5), p(5, 9), ident(p(5, 10), "int"), p(5, 13)), p(5, 14), exprs(ident(p(5, 15), "x")), 0, p(5, 16)),
+			)),
+		)
+	})
+
+	expectParseError(t, `var a map[int]`, "<input>:1:15: expected type, found ';'")
+	expectParseError(t, `var a tuple[int string]`, "<input>:1:17: missing ',' in tuple type")
+}
+
+func TestPrimaryExprs(t *testing.T) {
+	expectParse(t, `a.b.c
+xs[0]
+xs[1:2]
+xs[:]
+xs[1:2:3]
+x as int
+m["k"].f(1)[2]
+xs[0] = y as list[int]`, func(p pfn) []ast.Stmt {
+		return stmts(
+			exprStmt(selectorExpr(selectorExpr(ident(p(1, 1), "a"), ident(p(1, 3), "b")), ident(p(1, 5), "c"))),
+			exprStmt(indexExpr(ident(p(2, 1), "xs"), p(2, 3), intLit(p(2, 4), "0"), p(2, 5))),
+			exprStmt(sliceExpr(ident(p(3, 1), "xs"), p(3, 3), intLit(p(3, 4), "1"), intLit(p(3, 6), "2"), nil, false, p(3, 7))),
+			exprStmt(sliceExpr(ident(p(4, 1), "xs"), p(4, 3), nil, nil, nil, false, p(4, 5))),
+			exprStmt(sliceExpr(ident(p(5, 1), "xs"), p(5, 3), intLit(p(5, 4), "1"), intLit(p(5, 6), "2"), intLit(p(5, 8), "3"), true, p(5, 9))),
+			exprStmt(typeAssertExpr(ident(p(6, 1), "x"), p(6, 3), ident(p(6, 6), "int"))),
+			exprStmt(indexExpr(
+				callExpr(
+					selectorExpr(indexExpr(ident(p(7, 1), "m"), p(7, 2), basicLit(p(7, 3), token.STRING, `"k"`), p(7, 6)), ident(p(7, 8), "f")),
+					p(7, 9), exprs(intLit(p(7, 10), "1")), 0, p(7, 11),
+				),
+				p(7, 12), intLit(p(7, 13), "2"), p(7, 14),
+			)),
+			assignStmt(
+				exprs(indexExpr(ident(p(8, 1), "xs"), p(8, 3), intLit(p(8, 4), "0"), p(8, 5))),
+				p(8, 7),
+				token.ASSIGN,
+				exprs(typeAssertExpr(ident(p(8, 9), "y"), p(8, 11), listType(p(8, 14), p(8, 18), ident(p(8, 19), "int"), p(8, 22)))),
+			),
+		)
+	})
+
+	expectParseError(t, "xs[1::3]", "<input>:1:5: 2nd index required in 3-index slice")
+	expectParseError(t, "xs[1:2:]", "<input>:1:7: 3rd index required in 3-index slice")
+	expectParseError(t, "xs[]", "<input>:1:4: expected operand, found ']'")
+	expectParseError(t, "a.(b)", "<input>:1:3: expected selector, found '('")
+	expectParseError(t, "x as", "<input>:1:5: expected type, found 'EOF'")
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	expectParse(t, `"Hi {name}, {{x}} {m["k"] + 1}!"
+"{f("{x}")}"`, func(p pfn) []ast.Stmt {
+		return stmts(
+			exprStmt(interpolatedString(
+				basicLit(p(1, 1), token.STRING_HEAD, `"Hi {`),
+				ident(p(1, 6), "name"),
+				basicLit(p(1, 10), token.STRING_MID, `}, {{x}} {`),
+				binaryExpr(
+					indexExpr(ident(p(1, 20), "m"), p(1, 21), basicLit(p(1, 22), token.STRING, `"k"`), p(1, 25)),
+					p(1, 27), token.ADD, intLit(p(1, 29), "1"),
+				),
+				basicLit(p(1, 30), token.STRING_TAIL, `}!"`),
+			)),
+			exprStmt(interpolatedString(
+				basicLit(p(2, 1), token.STRING_HEAD, `"{`),
+				callExpr(ident(p(2, 3), "f"), p(2, 4), exprs(interpolatedString(
+					basicLit(p(2, 5), token.STRING_HEAD, `"{`),
+					ident(p(2, 7), "x"),
+					basicLit(p(2, 8), token.STRING_TAIL, `}"`),
+				)), 0, p(2, 10)),
+				basicLit(p(2, 11), token.STRING_TAIL, `}"`),
+			)),
+		)
+	})
+
+	expectParseError(t, `"a {} b"`, "<input>:1:5: empty expression in string interpolation")
+	expectParseError(t, `"a {b c}"`, "<input>:1:7: expected '}', found c")
+	expectParseError(t, `"a {b"`, "<input>:1:7: literal not terminated")
+	expectParseError(t, `"a {b // c}"`, "<input>:1:7: comment not allowed in string interpolation")
+	expectParseError(t, `"a } b"`, "<input>:1:4: unbalanced '}' in string literal; use '}}' for a literal brace")
+}
+
+func TestSwitchStmts(t *testing.T) {
+	expectParse(t, `switch x = 1; x {
+case 1, 2:
+	fallthrough
+case 3:
+	break
+default:
+}
+switch {
+}
+switch v = typeof y {
+case int:
+case float, string:
+}`, func(p pfn) []ast.Stmt {
+		return stmts(
+			switchStmt(p(1, 1),
+				assignStmt(exprs(ident(p(1, 8), "x")), p(1, 10), token.ASSIGN, exprs(intLit(p(1, 12), "1"))),
+				ident(p(1, 15), "x"),
+				blockStmt(p(1, 17), p(7, 1),
+					caseClause(p(2, 1), exprs(intLit(p(2, 6), "1"), intLit(p(2, 9), "2")), p(2, 10),
+						branchStmt(p(3, 2), token.FALLTHROUGH),
+					),
+					caseClause(p(4, 1), exprs(intLit(p(4, 6), "3")), p(4, 7),
+						branchStmt(p(5, 2), token.BREAK),
+					),
+					caseClause(p(6, 1), nil, p(6, 8)),
+				),
+			),
+			switchStmt(p(8, 1), nil, nil, blockStmt(p(8, 8), p(9, 1))),
+			typeSwitchStmt(p(10, 1), nil, ident(p(10, 8), "v"), p(10, 12), ident(p(10, 19), "y"),
+				blockStmt(p(10, 21), p(13, 1),
+					caseClause(p(11, 1), exprs(ident(p(11, 6), "int")), p(11, 9)),
+					caseClause(p(12, 1), exprs(ident(p(12, 6), "float"), ident(p(12, 13), "string")), p(12, 19)),
+				),
+			),
+		)
+	})
+
+	expectParseError(t, "switch x { case 1: case 1: }", "<input>:1:25: duplicate case 1 in switch\n\tprevious case at 1:17")
+	expectParseError(t, "switch x { case 0x10, -2: case 16: }", "<input>:1:32: duplicate case 16 in switch\n\tprevious case at 1:17")
+	expectParseError(t, "switch x { case -2: case -2.0: }", "<input>:1:26: duplicate case -2 in switch\n\tprevious case at 1:17")
+	expectParseError(t, "switch x { case \"a\": case `a`: }", "<input>:1:27: duplicate case \"a\" in switch\n\tprevious case at 1:17")
+	expectParseError(t, "switch x { case \"{{\": case `{`: }", "<input>:1:28: duplicate case \"{\" in switch\n\tprevious case at 1:17")
+	expectParseError(t, "switch { case true: case true: }", "<input>:1:26: duplicate case true in switch\n\tprevious case at 1:15")
+	expectParseError(t, "switch x { default: default: }", "<input>:1:21: multiple defaults in switch (first at 1:12)")
+	expectParseError(t, "switch x { case 1: fallthrough }", "<input>:1:20: cannot fallthrough final case in switch")
+	expectParseError(t, "switch x { case 1: fallthrough; x++; case 2: }", "<input>:1:20: fallthrough statement out of place")
+	expectParseError(t, "switch x { case 1: if x { fallthrough }; case 2: }", "<input>:1:27: fallthrough statement out of place")
+	expectParseError(t, "switch typeof x { case int: fallthrough; case float: }", "<input>:1:29: cannot fallthrough in type switch")
+	expectParseError(t, "switch typeof x; x {}", "<input>:1:8: typeof used outside type switch")
+	expectParseError(t, "switch x { case 1: continue }", "<input>:1:20: continue is not in a loop")
+}
+
+func TestTypeSwitchScopes(t *testing.T) {
+	input := `switch v = typeof x {
+case int:
+	print(v)
+case float, string:
+	print(v)
+default:
+	print(v)
+}`
+	f := parseFile(t, input)
+	ts := f.Stmts[0].(*ast.TypeSwitchStmt)
+	require.Nil(t, ts.Lhs.Obj)
+
+	var objs []*ast.Object
+	for _, s := range ts.Body.List {
+		cc := s.(*ast.CaseClause)
+		v := cc.Body[0].(*ast.ExprStmt).Expr.(*ast.CallExpr).Args[0].(*ast.Ident)
+		require.NotNil(t, v.Obj)
+		require.Equal(t, ast.Var, v.Obj.Kind)
+		require.Same(t, ts, v.Obj.Decl)
+		require.Equal(t, ts.Lhs.Pos(), v.Obj.Pos())
+		objs = append(objs, v.Obj)
+	}
+	require.Same(t, ts.Body.List[0].(*ast.CaseClause).List[0], objs[0].Type)
+	require.Nil(t, objs[1].Type)
+	require.Nil(t, objs[2].Type)
+	require.NotSame(t, objs[0], objs[1])
+	require.NotSame(t, objs[1], objs[2])
+}
+
+func TestComments(t *testing.T) {
+	input := `// Package doc is not attached
+// to any declaration.
+
+// Colors enumerates
+// the primary colors.
+const (
+	// Red is red.
+	red = iota // first
+	green      /* second */
+	/* Blue is blue. */
+	blue
+)
+
+/*
+Sum adds two ints.
+*/
+fn sum(x, y int) int {
+	return x + y // unattached
+}
+
+let x = sum(1, 2) // three
+let y = 4
+`
+	f := parseFile(t, input)
+	require.Len(t, f.Comments, 9)
+	require.Equal(t, "Package doc is not attached\nto any declaration.\n", f.Comments[0].Text())
+
+	decl := f.Stmts[0].(*ast.DeclStmt).Decl.(*ast.GenDecl)
+	require.Equal(t, "Colors enumerates\nthe primary colors.\n", decl.Doc.Text())
+	require.Same(t, f.Comments[1], decl.Doc)
+	for i, expected := range []struct {
+		doc, comment string
+	}{
+		{"Red is red.\n", "first\n"},
+		{"", " second\n"},
+		{" Blue is blue.\n", ""},
+	} {
+		spec := decl.Specs[i].(*ast.ValueSpec)
+		require.Equal(t, expected.doc, spec.Doc.Text(), spec.Names[0].Name)
+		require.Equal(t, expected.comment, spec.Comment.Text(), spec.Names[0].Name)
+	}
+
+	fd := f.Stmts[1].(*ast.DeclStmt).Decl.(*ast.FuncDecl)
+	require.Equal(t, "Sum adds two ints.\n", fd.Doc.Text())
+	require.Equal(t, "/*\nSum adds two ints.\n*/", fd.Doc.List[0].Text)
+
+	x := f.Stmts[2].(*ast.DeclStmt).Decl.(*ast.GenDecl)
+	require.Nil(t, x.Doc)
+	require.Nil(t, x.Specs[0].(*ast.ValueSpec).Doc)
+	require.Equal(t, "three\n", x.Specs[0].(*ast.ValueSpec).Comment.Text())
+	y := f.Stmts[3].(*ast.DeclStmt).Decl.(*ast.GenDecl)
+	require.Nil(t, y.Doc)
+	require.Nil(t, y.Specs[0].(*ast.ValueSpec).Comment)
+
+	// general comments spanning lines terminate statements
+	f = parseFile(t, "a = 1 /*\n*/ b = 2")
+	require.Len(t, f.Stmts, 2)
+	require.Len(t, f.Comments, 1)
+	expectParseError(t, "a = 1 /* */ b = 2", "<input>:1:13: expected ';', found b")
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := "let a = 1\nf(a b)\nlet a = 2"
+	fset := token.NewFileSet()
+	base := fset.Base()
+
+	_, err := parser.ParseFile(fset, "", input, parser.DeclarationErrors)
+	testFile := fset.File(token.Pos(base))
+	list, ok := err.(diagnostics.List)
+	require.True(t, ok)
+	require.Len(t, list, 2)
+
+	// a missing comma is inserted by the suggested fix
+	comma := list[0]
+	require.Equal(t, "missing ',' in argument list", comma.Msg)
+	require.Equal(t, diagnostics.SyntaxError, comma.Code)
+	require.Equal(t, testFile.Pos(14), comma.Span.Pos)
+	require.Len(t, comma.Fixes, 1)
+	require.Equal(t, diagnostics.Span{Pos: testFile.Pos(14), End: testFile.Pos(14)}, comma.Fixes[0].Span)
+	require.Equal(t, ",", comma.Fixes[0].NewText)
+
+	// a redeclaration refers to the previous declaration
+	redecl := list[1]
+	require.Equal(t, "a redeclared in this block", redecl.Msg)
+	require.Equal(t, diagnostics.Redeclared, redecl.Code)
+	require.Equal(t, diagnostics.Span{Pos: testFile.Pos(21), End: testFile.Pos(22)}, redecl.Span)
+	require.Len(t, redecl.Related, 1)
+	require.Equal(t, diagnostics.Span{Pos: testFile.Pos(4), End: testFile.Pos(5)}, redecl.Related[0].Span)
+	require.Equal(t, "<input>:3:5: a redeclared in this block\n\tprevious declaration at 1:5", redecl.Error())
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"x = (", diagnostics.SyntaxError},
+		{"x = 1 @ 2", diagnostics.SyntaxError},
+		{"break", diagnostics.MisplacedBranch},
+		{"let a = 1; let a = 2", diagnostics.Redeclared},
+		{"switch x { case 1: case 1: }", diagnostics.DuplicateCase},
+		{"switch x { default: default: }", diagnostics.DuplicateCase},
+		{"const x = 1 / 0", diagnostics.InvalidConstant},
+	}
+
+	for _, test := range tests {
+		_, err := parser.ParseFile(token.NewFileSet(), "", test.input, parser.DeclarationErrors)
+		list, ok := err.(diagnostics.List)
+		require.True(t, ok, test.input)
+		require.Equal(t, test.code, list[0].Code, test.input)
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	fset := token.NewFileSet()
+
+	// redeclarations are only reported with DeclarationErrors
+	input := "let a = 1\nlet a = 2"
+	_, err := parser.ParseFile(fset, "", input, 0)
+	require.NoError(t, err)
+	_, err = parser.ParseFile(fset, "", input, parser.DeclarationErrors)
+	require.EqualError(t, err, "<input>:2:5: a redeclared in this block\n\tprevious declaration at 1:5")
+
+	// comments are only collected with ParseComments
+	input = "// doc\nlet a = 1 // line"
+	f, err := parser.ParseFile(fset, "", input, 0)
+	require.NoError(t, err)
+	require.Nil(t, f.Comments)
+	f, err = parser.ParseFile(fset, "", input, parser.ParseComments)
+	require.NoError(t, err)
+	require.Len(t, f.Comments, 2)
+
+	// without AllErrors, only the first error per line is reported
+	// and parsing stops after 10 errors
+	input = strings.Repeat("let a\n", 12)
+	_, err = parser.ParseFile(fset, "", input, 0)
+	require.Len(t, err, 11)
+	_, err = parser.ParseFile(fset, "", input, parser.AllErrors)
+	require.Len(t, err, 24)
+}
+
+func TestParseSources(t *testing.T) {
+	input := "let a = 1"
+	dir, err := ioutil.TempDir("", "rose")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.rose")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(input), 0o644))
+
+	for _, src := range []interface{}{input, []byte(input), bytes.NewBufferString(input), strings.NewReader(input), nil} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		require.NoError(t, err)
+		require.Len(t, f.Stmts, 1)
+		require.Equal(t, filename+":1:5", fset.Position(declaredObjects(f)["a"].Pos()).String())
+	}
+
+	_, err = parser.ParseFile(token.NewFileSet(), "", 42, 0)
+	require.EqualError(t, err, "invalid source")
+	_, err = parser.ParseFile(token.NewFileSet(), filepath.Join(dir, "missing.rose"), nil, 0)
+	require.Error(t, err)
+}
+
+func TestParseFileInScope(t *testing.T) {
+	fset := token.NewFileSet()
+	scope := ast.NewScope(nil)
+
+	f1, err := parser.ParseFileInScope(fset, "1", "let a = 1\nb = 2", parser.DeclarationErrors, scope)
+	require.NoError(t, err)
+	a := scope.Lookup("a")
+	require.NotNil(t, a)
+	require.Equal(t, ast.Let, a.Kind)
+	require.NotNil(t, scope.Lookup("b"))
+	require.Empty(t, f1.Unresolved)
+
+	// identifiers resolve to the objects of the scope
+	f2, err := parser.ParseFileInScope(fset, "2", "c = a + b\nb = 3", parser.DeclarationErrors, scope)
+	require.NoError(t, err)
+	require.Empty(t, f2.Unresolved)
+	assign := f2.Stmts[0].(*ast.AssignStmt)
+	require.Same(t, a, assign.Rhs[0].(*ast.BinaryExpr).Lhs.(*ast.Ident).Obj)
+	require.Same(t, scope.Lookup("b"), f2.Stmts[1].(*ast.AssignStmt).Lhs[0].(*ast.Ident).Obj)
+	require.Len(t, scope.Objects, 3)
+
+	// declarations are checked against the scope
+	_, err = parser.ParseFileInScope(fset, "3", "let a = 2", parser.DeclarationErrors, scope)
+	require.EqualError(t, err, "3:1:5: a redeclared in this block\n\tprevious declaration at 1:1:5")
+
+	// a nested scope sees the objects of its outer scope but
+	// receives the declarations itself
+	inner := ast.NewScope(scope)
+	_, err = parser.ParseFileInScope(fset, "4", "let a = 2\nd = a", parser.DeclarationErrors, inner)
+	require.NoError(t, err)
+	require.Len(t, inner.Objects, 2)
+	require.Len(t, scope.Objects, 3)
+}
+
 type pfn func(int, int) token.Pos        // position conversion function
 type expectedFn func(pos pfn) []ast.Stmt // callback function to return expected results
 
 func expectParse(t *testing.T, input string, fn expectedFn) {
 	fset := token.NewFileSet()
-	testFile := fset.AddFile("", -1, len(input))
-
-	var off int
-	for {
-		o := strings.IndexRune(input[off:], '\n')
-		if o == -1 {
-			break
-		}
-		testFile.AddLine(o + off + 1)
-		off += o + 1
-	}
+	base := fset.Base()
 
-	actual, err := parser.ParseFile(testFile, strings.NewReader(input))
+	actual, err := parser.ParseFile(fset, "", input, parser.ParseComments|parser.DeclarationErrors)
 	require.NoError(t, err)
+	testFile := fset.File(token.Pos(base))
 
 	expected := fn(func(line, column int) token.Pos {
 		return token.Pos(int(testFile.LineStart(line)) + (column - 1))
@@ -176,11 +1196,31 @@ func expectParse(t *testing.T, input string, fn expectedFn) {
 	}
 }
 
-func expectParseError(t *testing.T, input, expectedErr string) {
-	fset := token.NewFileSet()
-	testFile := fset.AddFile("", -1, len(input))
+func parseFile(t *testing.T, input string) *ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "", input, parser.ParseComments|parser.DeclarationErrors)
+	require.NoError(t, err)
+
+	return f
+}
+
+// declaredObjects returns the objects declared by the top-level
+// const, let and var declarations of f.
+func declaredObjects(f *ast.File) map[string]*ast.Object {
+	objs := make(map[string]*ast.Object)
+	for _, s := range f.Stmts {
+		if ds, ok := s.(*ast.DeclStmt); ok {
+			for _, spec := range ds.Decl.(*ast.GenDecl).Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					objs[name.Name] = name.Obj
+				}
+			}
+		}
+	}
+	return objs
+}
 
-	_, err := parser.ParseFile(testFile, strings.NewReader(input))
+func expectParseError(t *testing.T, input, expectedErr string) {
+	_, err := parser.ParseFile(token.NewFileSet(), "", input, parser.ParseComments|parser.DeclarationErrors)
 	require.EqualError(t, err, expectedErr)
 }
 
@@ -188,6 +1228,122 @@ func stmts(s ...ast.Stmt) []ast.Stmt {
 	return s
 }
 
+func exprStmt(x ast.Expr) *ast.ExprStmt {
+	return &ast.ExprStmt{Expr: x}
+}
+
+func assignStmt(lhs []ast.Expr, pos token.Pos, tok token.Token, rhs []ast.Expr) *ast.AssignStmt {
+	return &ast.AssignStmt{
+		Lhs:    lhs,
+		TokPos: pos,
+		Tok:    tok,
+		Rhs:    rhs,
+	}
+}
+
+func incDecStmt(x ast.Expr, pos token.Pos, tok token.Token) *ast.IncDecStmt {
+	return &ast.IncDecStmt{
+		Expr:   x,
+		TokPos: pos,
+		Tok:    tok,
+	}
+}
+
+func blockStmt(lBrace, rBrace token.Pos, list ...ast.Stmt) *ast.BlockStmt {
+	return &ast.BlockStmt{
+		Lbrace: lBrace,
+		List:   list,
+		Rbrace: rBrace,
+	}
+}
+
+func ifStmt(pos token.Pos, init ast.Stmt, cond ast.Expr, body *ast.BlockStmt, elseStmt ast.Stmt) *ast.IfStmt {
+	return &ast.IfStmt{
+		If:   pos,
+		Init: init,
+		Cond: cond,
+		Body: body,
+		Else: elseStmt,
+	}
+}
+
+func returnStmt(pos token.Pos, results ...ast.Expr) *ast.ReturnStmt {
+	return &ast.ReturnStmt{
+		Return:  pos,
+		Results: results,
+	}
+}
+
+func branchStmt(pos token.Pos, tok token.Token) *ast.BranchStmt {
+	return &ast.BranchStmt{
+		TokPos: pos,
+		Tok:    tok,
+	}
+}
+
+func caseClause(pos token.Pos, list []ast.Expr, colon token.Pos, body ...ast.Stmt) *ast.CaseClause {
+	return &ast.CaseClause{
+		Case:  pos,
+		List:  list,
+		Colon: colon,
+		Body:  body,
+	}
+}
+
+func switchStmt(pos token.Pos, init ast.Stmt, tag ast.Expr, body *ast.BlockStmt) *ast.SwitchStmt {
+	return &ast.SwitchStmt{
+		Switch: pos,
+		Init:   init,
+		Tag:    tag,
+		Body:   body,
+	}
+}
+
+func typeSwitchStmt(pos token.Pos, init ast.Stmt, lhs *ast.Ident, typeOf token.Pos, x ast.Expr, body *ast.BlockStmt) *ast.TypeSwitchStmt {
+	return &ast.TypeSwitchStmt{
+		Switch: pos,
+		Init:   init,
+		Lhs:    lhs,
+		TypeOf: typeOf,
+		X:      x,
+		Body:   body,
+	}
+}
+
+func forStmt(pos token.Pos, init ast.Stmt, cond ast.Expr, post ast.Stmt, body, elseBlock *ast.BlockStmt) *ast.ForStmt {
+	return &ast.ForStmt{
+		For:  pos,
+		Init: init,
+		Cond: cond,
+		Post: post,
+		Body: body,
+		Else: elseBlock,
+	}
+}
+
+func forInStmt(pos token.Pos, vars []*ast.Ident, in token.Pos, x ast.Expr, ifPos token.Pos, filter ast.Expr, body, elseBlock *ast.BlockStmt) *ast.ForInStmt {
+	return &ast.ForInStmt{
+		For:    pos,
+		Vars:   vars,
+		In:     in,
+		X:      x,
+		If:     ifPos,
+		Filter: filter,
+		Body:   body,
+		Else:   elseBlock,
+	}
+}
+
+func funcDecl(name *ast.Ident, typ *ast.FuncType, body *ast.BlockStmt) ast.Stmt {
+	return &ast.DeclStmt{
+		Decl: &ast.FuncDecl{
+			Name: name,
+			Type: typ,
+			Body: body,
+		},
+	}
+}
+
 func constDecl(pos, lParen, rParen token.Pos, specs ...ast.Spec) ast.Stmt {
 	return genDecl(token.CONST, pos, lParen, rParen, specs)
 }
@@ -220,6 +1376,24 @@ func valueSpec(names []*ast.Ident, typ ast.Expr, values []ast.Expr) *ast.ValueSp
 	}
 }
 
+func fieldList(opening, closing token.Pos, list ...*ast.Field) *ast.FieldList {
+	return &ast.FieldList{
+		Opening: opening,
+		List:    list,
+		Closing: closing,
+	}
+}
+
+func field(names []*ast.Ident, ellipsis token.Pos, typ ast.Expr, assign token.Pos, def ast.Expr) *ast.Field {
+	return &ast.Field{
+		Names:    names,
+		Ellipsis: ellipsis,
+		Type:     typ,
+		Assign:   assign,
+		Default:  def,
+	}
+}
+
 func idents(list ...*ast.Ident) []*ast.Ident {
 	return list
 }
@@ -239,6 +1413,175 @@ func intLit(pos token.Pos, val string) *ast.BasicLit {
 	return basicLit(pos, token.INT, val)
 }
 
+func funcType(pos token.Pos, params, results *ast.FieldList) *ast.FuncType {
+	return &ast.FuncType{
+		Func:    pos,
+		Params:  params,
+		Results: results,
+	}
+}
+
+func funcLit(typ *ast.FuncType, body *ast.BlockStmt) *ast.FuncLit {
+	return &ast.FuncLit{
+		Type: typ,
+		Body: body,
+	}
+}
+
+func callExpr(fun ast.Expr, lParen token.Pos, args []ast.Expr, ellipsis, rParen token.Pos) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun:      fun,
+		Lparen:   lParen,
+		Args:     args,
+		Ellipsis: ellipsis,
+		Rparen:   rParen,
+	}
+}
+
+func keywordArg(name *ast.Ident, assign token.Pos, value ast.Expr) *ast.KeywordArg {
+	return &ast.KeywordArg{
+		Name:   name,
+		Assign: assign,
+		Value:  value,
+	}
+}
+
+func binaryExpr(lhs ast.Expr, pos token.Pos, op token.Token, rhs ast.Expr) *ast.BinaryExpr {
+	return &ast.BinaryExpr{
+		Lhs:   lhs,
+		OpPos: pos,
+		Op:    op,
+		Rhs:   rhs,
+	}
+}
+
+func parenExpr(lParen token.Pos, x ast.Expr, rParen token.Pos) *ast.ParenExpr {
+	return &ast.ParenExpr{
+		Lparen: lParen,
+		Expr:   x,
+		Rparen: rParen,
+	}
+}
+
+func selectorExpr(x ast.Expr, sel *ast.Ident) *ast.SelectorExpr {
+	return &ast.SelectorExpr{
+		X:   x,
+		Sel: sel,
+	}
+}
+
+func indexExpr(x ast.Expr, lBrack token.Pos, index ast.Expr, rBrack token.Pos) *ast.IndexExpr {
+	return &ast.IndexExpr{
+		X:      x,
+		Lbrack: lBrack,
+		Index:  index,
+		Rbrack: rBrack,
+	}
+}
+
+func sliceExpr(x ast.Expr, lBrack token.Pos, low, high, max ast.Expr, slice3 bool, rBrack token.Pos) *ast.SliceExpr {
+	return &ast.SliceExpr{
+		X:      x,
+		Lbrack: lBrack,
+		Low:    low,
+		High:   high,
+		Max:    max,
+		Slice3: slice3,
+		Rbrack: rBrack,
+	}
+}
+
+func typeAssertExpr(x ast.Expr, as token.Pos, typ ast.Expr) *ast.TypeAssertExpr {
+	return &ast.TypeAssertExpr{
+		X:    x,
+		As:   as,
+		Type: typ,
+	}
+}
+
+func interpolatedString(parts ...ast.Expr) *ast.InterpolatedString {
+	return &ast.InterpolatedString{
+		Parts: parts,
+	}
+}
+
+func listLit(lBrack, rBrack token.Pos, elts ...ast.Expr) *ast.ListLit {
+	return &ast.ListLit{
+		Lbrack: lBrack,
+		Elts:   elts,
+		Rbrack: rBrack,
+	}
+}
+
+func setLit(lBrace, rBrace token.Pos, elts ...ast.Expr) *ast.SetLit {
+	return &ast.SetLit{
+		Lbrace: lBrace,
+		Elts:   elts,
+		Rbrace: rBrace,
+	}
+}
+
+func mapLit(lBrace, rBrace token.Pos, elts ...*ast.KeyValueExpr) *ast.MapLit {
+	return &ast.MapLit{
+		Lbrace: lBrace,
+		Elts:   elts,
+		Rbrace: rBrace,
+	}
+}
+
+func tupleLit(lParen, rParen token.Pos, elts ...ast.Expr) *ast.TupleLit {
+	return &ast.TupleLit{
+		Lparen: lParen,
+		Elts:   elts,
+		Rparen: rParen,
+	}
+}
+
+func keyValueExpr(key ast.Expr, colon token.Pos, value ast.Expr) *ast.KeyValueExpr {
+	return &ast.KeyValueExpr{
+		Key:   key,
+		Colon: colon,
+		Value: value,
+	}
+}
+
+func listType(pos, lBrack token.Pos, elt ast.Expr, rBrack token.Pos) *ast.ListType {
+	return &ast.ListType{
+		List:   pos,
+		Lbrack: lBrack,
+		Elt:    elt,
+		Rbrack: rBrack,
+	}
+}
+
+func setType(pos, lBrack token.Pos, elt ast.Expr, rBrack token.Pos) *ast.SetType {
+	return &ast.SetType{
+		Set:    pos,
+		Lbrack: lBrack,
+		Elt:    elt,
+		Rbrack: rBrack,
+	}
+}
+
+func mapType(pos, lBrack token.Pos, key ast.Expr, rBrack token.Pos, value ast.Expr) *ast.MapType {
+	return &ast.MapType{
+		Map:    pos,
+		Lbrack: lBrack,
+		Key:    key,
+		Rbrack: rBrack,
+		Value:  value,
+	}
+}
+
+func tupleType(pos, lBrack, rBrack token.Pos, elts ...ast.Expr) *ast.TupleType {
+	return &ast.TupleType{
+		Tuple:  pos,
+		Lbrack: lBrack,
+		Elts:   elts,
+		Rbrack: rBrack,
+	}
+}
+
 func basicLit(pos token.Pos, kind token.Token, val string) *ast.BasicLit {
 	return &ast.BasicLit{
 		ValuePos: pos,
@@ -258,6 +1601,84 @@ func equalStmt(t *testing.T, expected, actual ast.Stmt) {
 	switch expected := expected.(type) {
 	case *ast.DeclStmt:
 		equalDecl(t, expected.Decl, actual.(*ast.DeclStmt).Decl)
+	case *ast.ExprStmt:
+		equalExpr(t, expected.Expr, actual.(*ast.ExprStmt).Expr)
+	case *ast.AssignStmt:
+		require.Equal(t, len(expected.Lhs), len(actual.(*ast.AssignStmt).Lhs))
+		for i := 0; i < len(expected.Lhs); i++ {
+			equalExpr(t, expected.Lhs[i], actual.(*ast.AssignStmt).Lhs[i])
+		}
+		require.Equal(t, expected.TokPos, actual.(*ast.AssignStmt).TokPos)
+		require.Equal(t, expected.Tok, actual.(*ast.AssignStmt).Tok)
+		require.Equal(t, len(expected.Rhs), len(actual.(*ast.AssignStmt).Rhs))
+		for i := 0; i < len(expected.Rhs); i++ {
+			equalExpr(t, expected.Rhs[i], actual.(*ast.AssignStmt).Rhs[i])
+		}
+	case *ast.IncDecStmt:
+		equalExpr(t, expected.Expr, actual.(*ast.IncDecStmt).Expr)
+		require.Equal(t, expected.TokPos, actual.(*ast.IncDecStmt).TokPos)
+		require.Equal(t, expected.Tok, actual.(*ast.IncDecStmt).Tok)
+	case *ast.BlockStmt:
+		require.Equal(t, expected.Lbrace, actual.(*ast.BlockStmt).Lbrace)
+		require.Equal(t, expected.Rbrace, actual.(*ast.BlockStmt).Rbrace)
+		require.Equal(t, len(expected.List), len(actual.(*ast.BlockStmt).List))
+		for i := 0; i < len(expected.List); i++ {
+			equalStmt(t, expected.List[i], actual.(*ast.BlockStmt).List[i])
+		}
+	case *ast.IfStmt:
+		require.Equal(t, expected.If, actual.(*ast.IfStmt).If)
+		equalStmt(t, expected.Init, actual.(*ast.IfStmt).Init)
+		equalExpr(t, expected.Cond, actual.(*ast.IfStmt).Cond)
+		equalStmt(t, expected.Body, actual.(*ast.IfStmt).Body)
+		equalStmt(t, expected.Else, actual.(*ast.IfStmt).Else)
+	case *ast.ReturnStmt:
+		require.Equal(t, expected.Return, actual.(*ast.ReturnStmt).Return)
+		require.Equal(t, len(expected.Results), len(actual.(*ast.ReturnStmt).Results))
+		for i := 0; i < len(expected.Results); i++ {
+			equalExpr(t, expected.Results[i], actual.(*ast.ReturnStmt).Results[i])
+		}
+	case *ast.BranchStmt:
+		require.Equal(t, expected.TokPos, actual.(*ast.BranchStmt).TokPos)
+		require.Equal(t, expected.Tok, actual.(*ast.BranchStmt).Tok)
+	case *ast.CaseClause:
+		require.Equal(t, expected.Case, actual.(*ast.CaseClause).Case)
+		equalExprs(t, expected.List, actual.(*ast.CaseClause).List)
+		require.Equal(t, expected.Colon, actual.(*ast.CaseClause).Colon)
+		require.Equal(t, len(expected.Body), len(actual.(*ast.CaseClause).Body))
+		for i := 0; i < len(expected.Body); i++ {
+			equalStmt(t, expected.Body[i], actual.(*ast.CaseClause).Body[i])
+		}
+	case *ast.SwitchStmt:
+		require.Equal(t, expected.Switch, actual.(*ast.SwitchStmt).Switch)
+		equalStmt(t, expected.Init, actual.(*ast.SwitchStmt).Init)
+		equalExpr(t, expected.Tag, actual.(*ast.SwitchStmt).Tag)
+		equalStmt(t, expected.Body, actual.(*ast.SwitchStmt).Body)
+	case *ast.TypeSwitchStmt:
+		require.Equal(t, expected.Switch, actual.(*ast.TypeSwitchStmt).Switch)
+		equalStmt(t, expected.Init, actual.(*ast.TypeSwitchStmt).Init)
+		equalIdent(t, expected.Lhs, actual.(*ast.TypeSwitchStmt).Lhs)
+		require.Equal(t, expected.TypeOf, actual.(*ast.TypeSwitchStmt).TypeOf)
+		equalExpr(t, expected.X, actual.(*ast.TypeSwitchStmt).X)
+		equalStmt(t, expected.Body, actual.(*ast.TypeSwitchStmt).Body)
+	case *ast.ForStmt:
+		require.Equal(t, expected.For, actual.(*ast.ForStmt).For)
+		equalStmt(t, expected.Init, actual.(*ast.ForStmt).Init)
+		equalExpr(t, expected.Cond, actual.(*ast.ForStmt).Cond)
+		equalStmt(t, expected.Post, actual.(*ast.ForStmt).Post)
+		equalStmt(t, expected.Body, actual.(*ast.ForStmt).Body)
+		equalStmt(t, expected.Else, actual.(*ast.ForStmt).Else)
+	case *ast.ForInStmt:
+		require.Equal(t, expected.For, actual.(*ast.ForInStmt).For)
+		require.Equal(t, len(expected.Vars), len(actual.(*ast.ForInStmt).Vars))
+		for i := 0; i < len(expected.Vars); i++ {
+			equalIdent(t, expected.Vars[i], actual.(*ast.ForInStmt).Vars[i])
+		}
+		require.Equal(t, expected.In, actual.(*ast.ForInStmt).In)
+		equalExpr(t, expected.X, actual.(*ast.ForInStmt).X)
+		require.Equal(t, expected.If, actual.(*ast.ForInStmt).If)
+		equalExpr(t, expected.Filter, actual.(*ast.ForInStmt).Filter)
+		equalStmt(t, expected.Body, actual.(*ast.ForInStmt).Body)
+		equalStmt(t, expected.Else, actual.(*ast.ForInStmt).Else)
 	default:
 		panic(fmt.Errorf("unknown type: %T", expected))
 	}
@@ -282,6 +1703,10 @@ func equalDecl(t *testing.T, expected, actual ast.Decl) {
 		for i := 0; i < len(expected.Specs); i++ {
 			equalSpec(t, expected.Specs[i], actual.(*ast.GenDecl).Specs[i])
 		}
+	case *ast.FuncDecl:
+		equalIdent(t, expected.Name, actual.(*ast.FuncDecl).Name)
+		equalExpr(t, expected.Type, actual.(*ast.FuncDecl).Type)
+		equalStmt(t, expected.Body, actual.(*ast.FuncDecl).Body)
 	default:
 		panic(fmt.Errorf("unknown type: %T", expected))
 	}
@@ -339,7 +1764,131 @@ func equalExpr(t *testing.T, expected, actual ast.Expr) {
 		require.Equal(t, expected.ValuePos, actual.(*ast.BasicLit).ValuePos)
 		require.Equal(t, expected.Kind, actual.(*ast.BasicLit).Kind)
 		require.Equal(t, expected.Value, actual.(*ast.BasicLit).Value)
+	case *ast.FuncLit:
+		equalExpr(t, expected.Type, actual.(*ast.FuncLit).Type)
+		equalStmt(t, expected.Body, actual.(*ast.FuncLit).Body)
+	case *ast.CallExpr:
+		equalExpr(t, expected.Fun, actual.(*ast.CallExpr).Fun)
+		require.Equal(t, expected.Lparen, actual.(*ast.CallExpr).Lparen)
+		require.Equal(t, len(expected.Args), len(actual.(*ast.CallExpr).Args))
+		for i := 0; i < len(expected.Args); i++ {
+			equalExpr(t, expected.Args[i], actual.(*ast.CallExpr).Args[i])
+		}
+		require.Equal(t, expected.Ellipsis, actual.(*ast.CallExpr).Ellipsis)
+		require.Equal(t, expected.Rparen, actual.(*ast.CallExpr).Rparen)
+	case *ast.KeywordArg:
+		equalIdent(t, expected.Name, actual.(*ast.KeywordArg).Name)
+		require.Equal(t, expected.Assign, actual.(*ast.KeywordArg).Assign)
+		equalExpr(t, expected.Value, actual.(*ast.KeywordArg).Value)
+	case *ast.FuncType:
+		require.Equal(t, expected.Func, actual.(*ast.FuncType).Func)
+		equalFieldList(t, expected.Params, actual.(*ast.FuncType).Params)
+		equalFieldList(t, expected.Results, actual.(*ast.FuncType).Results)
+	case *ast.BinaryExpr:
+		equalExpr(t, expected.Lhs, actual.(*ast.BinaryExpr).Lhs)
+		require.Equal(t, expected.OpPos, actual.(*ast.BinaryExpr).OpPos)
+		require.Equal(t, expected.Op, actual.(*ast.BinaryExpr).Op)
+		equalExpr(t, expected.Rhs, actual.(*ast.BinaryExpr).Rhs)
+	case *ast.ParenExpr:
+		require.Equal(t, expected.Lparen, actual.(*ast.ParenExpr).Lparen)
+		equalExpr(t, expected.Expr, actual.(*ast.ParenExpr).Expr)
+		require.Equal(t, expected.Rparen, actual.(*ast.ParenExpr).Rparen)
+	case *ast.InterpolatedString:
+		equalExprs(t, expected.Parts, actual.(*ast.InterpolatedString).Parts)
+	case *ast.SelectorExpr:
+		equalExpr(t, expected.X, actual.(*ast.SelectorExpr).X)
+		equalIdent(t, expected.Sel, actual.(*ast.SelectorExpr).Sel)
+	case *ast.IndexExpr:
+		equalExpr(t, expected.X, actual.(*ast.IndexExpr).X)
+		require.Equal(t, expected.Lbrack, actual.(*ast.IndexExpr).Lbrack)
+		equalExpr(t, expected.Index, actual.(*ast.IndexExpr).Index)
+		require.Equal(t, expected.Rbrack, actual.(*ast.IndexExpr).Rbrack)
+	case *ast.SliceExpr:
+		equalExpr(t, expected.X, actual.(*ast.SliceExpr).X)
+		require.Equal(t, expected.Lbrack, actual.(*ast.SliceExpr).Lbrack)
+		equalExpr(t, expected.Low, actual.(*ast.SliceExpr).Low)
+		equalExpr(t, expected.High, actual.(*ast.SliceExpr).High)
+		equalExpr(t, expected.Max, actual.(*ast.SliceExpr).Max)
+		require.Equal(t, expected.Slice3, actual.(*ast.SliceExpr).Slice3)
+		require.Equal(t, expected.Rbrack, actual.(*ast.SliceExpr).Rbrack)
+	case *ast.TypeAssertExpr:
+		equalExpr(t, expected.X, actual.(*ast.TypeAssertExpr).X)
+		require.Equal(t, expected.As, actual.(*ast.TypeAssertExpr).As)
+		equalExpr(t, expected.Type, actual.(*ast.TypeAssertExpr).Type)
+	case *ast.ListLit:
+		require.Equal(t, expected.Lbrack, actual.(*ast.ListLit).Lbrack)
+		equalExprs(t, expected.Elts, actual.(*ast.ListLit).Elts)
+		require.Equal(t, expected.Rbrack, actual.(*ast.ListLit).Rbrack)
+	case *ast.SetLit:
+		require.Equal(t, expected.Lbrace, actual.(*ast.SetLit).Lbrace)
+		equalExprs(t, expected.Elts, actual.(*ast.SetLit).Elts)
+		require.Equal(t, expected.Rbrace, actual.(*ast.SetLit).Rbrace)
+	case *ast.MapLit:
+		require.Equal(t, expected.Lbrace, actual.(*ast.MapLit).Lbrace)
+		require.Equal(t, len(expected.Elts), len(actual.(*ast.MapLit).Elts))
+		for i := 0; i < len(expected.Elts); i++ {
+			equalExpr(t, expected.Elts[i], actual.(*ast.MapLit).Elts[i])
+		}
+		require.Equal(t, expected.Rbrace, actual.(*ast.MapLit).Rbrace)
+	case *ast.TupleLit:
+		require.Equal(t, expected.Lparen, actual.(*ast.TupleLit).Lparen)
+		equalExprs(t, expected.Elts, actual.(*ast.TupleLit).Elts)
+		require.Equal(t, expected.Rparen, actual.(*ast.TupleLit).Rparen)
+	case *ast.KeyValueExpr:
+		equalExpr(t, expected.Key, actual.(*ast.KeyValueExpr).Key)
+		require.Equal(t, expected.Colon, actual.(*ast.KeyValueExpr).Colon)
+		equalExpr(t, expected.Value, actual.(*ast.KeyValueExpr).Value)
+	case *ast.ListType:
+		require.Equal(t, expected.List, actual.(*ast.ListType).List)
+		require.Equal(t, expected.Lbrack, actual.(*ast.ListType).Lbrack)
+		equalExpr(t, expected.Elt, actual.(*ast.ListType).Elt)
+		require.Equal(t, expected.Rbrack, actual.(*ast.ListType).Rbrack)
+	case *ast.SetType:
+		require.Equal(t, expected.Set, actual.(*ast.SetType).Set)
+		require.Equal(t, expected.Lbrack, actual.(*ast.SetType).Lbrack)
+		equalExpr(t, expected.Elt, actual.(*ast.SetType).Elt)
+		require.Equal(t, expected.Rbrack, actual.(*ast.SetType).Rbrack)
+	case *ast.MapType:
+		require.Equal(t, expected.Map, actual.(*ast.MapType).Map)
+		require.Equal(t, expected.Lbrack, actual.(*ast.MapType).Lbrack)
+		equalExpr(t, expected.Key, actual.(*ast.MapType).Key)
+		require.Equal(t, expected.Rbrack, actual.(*ast.MapType).Rbrack)
+		equalExpr(t, expected.Value, actual.(*ast.MapType).Value)
+	case *ast.TupleType:
+		require.Equal(t, expected.Tuple, actual.(*ast.TupleType).Tuple)
+		require.Equal(t, expected.Lbrack, actual.(*ast.TupleType).Lbrack)
+		equalExprs(t, expected.Elts, actual.(*ast.TupleType).Elts)
+		require.Equal(t, expected.Rbrack, actual.(*ast.TupleType).Rbrack)
 	default:
 		panic(fmt.Errorf("unknown type: %T", expected))
 	}
 }
+
+func equalExprs(t *testing.T, expected, actual []ast.Expr) {
+	require.Equal(t, len(expected), len(actual))
+	for i := 0; i < len(expected); i++ {
+		equalExpr(t, expected[i], actual[i])
+	}
+}
+
+func equalFieldList(t *testing.T, expected, actual *ast.FieldList) {
+	if expected == nil {
+		require.Nil(t, actual, "expected nil, but got not nil")
+		return
+	}
+	require.NotNil(t, actual, "expected not nil, but got nil")
+
+	require.Equal(t, expected.Opening, actual.Opening)
+	require.Equal(t, expected.Closing, actual.Closing)
+	require.Equal(t, len(expected.List), len(actual.List))
+	for i := 0; i < len(expected.List); i++ {
+		require.Equal(t, len(expected.List[i].Names), len(actual.List[i].Names))
+		for j := 0; j < len(expected.List[i].Names); j++ {
+			equalIdent(t, expected.List[i].Names[j], actual.List[i].Names[j])
+		}
+		require.Equal(t, expected.List[i].Ellipsis, actual.List[i].Ellipsis)
+		equalExpr(t, expected.List[i].Type, actual.List[i].Type)
+		require.Equal(t, expected.List[i].Assign, actual.List[i].Assign)
+		equalExpr(t, expected.List[i].Default, actual.List[i].Default)
+	}
+}
@@ -1,7 +1,12 @@
 package parser
 
 import (
+	"fmt"
+	"go/constant"
+	gotoken "go/token"
+
 	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
 	"github.com/capnspacehook/rose/token"
 )
 
@@ -13,6 +18,35 @@ func (p *Parser) parseStmt() (s ast.Stmt) {
 	switch p.tok {
 	case token.CONST, token.LET, token.VAR:
 		s = &ast.DeclStmt{Decl: p.parseDecl()}
+	case token.FN:
+		if p.lexer.Peek() == token.IDENT {
+			s = &ast.DeclStmt{Decl: p.parseFuncDecl()}
+			break
+		}
+		fallthrough // function literal
+	case
+		// tokens that may start an expression
+		token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING, token.RAW_STRING, token.STRING_HEAD, token.LPAREN, // operands
+		token.LBRACK, token.LBRACE, // composite literals
+		token.ADD, token.SUB, token.NOT, token.INVT, token.AND: // unary operators
+		s, _ = p.parseSimpleStmt(basic)
+		p.expectSemi()
+	case token.RETURN:
+		s = p.parseReturnStmt()
+	case token.BREAK, token.CONTINUE, token.FALLTHROUGH:
+		s = p.parseBranchStmt(p.tok)
+	case token.IF:
+		s = p.parseIfStmt()
+	case token.SWITCH:
+		s = p.parseSwitchStmt()
+	case token.FOR:
+		s = p.parseForStmt()
+	case token.SEMI:
+		// Is it ever possible to have an implicit semicolon
+		// producing an empty statement in a valid program?
+		// (handle correctly anyway)
+		s = &ast.EmptyStmt{Semicolon: p.pos, Implicit: p.lit == "\n"}
+		p.next()
 	default:
 		// no statement found
 		pos := p.pos
@@ -24,7 +58,586 @@ func (p *Parser) parseStmt() (s ast.Stmt) {
 	return
 }
 
-type parseSpecFunc func(keyword token.Token, i int) ast.Spec
+func (p *Parser) parseStmtList() (list []ast.Stmt) {
+	if p.trace {
+		defer un(trace(p, "StatementList"))
+	}
+
+	for p.tok != token.CASE && p.tok != token.DEFAULT && p.tok != token.RBRACE && p.tok != token.EOF {
+		list = append(list, p.parseStmt())
+	}
+
+	return
+}
+
+func (p *Parser) parseBlockStmt() *ast.BlockStmt {
+	if p.trace {
+		defer un(trace(p, "BlockStmt"))
+	}
+
+	lbrace := p.expect(token.LBRACE)
+	p.openScope()
+	list := p.parseStmtList()
+	p.closeScope()
+	rbrace := p.expect(token.RBRACE)
+
+	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
+}
+
+// parseSimpleStmt returns true as 2nd result if it parsed the in
+// clause of a for-in statement (with mode == inOk) or the guard of a
+// type switch (with mode == typeofOk). The in clause is returned as
+// an assignment with Tok == token.IN, and the guard as an assignment
+// with Tok == token.TYPEOF; the variables on the left side are not
+// declared.
+const (
+	basic = iota
+	inOk
+	typeofOk
+)
+
+func (p *Parser) parseSimpleStmt(mode int) (ast.Stmt, bool) {
+	if p.trace {
+		defer un(trace(p, "SimpleStmt"))
+	}
+
+	x := p.parseLhsList()
+
+	switch p.tok {
+	case token.ASSIGN:
+		// assignment statement, possibly declaring variables
+		pos, tok := p.pos, p.tok
+		p.next()
+		if mode == typeofOk && p.tok == token.TYPEOF && len(x) == 1 {
+			return p.parseTypeOfGuard(x), true
+		}
+		y := p.parseAssignRhs(len(x))
+		as := &ast.AssignStmt{Lhs: x, TokPos: pos, Tok: tok, Rhs: y}
+		p.assignVarDecl(as, x)
+		return as, false
+	case
+		token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN,
+		token.REM_ASSIGN, token.EXP_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN,
+		token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN:
+		// assignment operation
+		pos, tok := p.pos, p.tok
+		p.next()
+		y := p.parseRhsList()
+		if len(x) > 1 || len(y) > 1 {
+			p.error(pos, "assignment operation "+tok.String()+" requires single-valued expressions")
+		}
+		return &ast.AssignStmt{Lhs: x, TokPos: pos, Tok: tok, Rhs: y}, false
+	case token.IN:
+		if mode == inOk {
+			pos := p.pos
+			p.next()
+			y := []ast.Expr{p.checkExpr(p.parseExpr(false))}
+			return &ast.AssignStmt{Lhs: x, TokPos: pos, Tok: token.IN, Rhs: y}, true
+		}
+	}
+
+	if len(x) > 1 {
+		p.errorExpected(x[0].Pos(), "1 expression")
+		// continue with first expression
+	}
+
+	switch p.tok {
+	case token.INC, token.DEC:
+		// increment or decrement
+		s := &ast.IncDecStmt{Expr: x[0], TokPos: p.pos, Tok: p.tok}
+		p.next()
+		return s, false
+	}
+
+	// expression
+	return &ast.ExprStmt{Expr: x[0]}, false
+}
+
+func (p *Parser) makeExpr(s ast.Stmt, want string) ast.Expr {
+	if s == nil {
+		return nil
+	}
+	if es, isExpr := s.(*ast.ExprStmt); isExpr {
+		return p.checkExpr(es.Expr)
+	}
+	found := "simple statement"
+	if _, isAss := s.(*ast.AssignStmt); isAss {
+		found = "assignment"
+	}
+	p.error(s.Pos(), fmt.Sprintf("expected %s, found %s", want, found))
+	return &ast.BadExpr{From: s.Pos(), To: p.safePos(s.End())}
+}
+
+func (p *Parser) parseIfHeader() (init ast.Stmt, cond ast.Expr) {
+	if p.tok == token.LBRACE {
+		p.error(p.pos, "missing condition in if statement")
+		cond = &ast.BadExpr{From: p.pos, To: p.pos}
+		return
+	}
+	// p.tok != token.LBRACE
+
+	prevLev := p.exprLev
+	p.exprLev = -1
+
+	if p.tok != token.SEMI {
+		init, _ = p.parseSimpleStmt(basic)
+	}
+
+	var condStmt ast.Stmt
+	var semi struct {
+		pos token.Pos
+		lit string // ";" or "\n"; valid if pos.IsValid()
+	}
+	if p.tok != token.LBRACE {
+		if p.tok == token.SEMI {
+			semi.pos = p.pos
+			semi.lit = p.lit
+			p.next()
+		} else {
+			p.expect(token.SEMI)
+		}
+		if p.tok != token.LBRACE {
+			condStmt, _ = p.parseSimpleStmt(basic)
+		}
+	} else {
+		condStmt = init
+		init = nil
+	}
+
+	if condStmt != nil {
+		cond = p.makeExpr(condStmt, "boolean expression")
+	} else if semi.pos.IsValid() {
+		if semi.lit == "\n" {
+			p.error(semi.pos, "unexpected newline, expecting { after if clause")
+		} else {
+			p.error(semi.pos, "missing condition in if statement")
+		}
+	}
+
+	// make sure we have a valid AST
+	if cond == nil {
+		cond = &ast.BadExpr{From: p.pos, To: p.pos}
+	}
+
+	p.exprLev = prevLev
+
+	return
+}
+
+func (p *Parser) parseIfStmt() *ast.IfStmt {
+	if p.trace {
+		defer un(trace(p, "IfStmt"))
+	}
+
+	pos := p.expect(token.IF)
+	p.openScope()
+	defer p.closeScope()
+
+	init, cond := p.parseIfHeader()
+	body := p.parseBlockStmt()
+
+	var elseStmt ast.Stmt
+	if p.tok == token.ELSE {
+		p.next()
+		switch p.tok {
+		case token.IF:
+			elseStmt = p.parseIfStmt()
+		case token.LBRACE:
+			elseStmt = p.parseBlockStmt()
+			p.expectSemi()
+		default:
+			p.errorExpected(p.pos, "if statement or block")
+			elseStmt = &ast.BadStmt{From: p.pos, To: p.pos}
+		}
+	} else {
+		p.expectSemi()
+	}
+
+	return &ast.IfStmt{If: pos, Init: init, Cond: cond, Body: body, Else: elseStmt}
+}
+
+func (p *Parser) parseReturnStmt() *ast.ReturnStmt {
+	if p.trace {
+		defer un(trace(p, "ReturnStmt"))
+	}
+
+	pos := p.pos
+	p.expect(token.RETURN)
+	if p.funcLev == 0 {
+		p.errorCode(pos, diagnostics.MisplacedBranch, "return is not in a function")
+	}
+	var x []ast.Expr
+	if p.tok != token.SEMI && p.tok != token.RBRACE {
+		x = p.parseRhsList()
+	}
+	p.expectSemi()
+
+	return &ast.ReturnStmt{Return: pos, Results: x}
+}
+
+func (p *Parser) parseBranchStmt(tok token.Token) *ast.BranchStmt {
+	if p.trace {
+		defer un(trace(p, "BranchStmt"))
+	}
+
+	pos := p.expect(tok)
+	switch tok {
+	case token.BREAK:
+		if p.loopLev == 0 && p.switchLev == 0 {
+			p.errorCode(pos, diagnostics.MisplacedBranch, "break is not in a loop or switch")
+		}
+	case token.CONTINUE:
+		if p.loopLev == 0 {
+			p.errorCode(pos, diagnostics.MisplacedBranch, "continue is not in a loop")
+		}
+	case token.FALLTHROUGH:
+		// fallthrough statements that end a case clause
+		// are parsed by parseCaseClause
+		p.errorCode(pos, diagnostics.MisplacedBranch, "fallthrough statement out of place")
+	}
+	p.expectSemi()
+
+	return &ast.BranchStmt{TokPos: pos, Tok: tok}
+}
+
+func (p *Parser) parseForStmt() ast.Stmt {
+	if p.trace {
+		defer un(trace(p, "ForStmt"))
+	}
+
+	pos := p.expect(token.FOR)
+	p.openScope()
+	defer p.closeScope()
+
+	var s1, s2, s3 ast.Stmt
+	var isIn bool
+	if p.tok != token.LBRACE {
+		prevLev := p.exprLev
+		p.exprLev = -1
+		if p.tok != token.SEMI {
+			p.inForHeader = true
+			s2, isIn = p.parseSimpleStmt(inOk)
+			p.inForHeader = false
+		}
+		if !isIn && p.tok == token.SEMI {
+			p.next()
+			s1 = s2
+			s2 = nil
+			if p.tok != token.SEMI {
+				s2, _ = p.parseSimpleStmt(basic)
+			}
+			p.expectSemi()
+			if p.tok != token.LBRACE {
+				s3, _ = p.parseSimpleStmt(basic)
+			}
+		}
+		p.exprLev = prevLev
+	}
+
+	if isIn {
+		as := s2.(*ast.AssignStmt)
+		s := &ast.ForInStmt{
+			For: pos,
+			In:  as.TokPos,
+			X:   as.Rhs[0],
+		}
+		for _, x := range as.Lhs {
+			if ident, isIdent := x.(*ast.Ident); isIdent {
+				s.Vars = append(s.Vars, ident)
+			} else {
+				p.errorExpected(x.Pos(), "identifier")
+			}
+		}
+		// the loop variables are visible to the filter and body
+		p.declare(s, nil, p.topScope, ast.Var, s.Vars...)
+
+		if p.tok == token.IF {
+			s.If = p.pos
+			p.next()
+			prevLev := p.exprLev
+			p.exprLev = -1
+			s.Filter = p.checkExpr(p.parseExpr(false))
+			p.exprLev = prevLev
+		}
+		s.Body, s.Else = p.parseLoopBody()
+
+		return s
+	}
+
+	// regular for statement
+	body, elseBlock := p.parseLoopBody()
+
+	return &ast.ForStmt{
+		For:  pos,
+		Init: s1,
+		Cond: p.makeExpr(s2, "boolean expression"),
+		Post: s3,
+		Body: body,
+		Else: elseBlock,
+	}
+}
+
+// parseTypeOfGuard parses the typeof clause of a type switch. The
+// guard is returned as an assignment with Tok == token.TYPEOF and
+// the variable being bound, if any, as the only Lhs element.
+func (p *Parser) parseTypeOfGuard(lhs []ast.Expr) *ast.AssignStmt {
+	if p.trace {
+		defer un(trace(p, "TypeOfGuard"))
+	}
+
+	pos := p.expect(token.TYPEOF)
+	x := p.checkExpr(p.parsePrimaryExpr(false))
+
+	return &ast.AssignStmt{Lhs: lhs, TokPos: pos, Tok: token.TYPEOF, Rhs: []ast.Expr{x}}
+}
+
+func (p *Parser) parseSwitchStmt() ast.Stmt {
+	if p.trace {
+		defer un(trace(p, "SwitchStmt"))
+	}
+
+	pos := p.expect(token.SWITCH)
+	p.openScope()
+	defer p.closeScope()
+
+	var s1, s2 ast.Stmt
+	var isTypeSwitch bool
+	if p.tok != token.LBRACE {
+		prevLev := p.exprLev
+		p.exprLev = -1
+		if p.tok != token.SEMI {
+			s2, isTypeSwitch = p.parseSwitchHeader()
+		}
+		if p.tok == token.SEMI {
+			p.next()
+			if isTypeSwitch {
+				p.errorCode(s2.(*ast.AssignStmt).TokPos, diagnostics.MisplacedBranch, "typeof used outside type switch")
+			}
+			s1 = s2
+			s2, isTypeSwitch = nil, false
+			if p.tok != token.LBRACE {
+				s2, isTypeSwitch = p.parseSwitchHeader()
+			}
+		}
+		p.exprLev = prevLev
+	}
+
+	if isTypeSwitch {
+		guard := s2.(*ast.AssignStmt)
+		s := &ast.TypeSwitchStmt{
+			Switch: pos,
+			Init:   s1,
+			TypeOf: guard.TokPos,
+			X:      guard.Rhs[0],
+		}
+		if len(guard.Lhs) == 1 {
+			if ident, isIdent := guard.Lhs[0].(*ast.Ident); isIdent {
+				s.Lhs = ident
+			} else {
+				p.errorExpected(guard.Lhs[0].Pos(), "identifier")
+			}
+		}
+		s.Body = p.parseSwitchBody(s)
+
+		return s
+	}
+
+	s := &ast.SwitchStmt{
+		Switch: pos,
+		Init:   s1,
+		Tag:    p.makeExpr(s2, "switch expression"),
+	}
+	s.Body = p.parseSwitchBody(nil)
+	p.checkDuplicateCases(s.Body)
+
+	return s
+}
+
+// parseSwitchHeader parses the tag statement of a switch, which
+// may be the guard of a type switch.
+func (p *Parser) parseSwitchHeader() (ast.Stmt, bool) {
+	if p.tok == token.TYPEOF {
+		return p.parseTypeOfGuard(nil), true
+	}
+	return p.parseSimpleStmt(typeofOk)
+}
+
+// parseSwitchBody parses the case clauses of a switch statement;
+// ts is the enclosing type switch, if any.
+func (p *Parser) parseSwitchBody(ts *ast.TypeSwitchStmt) *ast.BlockStmt {
+	if p.trace {
+		defer un(trace(p, "SwitchBody"))
+	}
+
+	lbrace := p.expect(token.LBRACE)
+	p.switchLev++
+	var list []ast.Stmt
+	var def token.Pos // position of the default clause, if any
+	for p.tok == token.CASE || p.tok == token.DEFAULT {
+		cc := p.parseCaseClause(ts)
+		if cc.List == nil {
+			if def.IsValid() {
+				p.errorCode(cc.Case, diagnostics.DuplicateCase, fmt.Sprintf("multiple defaults in switch (first at %s)", p.file.Position(def)))
+			}
+			def = cc.Case
+		}
+		list = append(list, cc)
+	}
+	p.switchLev--
+	rbrace := p.expect(token.RBRACE)
+	p.expectSemi()
+
+	// a fallthrough may not transfer control out of the switch
+	if n := len(list); n > 0 {
+		if s := fallthroughStmt(list[n-1].(*ast.CaseClause)); s != nil && ts == nil {
+			p.errorCode(s.TokPos, diagnostics.MisplacedBranch, "cannot fallthrough final case in switch")
+		}
+	}
+
+	return &ast.BlockStmt{Lbrace: lbrace, List: list, Rbrace: rbrace}
+}
+
+// fallthroughStmt returns the fallthrough statement ending the
+// case clause cc, if any.
+func fallthroughStmt(cc *ast.CaseClause) *ast.BranchStmt {
+	if n := len(cc.Body); n > 0 {
+		if s, isBranch := cc.Body[n-1].(*ast.BranchStmt); isBranch && s.Tok == token.FALLTHROUGH {
+			return s
+		}
+	}
+	return nil
+}
+
+// parseCaseClause parses a case clause of a switch statement; ts
+// is the enclosing type switch, if any. The variable bound by a
+// type switch is declared in the scope of each clause, with the
+// case type as its type if the clause lists exactly one type.
+func (p *Parser) parseCaseClause(ts *ast.TypeSwitchStmt) *ast.CaseClause {
+	if p.trace {
+		defer un(trace(p, "CaseClause"))
+	}
+
+	pos := p.pos
+	var list []ast.Expr
+	if p.tok == token.CASE {
+		p.next()
+		if ts != nil {
+			list = p.parseTypeList()
+		} else {
+			list = p.parseRhsList()
+		}
+	} else {
+		p.expect(token.DEFAULT)
+	}
+
+	colon := p.expect(token.COLON)
+	p.openScope()
+	if ts != nil && ts.Lhs != nil {
+		obj := ast.NewObj(ast.Var, ts.Lhs.Name)
+		obj.Decl = ts
+		if len(list) == 1 {
+			obj.Type = list[0]
+		}
+		p.topScope.Insert(obj)
+	}
+
+	var body []ast.Stmt
+	for p.tok != token.CASE && p.tok != token.DEFAULT && p.tok != token.RBRACE && p.tok != token.EOF {
+		if p.tok != token.FALLTHROUGH {
+			body = append(body, p.parseStmt())
+			continue
+		}
+
+		// a fallthrough is only valid as the last
+		// statement of an expression switch clause
+		s := &ast.BranchStmt{TokPos: p.pos, Tok: token.FALLTHROUGH}
+		p.next()
+		p.expectSemi()
+		switch {
+		case ts != nil:
+			p.errorCode(s.TokPos, diagnostics.MisplacedBranch, "cannot fallthrough in type switch")
+		case p.tok != token.CASE && p.tok != token.DEFAULT && p.tok != token.RBRACE:
+			p.errorCode(s.TokPos, diagnostics.MisplacedBranch, "fallthrough statement out of place")
+		}
+		body = append(body, s)
+	}
+	p.closeScope()
+
+	return &ast.CaseClause{Case: pos, List: list, Colon: colon, Body: body}
+}
+
+func (p *Parser) parseTypeList() (list []ast.Expr) {
+	if p.trace {
+		defer un(trace(p, "TypeList"))
+	}
+
+	list = append(list, p.parseType())
+	for p.tok == token.COMMA {
+		p.next()
+		list = append(list, p.parseType())
+	}
+
+	return
+}
+
+// checkDuplicateCases reports constant case values of an expression
+// switch that are equal to a value of a previous case.
+func (p *Parser) checkDuplicateCases(body *ast.BlockStmt) {
+	type caseValue struct {
+		val  constant.Value
+		span diagnostics.Span
+	}
+	var seen []caseValue
+	for _, s := range body.List {
+		for _, x := range s.(*ast.CaseClause).List {
+			val, err := constValue(x, nil)
+			if err != nil || val.Kind() == constant.Unknown {
+				continue
+			}
+			for _, prev := range seen {
+				if comparableValues(prev.val, val) && constant.Compare(prev.val, gotoken.EQL, val) {
+					p.report(&diagnostics.Diagnostic{
+						Code: diagnostics.DuplicateCase,
+						Span: diagnostics.Span{Pos: x.Pos(), End: x.End()},
+						Msg:  fmt.Sprintf("duplicate case %s in switch", val),
+						Related: []diagnostics.Related{{
+							Pos:  p.file.Position(prev.span.Pos),
+							Span: prev.span,
+							Msg:  "previous case",
+						}},
+					})
+					break
+				}
+			}
+			seen = append(seen, caseValue{val: val, span: diagnostics.Span{Pos: x.Pos(), End: x.End()}})
+		}
+	}
+}
+
+func comparableValues(x, y constant.Value) bool {
+	isNumeric := func(v constant.Value) bool {
+		return v.Kind() == constant.Int || v.Kind() == constant.Float
+	}
+	return x.Kind() == y.Kind() || isNumeric(x) && isNumeric(y)
+}
+
+// parseLoopBody parses the body of a for statement and its optional
+// else clause.
+func (p *Parser) parseLoopBody() (body, elseBlock *ast.BlockStmt) {
+	p.loopLev++
+	body = p.parseBlockStmt()
+	p.loopLev--
+
+	if p.tok == token.ELSE {
+		p.next()
+		elseBlock = p.parseBlockStmt()
+	}
+	p.expectSemi()
+
+	return
+}
+
+type parseSpecFunc func(doc *ast.CommentGroup, keyword token.Token, i int) ast.Spec
 
 func (p *Parser) parseDecl() ast.Decl {
 	if p.trace {
@@ -51,6 +664,7 @@ func (p *Parser) parseGenDecl(keyword token.Token, f parseSpecFunc) *ast.GenDecl
 		defer un(trace(p, "GenDecl("+keyword.String()+")"))
 	}
 
+	doc := p.leadComment
 	pos := p.expect(keyword)
 	var lparen, rparen token.Pos
 	var list []ast.Spec
@@ -59,16 +673,17 @@ func (p *Parser) parseGenDecl(keyword token.Token, f parseSpecFunc) *ast.GenDecl
 		lparen = p.pos
 		p.next()
 		for i := 0; p.tok != token.RPAREN && p.tok != token.EOF; i++ {
-			list = append(list, f(keyword, i))
+			list = append(list, f(p.leadComment, keyword, i))
 		}
 
 		rparen = p.expect(token.RPAREN)
 		p.expectSemi()
 	} else {
-		list = append(list, f(keyword, 0))
+		list = append(list, f(nil, keyword, 0))
 	}
 
 	return &ast.GenDecl{
+		Doc:    doc,
 		TokPos: pos,
 		Tok:    keyword,
 		Lparen: lparen,
@@ -77,7 +692,7 @@ func (p *Parser) parseGenDecl(keyword token.Token, f parseSpecFunc) *ast.GenDecl
 	}
 }
 
-func (p *Parser) parseValueSpec(keyword token.Token, i int) ast.Spec {
+func (p *Parser) parseValueSpec(doc *ast.CommentGroup, keyword token.Token, i int) ast.Spec {
 	if p.trace {
 		defer un(trace(p, keyword.String()+"Spec"))
 	}
@@ -93,7 +708,7 @@ func (p *Parser) parseValueSpec(keyword token.Token, i int) ast.Spec {
 		p.next()
 		values = p.parseRhsList()
 	}
-	p.expectSemi()
+	p.expectSemi() // call before accessing p.lineComment
 
 	switch keyword {
 	case token.LET:
@@ -110,7 +725,26 @@ func (p *Parser) parseValueSpec(keyword token.Token, i int) ast.Spec {
 		}
 	}
 
-	if keyword != token.VAR && len(idents) != len(values) {
+	// a const spec without type and values in a group repeats
+	// the type and values of the last spec with values
+	inherited := false
+	if keyword == token.CONST {
+		if i == 0 || values != nil || typ != nil {
+			p.lastConst = nil
+		} else if p.lastConst != nil {
+			inherited = true
+		}
+	}
+
+	if inherited {
+		if n := len(p.lastConst.Values); len(idents) != n {
+			if len(idents) > n {
+				p.error(pos, "missing value in const declaration")
+			} else {
+				p.error(pos, "extra expression in const declaration")
+			}
+		}
+	} else if keyword != token.VAR && len(idents) != len(values) {
 		if len(idents) > len(values) {
 			p.error(pos, "missing value in "+keyword.String()+" declaration")
 		} else {
@@ -119,15 +753,68 @@ func (p *Parser) parseValueSpec(keyword token.Token, i int) ast.Spec {
 	}
 
 	spec := &ast.ValueSpec{
-		Names:  idents,
-		Type:   typ,
-		Values: values,
+		Doc:     doc,
+		Names:   idents,
+		Type:    typ,
+		Values:  values,
+		Comment: p.lineComment,
 	}
 	kind := ast.Con
-	if keyword == token.VAR {
+	switch keyword {
+	case token.LET:
+		kind = ast.Let
+	case token.VAR:
 		kind = ast.Var
 	}
 	p.declare(spec, i, p.topScope, kind, idents...)
+	if keyword == token.CONST {
+		if inherited {
+			p.foldConsts(idents, p.lastConst.Values, i, true)
+		} else {
+			p.foldConsts(idents, values, i, false)
+			if values != nil {
+				p.lastConst = spec
+			}
+		}
+	}
 
 	return spec
 }
+
+func (p *Parser) parseFuncDecl() *ast.FuncDecl {
+	if p.trace {
+		defer un(trace(p, "FunctionDecl"))
+	}
+
+	doc := p.leadComment
+	pos := p.expect(token.FN)
+	scope := ast.NewScope(p.topScope) // function scope
+
+	ident := p.parseIdent()
+	var params, results *ast.FieldList
+	if p.tok == token.LPAREN {
+		params, results = p.parseSignature(scope)
+	} else {
+		// only function literals may omit the parameter list
+		p.errorExpected(p.pos, "'('")
+		params = new(ast.FieldList)
+	}
+
+	decl := &ast.FuncDecl{
+		Doc:  doc,
+		Name: ident,
+		Type: &ast.FuncType{
+			Func:    pos,
+			Params:  params,
+			Results: results,
+		},
+	}
+	// declare the function before parsing the body
+	// so it can be called recursively
+	p.declare(decl, nil, p.topScope, ast.Fun, ident)
+
+	decl.Body = p.parseBody(scope)
+	p.expectSemi()
+
+	return decl
+}
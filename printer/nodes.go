@@ -0,0 +1,1020 @@
+// This file implements printing of AST nodes; specifically
+// expressions, statements, declarations, and files. It uses
+// the print functionality implemented in printer.go.
+
+package printer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/token"
+)
+
+// ----------------------------------------------------------------------------
+// Common AST nodes.
+
+// list prints the nodes of list separated by commas, using f to print
+// each node. Line breaks between the opening token at prev and the
+// list elements are preserved, and the elements following a line break
+// are indented. If the closing token at next follows the last element
+// on a later line, the list is terminated by a comma and a line break.
+// If next is invalid, no trailing comma is printed. list reports
+// whether it printed a trailing comma.
+func (p *printer) list(prev token.Pos, list []ast.Node, next token.Pos, f func(ast.Node)) bool {
+	line := p.lineFor(prev)
+	indented := false
+	for i, x := range list {
+		if i > 0 {
+			p.print(token.NoPos, ",")
+		}
+		if xline := p.lineFor(x.Pos()); line > 0 && xline > line {
+			if !indented {
+				p.indent++
+				indented = true
+			}
+			p.linebreak(token.NoPos, 1)
+		} else if i > 0 {
+			p.space()
+		}
+		f(x)
+		line = p.lineFor(x.End())
+	}
+
+	trailing := len(list) > 0 && line > 0 && p.lineFor(next) > line
+	if trailing {
+		p.print(token.NoPos, ",")
+		if indented {
+			// comments before the closing token
+			// belong to the list
+			p.flush(next)
+		}
+		p.linebreak(token.NoPos, 1)
+	}
+	if indented {
+		p.indent--
+	}
+	return trailing
+}
+
+func (p *printer) exprList(prev token.Pos, list []ast.Expr, depth int, next token.Pos) bool {
+	nodes := make([]ast.Node, len(list))
+	for i, x := range list {
+		nodes[i] = x
+	}
+	return p.list(prev, nodes, next, func(x ast.Node) { p.expr0(x.(ast.Expr), depth) })
+}
+
+func (p *printer) identList(list []*ast.Ident) {
+	for i, x := range list {
+		if i > 0 {
+			p.print(token.NoPos, ",")
+			p.space()
+		}
+		p.expr(x)
+	}
+}
+
+func (p *printer) field(f *ast.Field) {
+	if f.Ellipsis.IsValid() {
+		p.print(f.Ellipsis, "...")
+	}
+	p.identList(f.Names)
+	if f.Type != nil {
+		if len(f.Names) > 0 {
+			p.space()
+		}
+		p.expr(f.Type)
+	}
+	if f.Default != nil {
+		p.print(f.Assign, "=")
+		p.expr0(f.Default, 2)
+	}
+}
+
+func (p *printer) fieldList(opening token.Pos, fields []*ast.Field, closing token.Pos) {
+	nodes := make([]ast.Node, len(fields))
+	for i, f := range fields {
+		nodes[i] = f
+	}
+	p.list(opening, nodes, closing, func(f ast.Node) { p.field(f.(*ast.Field)) })
+}
+
+func (p *printer) parameters(fields *ast.FieldList) {
+	p.print(fields.Opening, "(")
+	p.fieldList(fields.Opening, fields.List, fields.Closing)
+	p.print(fields.Closing, ")")
+}
+
+func (p *printer) results(fields *ast.FieldList) {
+	if !fields.Opening.IsValid() && len(fields.List) == 1 && len(fields.List[0].Names) == 0 {
+		// single unnamed result
+		p.expr(fields.List[0].Type)
+		return
+	}
+	p.parameters(fields)
+}
+
+// signature prints the parameters and results of a function. The
+// parameters of a function literal or type without parameters and
+// results are only printed if they are present in the source.
+func (p *printer) signature(typ *ast.FuncType, isDecl bool) {
+	params := typ.Params
+	if isDecl || params.Opening.IsValid() || len(params.List) > 0 || typ.Results != nil {
+		p.parameters(params)
+	}
+	if typ.Results != nil {
+		p.space()
+		p.results(typ.Results)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+func walkBinary(e *ast.BinaryExpr) (has4, has5 bool, maxProblem int) {
+	switch e.Op.Precedence() {
+	case 4:
+		has4 = true
+	case 5:
+		has5 = true
+	}
+
+	switch l := e.Lhs.(type) {
+	case *ast.BinaryExpr:
+		if l.Op.Precedence() < e.Op.Precedence() {
+			// parens will be inserted.
+			// pretend this is an *ast.ParenExpr and do nothing.
+			break
+		}
+		h4, h5, mp := walkBinary(l)
+		has4 = has4 || h4
+		has5 = has5 || h5
+		if maxProblem < mp {
+			maxProblem = mp
+		}
+	}
+
+	switch r := e.Rhs.(type) {
+	case *ast.BinaryExpr:
+		if r.Op.Precedence() <= e.Op.Precedence() {
+			// parens will be inserted.
+			// pretend this is an *ast.ParenExpr and do nothing.
+			break
+		}
+		h4, h5, mp := walkBinary(r)
+		has4 = has4 || h4
+		has5 = has5 || h5
+		if maxProblem < mp {
+			maxProblem = mp
+		}
+
+	case *ast.UnaryExpr:
+		switch e.Op.String() + r.Op.String() {
+		case "++", "--":
+			if maxProblem < 4 {
+				maxProblem = 4
+			}
+		}
+	}
+	return
+}
+
+func cutoff(e *ast.BinaryExpr, depth int) int {
+	has4, has5, maxProblem := walkBinary(e)
+	if maxProblem > 0 {
+		return maxProblem + 1
+	}
+	if has4 && has5 {
+		if depth == 1 {
+			return 5
+		}
+		return 4
+	}
+	if depth == 1 {
+		return token.UnaryPrec
+	}
+	return 4
+}
+
+func diffPrec(expr ast.Expr, prec int) int {
+	x, ok := expr.(*ast.BinaryExpr)
+	if !ok || prec != x.Op.Precedence() {
+		return 1
+	}
+	return 0
+}
+
+func reduceDepth(depth int) int {
+	depth--
+	if depth < 1 {
+		depth = 1
+	}
+	return depth
+}
+
+// Format the binary expression: decide the cutoff and then format.
+// Let's call depth == 1 Normal mode, and depth > 1 Compact mode.
+// (Algorithm suggestion by Russ Cox.)
+//
+// The precedences are:
+//
+//	6             **
+//	5             *  /  %  <<  >>  &  &^
+//	4             +  -  |  ^
+//	3             ==  !=  <  <=  >  >=  in  not in
+//	2             and
+//	1             or
+//
+// The only decision is whether there will be spaces around levels 4
+// to 6. There are never spaces at level 7 (unary), and always spaces
+// at levels 3 and below, which is also what keeps the word operators
+// apart from their operands.
+//
+// To choose the cutoff, look at the whole expression but excluding
+// primary expressions (function calls, parenthesized exprs), and
+// apply these rules:
+//
+//  1. If there is a binary operator with a right side unary operand
+//     that would clash without a space, the cutoff must be (in order):
+//
+//     ++	5
+//     --	5
+//
+//     (Comparison operators always have spaces around them.)
+//
+//  2. If there is a mix of level 5 and level 4 operators, then the
+//     cutoff is 5 (use spaces to distinguish precedence) in Normal
+//     mode and 4 (never use spaces) in Compact mode.
+//
+//  3. If there are no level 4 operators or no level 5 operators, then
+//     the cutoff is 7 (always use spaces) in Normal mode and 4 (never
+//     use spaces) in Compact mode.
+func (p *printer) binaryExpr(x *ast.BinaryExpr, prec1, cutoff, depth int) {
+	prec := x.Op.Precedence()
+	if prec < prec1 {
+		// parenthesis needed
+		p.print(token.NoPos, "(")
+		p.expr0(x, reduceDepth(depth)) // parentheses undo one level of depth
+		p.print(token.NoPos, ")")
+		return
+	}
+
+	printBlank := prec < cutoff
+	p.expr1(x.Lhs, prec, depth+diffPrec(x.Lhs, prec))
+	if printBlank {
+		p.space()
+	}
+	p.print(x.OpPos, x.Op.String())
+	if line := p.lineFor(x.OpPos); line > 0 && p.lineFor(x.Rhs.Pos()) > line {
+		// keep the line break following the operator
+		p.indent++
+		p.linebreak(token.NoPos, 1)
+		p.expr1(x.Rhs, prec+1, depth+1)
+		p.indent--
+		return
+	}
+	if printBlank {
+		p.space()
+	}
+	p.expr1(x.Rhs, prec+1, depth+1)
+}
+
+func isBinary(expr ast.Expr) bool {
+	_, ok := expr.(*ast.BinaryExpr)
+	return ok
+}
+
+func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
+	switch x := expr.(type) {
+	case *ast.BadExpr:
+		p.print(x.Pos(), "BadExpr")
+
+	case *ast.Ident:
+		p.print(x.Pos(), x.Name)
+
+	case *ast.BinaryExpr:
+		p.binaryExpr(x, prec1, cutoff(x, depth), depth)
+
+	case *ast.KeywordArg:
+		p.expr(x.Name)
+		p.print(x.Assign, "=")
+		p.expr0(x.Value, depth+1)
+
+	case *ast.KeyValueExpr:
+		p.expr(x.Key)
+		p.print(x.Colon, ":")
+		p.space()
+		p.expr(x.Value)
+
+	case *ast.UnaryExpr:
+		const prec = token.UnaryPrec
+		if prec < prec1 {
+			// parenthesis needed
+			p.print(token.NoPos, "(")
+			p.expr(x)
+			p.print(token.NoPos, ")")
+			break
+		}
+		// no parenthesis needed
+		p.print(x.OpPos, x.Op.String())
+		if inner, isUnary := x.Expr.(*ast.UnaryExpr); x.Op == token.NOT ||
+			isUnary && inner.Op == x.Op && (x.Op == token.ADD || x.Op == token.SUB) {
+			// "not" is a keyword; "- -x" must not become "--x"
+			p.space()
+		}
+		p.expr1(x.Expr, prec, depth)
+
+	case *ast.BasicLit:
+		p.print(x.Pos(), x.Value)
+
+	case *ast.FuncLit:
+		p.print(x.Type.Pos(), token.FN.String())
+		p.signature(x.Type, false)
+		p.space()
+		p.funcBody(x.Body)
+
+	case *ast.InterpolatedString:
+		for _, part := range x.Parts {
+			p.expr(part)
+		}
+
+	case *ast.ParenExpr:
+		p.print(x.Lparen, "(")
+		if _, hasParens := x.Expr.(*ast.ParenExpr); hasParens {
+			p.expr0(x.Expr, depth)
+		} else {
+			p.expr0(x.Expr, reduceDepth(depth)) // parentheses undo one level of depth
+		}
+		p.print(x.Rparen, ")")
+
+	case *ast.SelectorExpr:
+		p.expr1(x.X, token.HighestPrec, depth)
+		p.print(token.NoPos, ".")
+		p.expr(x.Sel)
+
+	case *ast.TypeAssertExpr:
+		p.expr1(x.X, token.HighestPrec, depth)
+		p.space()
+		p.print(x.As, token.AS.String())
+		p.space()
+		p.expr(x.Type)
+
+	case *ast.IndexExpr:
+		p.expr1(x.X, token.HighestPrec, 1)
+		p.print(x.Lbrack, "[")
+		p.expr0(x.Index, depth+1)
+		p.print(x.Rbrack, "]")
+
+	case *ast.SliceExpr:
+		p.expr1(x.X, token.HighestPrec, 1)
+		p.print(x.Lbrack, "[")
+		indices := []ast.Expr{x.Low, x.High}
+		if x.Max != nil || x.Slice3 {
+			indices = append(indices, x.Max)
+		}
+		// determine if we need extra blanks around ':'
+		var needsBlanks bool
+		if depth <= 1 {
+			var indexCount int
+			var hasBinaries bool
+			for _, y := range indices {
+				if y != nil {
+					indexCount++
+					if isBinary(y) {
+						hasBinaries = true
+					}
+				}
+			}
+			if indexCount > 1 && hasBinaries {
+				needsBlanks = true
+			}
+		}
+		for i, y := range indices {
+			if i > 0 {
+				if indices[i-1] != nil && needsBlanks {
+					p.space()
+				}
+				p.print(token.NoPos, ":")
+				if y != nil && needsBlanks {
+					p.space()
+				}
+			}
+			if y != nil {
+				p.expr0(y, depth+1)
+			}
+		}
+		p.print(x.Rbrack, "]")
+
+	case *ast.CallExpr:
+		if len(x.Args) > 1 {
+			depth++
+		}
+		p.expr1(x.Fun, token.HighestPrec, depth)
+		p.print(x.Lparen, "(")
+		args := make([]ast.Node, len(x.Args))
+		for i, arg := range x.Args {
+			args[i] = arg
+		}
+		p.list(x.Lparen, args, x.Rparen, func(arg ast.Node) {
+			p.expr0(arg.(ast.Expr), depth)
+			if x.Ellipsis.IsValid() && arg == args[len(args)-1] {
+				p.print(x.Ellipsis, "...")
+			}
+		})
+		p.print(x.Rparen, ")")
+
+	case *ast.ListLit:
+		p.print(x.Lbrack, "[")
+		p.exprList(x.Lbrack, x.Elts, 1, x.Rbrack)
+		p.print(x.Rbrack, "]")
+
+	case *ast.SetLit:
+		p.print(x.Lbrace, "{")
+		p.exprList(x.Lbrace, x.Elts, 1, x.Rbrace)
+		p.print(x.Rbrace, "}")
+
+	case *ast.MapLit:
+		p.print(x.Lbrace, "{")
+		if len(x.Elts) == 0 {
+			p.print(token.NoPos, ":")
+		}
+		elts := make([]ast.Expr, len(x.Elts))
+		for i, kv := range x.Elts {
+			elts[i] = kv
+		}
+		p.exprList(x.Lbrace, elts, 1, x.Rbrace)
+		p.print(x.Rbrace, "}")
+
+	case *ast.TupleLit:
+		p.print(x.Lparen, "(")
+		if !p.exprList(x.Lparen, x.Elts, 1, x.Rparen) && len(x.Elts) == 1 {
+			// a tuple of one element needs a trailing comma
+			p.print(token.NoPos, ",")
+		}
+		p.print(x.Rparen, ")")
+
+	case *ast.FuncType:
+		p.print(x.Pos(), token.FN.String())
+		p.signature(x, false)
+
+	case *ast.ListType:
+		p.print(x.List, "list")
+		p.print(x.Lbrack, "[")
+		p.expr(x.Elt)
+		p.print(x.Rbrack, "]")
+
+	case *ast.SetType:
+		p.print(x.Set, "set")
+		p.print(x.Lbrack, "[")
+		p.expr(x.Elt)
+		p.print(x.Rbrack, "]")
+
+	case *ast.MapType:
+		p.print(x.Map, "map")
+		p.print(x.Lbrack, "[")
+		p.expr(x.Key)
+		p.print(x.Rbrack, "]")
+		p.expr(x.Value)
+
+	case *ast.TupleType:
+		p.print(x.Tuple, "tuple")
+		p.print(x.Lbrack, "[")
+		p.exprList(x.Lbrack, x.Elts, 1, x.Rbrack)
+		p.print(x.Rbrack, "]")
+
+	default:
+		panic("unreachable")
+	}
+}
+
+func (p *printer) expr0(x ast.Expr, depth int) {
+	p.expr1(x, token.LowestPrec, depth)
+}
+
+func (p *printer) expr(x ast.Expr) {
+	const depth = 1
+	p.expr1(x, token.LowestPrec, depth)
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+// stmtList prints the statements of list on lines of their own. A blank
+// line between two statements in the source is preserved; in a block,
+// blank lines before the first statement are not.
+func (p *printer) stmtList(list []ast.Stmt, inBlock bool) {
+	i := 0
+	for _, s := range list {
+		if _, isEmpty := s.(*ast.EmptyStmt); isEmpty {
+			continue
+		}
+		pos := s.Pos()
+		if i == 0 && inBlock {
+			pos = token.NoPos
+		}
+		p.linebreak(pos, 1)
+		p.stmt(s)
+		i++
+	}
+}
+
+// isEmpty reports whether list contains only empty statements.
+func isEmpty(list []ast.Stmt) bool {
+	for _, s := range list {
+		if _, isEmpty := s.(*ast.EmptyStmt); !isEmpty {
+			return false
+		}
+	}
+	return true
+}
+
+// block prints the block b; an empty block is printed as {}.
+func (p *printer) block(b *ast.BlockStmt) {
+	p.print(b.Lbrace, "{")
+	if isEmpty(b.List) && !p.commentBefore(b.Rbrace) {
+		p.print(b.Rbrace, "}")
+		return
+	}
+	p.indent++
+	p.stmtList(b.List, true)
+	p.flush(b.Rbrace)
+	p.indent--
+	p.linebreak(token.NoPos, 1)
+	p.print(b.Rbrace, "}")
+}
+
+// switchBody prints the body of a switch statement, with the
+// case clauses indented like the switch statement.
+func (p *printer) switchBody(b *ast.BlockStmt) {
+	p.print(b.Lbrace, "{")
+	if len(b.List) == 0 && !p.commentBefore(b.Rbrace) {
+		p.print(b.Rbrace, "}")
+		return
+	}
+	for i, s := range b.List {
+		pos := s.Pos()
+		if i == 0 {
+			pos = token.NoPos
+		}
+		p.linebreak(pos, 1)
+		p.stmt(s)
+
+		// comments indented more than the following case
+		// label belong to the body of this case clause
+		next := b.Rbrace
+		if i+1 < len(b.List) {
+			next = b.List[i+1].Pos()
+		}
+		p.indent++
+		for p.commentBefore(next) && p.columnFor(p.comments[p.cindex].Pos()) > p.columnFor(next) {
+			p.writeComment(p.comments[p.cindex])
+			p.cindex++
+		}
+		p.indent--
+	}
+	p.flush(b.Rbrace)
+	p.linebreak(token.NoPos, 1)
+	p.print(b.Rbrace, "}")
+}
+
+// funcBody prints the body of a function. A body that is written on
+// one line in the source is kept on one line if it contains only
+// simple statements and no comments.
+func (p *printer) funcBody(b *ast.BlockStmt) {
+	line := p.lineFor(b.Lbrace)
+	if line == 0 || line != p.lineFor(b.Rbrace) || p.commentBefore(b.Rbrace) {
+		p.block(b)
+		return
+	}
+	for _, s := range b.List {
+		switch s.(type) {
+		case *ast.BlockStmt, *ast.IfStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.ForStmt, *ast.ForInStmt, *ast.DeclStmt:
+			p.block(b)
+			return
+		}
+	}
+
+	p.print(b.Lbrace, "{")
+	i := 0
+	for _, s := range b.List {
+		if _, isEmpty := s.(*ast.EmptyStmt); isEmpty {
+			continue
+		}
+		if i > 0 {
+			p.print(token.NoPos, ";")
+		}
+		p.space()
+		p.stmt(s)
+		i++
+	}
+	if i > 0 {
+		p.space()
+	}
+	p.print(b.Rbrace, "}")
+}
+
+// controlClause prints the initialization statement, if any, and
+// the expression of an if or switch statement.
+func (p *printer) controlClause(init ast.Stmt, x ast.Expr) {
+	if init != nil {
+		p.space()
+		p.stmt(init)
+		p.print(token.NoPos, ";")
+	}
+	if x != nil {
+		p.space()
+		p.expr(x)
+	}
+}
+
+func (p *printer) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.BadStmt:
+		p.print(s.Pos(), "BadStmt")
+
+	case *ast.DeclStmt:
+		p.decl(s.Decl)
+
+	case *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.ExprStmt:
+		p.expr(s.Expr)
+
+	case *ast.IncDecStmt:
+		p.expr(s.Expr)
+		p.print(s.TokPos, s.Tok.String())
+
+	case *ast.AssignStmt:
+		p.exprList(token.NoPos, s.Lhs, 1, token.NoPos)
+		p.space()
+		p.print(s.TokPos, s.Tok.String())
+		p.space()
+		if len(s.Rhs) == 1 {
+			if tuple, isTuple := s.Rhs[0].(*ast.TupleLit); isTuple && !tuple.Lparen.IsValid() && len(tuple.Elts) > 1 {
+				// tuple assigned without parentheses
+				p.exprList(token.NoPos, tuple.Elts, 1, token.NoPos)
+				break
+			}
+		}
+		p.exprList(token.NoPos, s.Rhs, 1, token.NoPos)
+
+	case *ast.ReturnStmt:
+		p.print(s.Return, token.RETURN.String())
+		if len(s.Results) > 0 {
+			p.space()
+			p.exprList(token.NoPos, s.Results, 1, token.NoPos)
+		}
+
+	case *ast.BranchStmt:
+		p.print(s.TokPos, s.Tok.String())
+
+	case *ast.BlockStmt:
+		p.block(s)
+
+	case *ast.IfStmt:
+		p.print(s.If, token.IF.String())
+		p.controlClause(s.Init, s.Cond)
+		p.space()
+		p.block(s.Body)
+		if s.Else != nil {
+			p.space()
+			p.print(token.NoPos, token.ELSE.String())
+			p.space()
+			p.stmt(s.Else)
+		}
+
+	case *ast.CaseClause:
+		if s.List != nil {
+			p.print(s.Case, token.CASE.String())
+			p.space()
+			p.exprList(token.NoPos, s.List, 1, token.NoPos)
+		} else {
+			p.print(s.Case, token.DEFAULT.String())
+		}
+		p.print(s.Colon, ":")
+		p.indent++
+		p.stmtList(s.Body, true)
+		p.indent--
+
+	case *ast.SwitchStmt:
+		p.print(s.Switch, token.SWITCH.String())
+		p.controlClause(s.Init, s.Tag)
+		p.space()
+		p.switchBody(s.Body)
+
+	case *ast.TypeSwitchStmt:
+		p.print(s.Switch, token.SWITCH.String())
+		p.controlClause(s.Init, nil)
+		p.space()
+		if s.Lhs != nil {
+			p.expr(s.Lhs)
+			p.space()
+			p.print(token.NoPos, "=")
+			p.space()
+		}
+		p.print(s.TypeOf, token.TYPEOF.String())
+		p.space()
+		p.expr(s.X)
+		p.space()
+		p.switchBody(s.Body)
+
+	case *ast.ForStmt:
+		p.print(s.For, token.FOR.String())
+		if s.Init != nil || s.Post != nil {
+			p.space()
+			if s.Init != nil {
+				p.stmt(s.Init)
+			}
+			p.print(token.NoPos, ";")
+			p.space()
+			if s.Cond != nil {
+				p.expr(s.Cond)
+			}
+			p.print(token.NoPos, ";")
+			if s.Post != nil {
+				p.space()
+				p.stmt(s.Post)
+			}
+		} else if s.Cond != nil {
+			p.space()
+			p.expr(s.Cond)
+		}
+		p.space()
+		p.loopBody(s.Body, s.Else)
+
+	case *ast.ForInStmt:
+		p.print(s.For, token.FOR.String())
+		p.space()
+		p.identList(s.Vars)
+		p.space()
+		p.print(s.In, token.IN.String())
+		p.space()
+		p.expr(s.X)
+		if s.Filter != nil {
+			p.space()
+			p.print(s.If, token.IF.String())
+			p.space()
+			p.expr(s.Filter)
+		}
+		p.space()
+		p.loopBody(s.Body, s.Else)
+
+	default:
+		panic("unreachable")
+	}
+}
+
+func (p *printer) loopBody(body, elseBlock *ast.BlockStmt) {
+	p.block(body)
+	if elseBlock != nil {
+		p.space()
+		p.print(token.NoPos, token.ELSE.String())
+		p.space()
+		p.block(elseBlock)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+// The specs of a declaration are aligned in columns: the names, the
+// types, the values and the line comments. Each spec is split into
+// cells, one per column, that are padded to the widest cell of the
+// column in the surrounding block of specs. A block is a run of specs
+// on consecutive lines that have a cell in the column, so a blank
+// line, a comment on a line of its own or a spec spanning several
+// lines ends the alignment. A column of empty cells is discarded.
+
+// keepTypeColumn reports for each spec of specs whether its type column
+// must be kept even if it has no type: that is the case for a run of
+// specs with values in which some spec has a type, so that the values
+// of the run are aligned.
+func keepTypeColumn(specs []ast.Spec) []bool {
+	m := make([]bool, len(specs))
+
+	populate := func(i, j int, keepType bool) {
+		if keepType {
+			for ; i < j; i++ {
+				m[i] = true
+			}
+		}
+	}
+
+	i0 := -1 // if i0 >= 0 we are in a run and i0 is the start of the run
+	var keepType bool
+	for i, s := range specs {
+		t := s.(*ast.ValueSpec)
+		if t.Values != nil {
+			if i0 < 0 {
+				// start of a run of ValueSpecs with non-nil Values
+				i0 = i
+				keepType = false
+			}
+		} else {
+			if i0 >= 0 {
+				// end of a run
+				populate(i0, i, keepType)
+				i0 = -1
+			}
+		}
+		if t.Type != nil {
+			keepType = true
+		}
+	}
+	if i0 >= 0 {
+		// end of a run
+		populate(i0, len(specs), keepType)
+	}
+
+	return m
+}
+
+// cells returns the widths of the cells of s when its type column is
+// kept or not. The values of s only end a cell if they are followed by
+// a line comment, which is then placed in the fourth column.
+func (p *printer) cells(s *ast.ValueSpec, keepType bool) []int {
+	// measure the text as it is printed, without comments
+	m := &printer{fset: p.fset}
+	width := func(f func()) int {
+		m.output = m.output[:0]
+		m.line = 0
+		f()
+		text := string(m.output)
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+		return utf8.RuneCountInString(text)
+	}
+
+	cells := []int{width(func() { m.identList(s.Names) })}
+	if s.Type != nil || keepType {
+		typ := 0
+		if s.Type != nil {
+			typ = width(func() { m.expr(s.Type) })
+		}
+		cells = append(cells, typ)
+	}
+	if s.Comment != nil && p.lineFor(s.Pos()) == p.lineFor(s.End()) {
+		if s.Values != nil {
+			cells = append(cells, width(func() {
+				m.print(token.NoPos, "=")
+				m.space()
+				m.exprList(token.NoPos, s.Values, 1, token.NoPos)
+			}))
+		}
+		for len(cells) < 3 {
+			cells = append(cells, 0)
+		}
+	}
+	return cells
+}
+
+// alignment returns the number of blanks that follow each cell of the
+// specs in specs.
+func (p *printer) alignment(specs []ast.Spec) [][]int {
+	keepType := keepTypeColumn(specs)
+	cells := make([][]int, len(specs))
+	pads := make([][]int, len(specs))
+	for i, s := range specs {
+		cells[i] = p.cells(s.(*ast.ValueSpec), keepType[i])
+		pads[i] = make([]int, len(cells[i]))
+	}
+
+	// adjacent reports whether spec i is on the line after spec i-1
+	adjacent := func(i int) bool {
+		prev := p.lineFor(specs[i-1].End())
+		return prev > 0 && p.lineFor(specs[i-1].Pos()) == prev && p.lineFor(specs[i].Pos()) == prev+1
+	}
+
+	for col, more := 0, true; more; col++ {
+		more = false
+		for i := 0; i < len(specs); {
+			if len(cells[i]) <= col {
+				i++
+				continue
+			}
+			more = true
+
+			// find the block of cells in col and its width
+			width := cells[i][col]
+			j := i + 1
+			for ; j < len(specs) && len(cells[j]) > col && adjacent(j); j++ {
+				if cells[j][col] > width {
+					width = cells[j][col]
+				}
+			}
+			if width > 0 {
+				for ; i < j; i++ {
+					pads[i][col] = width - cells[i][col] + 1
+				}
+			}
+			i = j
+		}
+	}
+	return pads
+}
+
+// valueSpec prints s, followed by the blanks in pads after each of its
+// cells.
+func (p *printer) valueSpec(s *ast.ValueSpec, keepType bool, pads []int) {
+	p.leadComment(s.Doc)
+	p.identList(s.Names)
+	cell := 0
+	if s.Type != nil || keepType {
+		p.pad(pads[cell])
+		cell++
+		if s.Type != nil {
+			p.expr(s.Type)
+		}
+	}
+	if s.Values != nil {
+		p.pad(pads[cell])
+		cell++
+		p.print(token.NoPos, "=")
+		p.space()
+		p.exprList(token.NoPos, s.Values, 1, token.NoPos)
+	}
+	for ; cell < len(pads); cell++ {
+		// align the line comment
+		p.pad(pads[cell])
+	}
+	p.lineComment(s.Comment)
+}
+
+func (p *printer) spec(spec ast.Spec) {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		specs := []ast.Spec{s}
+		p.valueSpec(s, keepTypeColumn(specs)[0], p.alignment(specs)[0])
+
+	default:
+		panic("unreachable")
+	}
+}
+
+func (p *printer) genDecl(d *ast.GenDecl) {
+	p.leadComment(d.Doc)
+	p.print(d.TokPos, d.Tok.String())
+	p.space()
+
+	if !d.Lparen.IsValid() && len(d.Specs) == 1 {
+		p.spec(d.Specs[0])
+		return
+	}
+
+	p.print(d.Lparen, "(")
+	if len(d.Specs) > 0 {
+		keepType := keepTypeColumn(d.Specs)
+		pads := p.alignment(d.Specs)
+		p.indent++
+		for i, s := range d.Specs {
+			pos := s.Pos()
+			if i == 0 {
+				pos = token.NoPos
+			}
+			p.linebreak(pos, 1)
+			p.valueSpec(s.(*ast.ValueSpec), keepType[i], pads[i])
+		}
+		p.flush(d.Rparen)
+		p.indent--
+		p.linebreak(token.NoPos, 1)
+	}
+	p.print(d.Rparen, ")")
+}
+
+func (p *printer) funcDecl(d *ast.FuncDecl) {
+	p.leadComment(d.Doc)
+	p.print(d.Type.Pos(), token.FN.String())
+	p.space()
+	p.expr(d.Name)
+	p.signature(d.Type, true)
+	p.space()
+	p.funcBody(d.Body)
+}
+
+func (p *printer) decl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.BadDecl:
+		p.print(d.Pos(), "BadDecl")
+	case *ast.GenDecl:
+		p.genDecl(d)
+	case *ast.FuncDecl:
+		p.funcDecl(d)
+	default:
+		panic("unreachable")
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Files
+
+func (p *printer) file(src *ast.File) {
+	p.stmtList(src.Stmts, false)
+	p.flush(infinity)
+	if len(p.output) > 0 {
+		p.output = append(p.output, '\n')
+	}
+}
@@ -0,0 +1,301 @@
+// Package printer implements printing of AST nodes as Rose source code.
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/token"
+)
+
+const infinity = 1 << 30
+
+// pending whitespace
+type whitespace struct {
+	newlines int       // minimum number of line breaks
+	pos      token.Pos // position of the following text, used to preserve a blank line; or NoPos
+	blanks   int       // number of blanks, unless there are line breaks
+}
+
+type printer struct {
+	// Configuration (does not change after initialization)
+	Config
+	fset *token.FileSet
+
+	// Current state
+	output    []byte     // raw printer result
+	indent    int        // current indentation
+	ws        whitespace // whitespace to write before the next text
+	line      int        // source line of the last text written; or 0
+	needBreak bool       // if set, a //-style comment was written and the line must end
+
+	// Comments
+	comments        []*ast.Comment // may be nil
+	cindex          int            // index of the next comment to write
+	useNodeComments bool           // if set, print the comments attached to nodes
+}
+
+func (p *printer) init(cfg *Config, fset *token.FileSet, node ast.Node) {
+	p.Config = *cfg
+	p.fset = fset
+	if f, isFile := node.(*ast.File); isFile {
+		for _, g := range f.Comments {
+			p.comments = append(p.comments, g.List...)
+		}
+	}
+	p.useNodeComments = p.comments == nil
+}
+
+// lineFor returns the source line of pos, or 0 if it is unknown.
+func (p *printer) lineFor(pos token.Pos) int {
+	if p.fset == nil || !pos.IsValid() {
+		return 0
+	}
+	return p.fset.Position(pos).Line
+}
+
+// columnFor returns the source column of pos, or 0 if it is unknown.
+func (p *printer) columnFor(pos token.Pos) int {
+	if p.fset == nil || !pos.IsValid() {
+		return 0
+	}
+	return p.fset.Position(pos).Column
+}
+
+// ----------------------------------------------------------------------------
+// Whitespace
+
+// space schedules a blank before the next text.
+func (p *printer) space() {
+	if p.ws.blanks == 0 {
+		p.ws.blanks = 1
+	}
+}
+
+// pad schedules n more blanks before the next text, aligning it
+// with the text in the lines around it.
+func (p *printer) pad(n int) {
+	p.ws.blanks += n
+}
+
+// linebreak schedules at least min line breaks before the next text.
+// If the next text starts at the source position pos, a blank line
+// separating it from the previous text in the source is preserved.
+func (p *printer) linebreak(pos token.Pos, min int) {
+	if min > p.ws.newlines {
+		p.ws.newlines = min
+	}
+	p.ws.pos = pos
+}
+
+func (p *printer) writeIndent() {
+	n := p.Indent + p.indent
+	if p.Mode&UseSpaces != 0 {
+		p.output = append(p.output, strings.Repeat(" ", n*p.Tabwidth)...)
+	} else {
+		p.output = append(p.output, strings.Repeat("\t", n)...)
+	}
+}
+
+// writeNewlines writes n line breaks followed by the indentation,
+// or only the indentation at the start of the output.
+func (p *printer) writeNewlines(n int) {
+	if len(p.output) > 0 {
+		p.output = append(p.output, strings.Repeat("\n", n)...)
+	}
+	p.writeIndent()
+}
+
+// writeWhitespace writes the pending whitespace.
+func (p *printer) writeWhitespace() {
+	n := p.ws.newlines
+	if p.needBreak && n == 0 {
+		n = 1
+	}
+	if p.line > 0 {
+		d := p.lineFor(p.ws.pos) - p.line
+		if n == 0 && d > 0 {
+			// the line breaks were taken by a comment
+			// but the text starts on a later line
+			n = 1
+		}
+		if n > 0 && d > n {
+			n = 2 // at most one blank line
+		}
+	}
+
+	switch {
+	case n > 0 || len(p.output) == 0:
+		p.writeNewlines(n)
+	case p.ws.blanks > 0:
+		p.output = append(p.output, strings.Repeat(" ", p.ws.blanks)...)
+	}
+	p.ws = whitespace{}
+	p.needBreak = false
+}
+
+// ----------------------------------------------------------------------------
+// Text and comments
+
+// print writes the text s starting at the source position pos, which
+// may be invalid. Comments preceding pos and pending whitespace are
+// written first.
+func (p *printer) print(pos token.Pos, s string) {
+	p.flush(pos)
+	p.writeWhitespace()
+	p.output = append(p.output, s...)
+	if line := p.lineFor(pos); line > 0 {
+		p.line = line + strings.Count(s, "\n")
+	}
+}
+
+// commentBefore reports whether there is a comment to write before
+// the source position pos.
+func (p *printer) commentBefore(pos token.Pos) bool {
+	return p.cindex < len(p.comments) && pos.IsValid() && p.comments[p.cindex].Pos() < pos
+}
+
+// flush writes all comments preceding the source position pos.
+func (p *printer) flush(pos token.Pos) {
+	for p.commentBefore(pos) {
+		p.writeComment(p.comments[p.cindex])
+		p.cindex++
+	}
+}
+
+// writeComment writes the comment c. A comment on the same source line
+// as the previous text follows that text, separated by the pending
+// blanks; any other comment is written on a line of its own, taking
+// the place of any pending line breaks. The remaining pending
+// whitespace is kept for the text following the comment.
+func (p *printer) writeComment(c *ast.Comment) {
+	line := p.lineFor(c.Pos())
+	if p.line > 0 && line == p.line {
+		n := p.ws.blanks
+		if l := len(p.output); n == 0 && l > 0 && !strings.ContainsRune("([{", rune(p.output[l-1])) {
+			n = 1
+		}
+		p.output = append(p.output, strings.Repeat(" ", n)...)
+		p.ws.blanks = 0
+	} else {
+		n := 1
+		if p.line > 0 && line-p.line > 1 {
+			n = 2 // at most one blank line
+		}
+		p.writeNewlines(n)
+		p.ws.newlines = 0
+		p.needBreak = false
+	}
+	p.output = append(p.output, c.Text...)
+
+	if line > 0 {
+		p.line = p.lineFor(c.End())
+	}
+	if c.Text[1] == '/' {
+		p.needBreak = true
+	} else {
+		p.space()
+	}
+}
+
+// leadComment writes the documentation comment g of a node that is
+// about to be printed if the comments attached to nodes are printed.
+func (p *printer) leadComment(g *ast.CommentGroup) {
+	if g == nil || !p.useNodeComments {
+		return
+	}
+	for _, c := range g.List {
+		p.print(c.Pos(), c.Text)
+		p.linebreak(token.NoPos, 1)
+	}
+}
+
+// lineComment writes the line comment g of the node that was printed
+// last if the comments attached to nodes are printed.
+func (p *printer) lineComment(g *ast.CommentGroup) {
+	if g == nil || !p.useNodeComments {
+		return
+	}
+	for _, c := range g.List {
+		p.space()
+		p.print(c.Pos(), c.Text)
+		if c.Text[1] == '/' {
+			p.needBreak = true
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Public interface
+
+// A Mode value is a set of flags (or 0). They control printing.
+type Mode uint
+
+const (
+	UseSpaces Mode = 1 << iota // indent with spaces instead of tabs
+)
+
+// A Config node controls the output of Fprint.
+type Config struct {
+	Mode     Mode // default: 0
+	Tabwidth int  // number of spaces per indentation level if UseSpaces is set
+	Indent   int  // default: 0 (all code is indented at least by this much)
+}
+
+// Fprint "pretty-prints" an AST node to output for a given configuration
+// cfg. Position information is interpreted relative to the file set
+// fset, which may be nil. The node type must be *ast.File, a node
+// assignment-compatible to ast.Expr, ast.Stmt, ast.Decl or ast.Spec, or
+// one of *ast.Field, *ast.FieldList, *ast.Comment and *ast.CommentGroup.
+//
+// If node is an *ast.File, the comments of the file are printed at
+// their positions in the source; otherwise, only the comments attached
+// to the printed nodes are.
+func (cfg *Config) Fprint(output io.Writer, fset *token.FileSet, node ast.Node) error {
+	var p printer
+	p.init(cfg, fset, node)
+	if err := p.printNode(node); err != nil {
+		return err
+	}
+	_, err := output.Write(p.output)
+	return err
+}
+
+// Fprint "pretty-prints" an AST node to output, indenting with tabs.
+// It calls Config.Fprint with default settings.
+func Fprint(output io.Writer, fset *token.FileSet, node ast.Node) error {
+	return (&Config{Tabwidth: 8}).Fprint(output, fset, node)
+}
+
+func (p *printer) printNode(node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.File:
+		p.file(n)
+	case ast.Expr:
+		p.expr(n)
+	case ast.Stmt:
+		p.stmt(n)
+	case ast.Decl:
+		p.decl(n)
+	case ast.Spec:
+		p.spec(n)
+	case *ast.Field:
+		p.field(n)
+	case *ast.FieldList:
+		p.parameters(n)
+	case *ast.Comment:
+		p.print(n.Pos(), n.Text)
+	case *ast.CommentGroup:
+		for i, c := range n.List {
+			if i > 0 {
+				p.linebreak(c.Pos(), 1)
+			}
+			p.print(c.Pos(), c.Text)
+		}
+	default:
+		return fmt.Errorf("rose/printer: unsupported node type %T", node)
+	}
+	return nil
+}
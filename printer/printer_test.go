@@ -0,0 +1,244 @@
+package printer_test
+
+import (
+	"bytes"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/printer"
+	"github.com/capnspacehook/rose/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func format(t *testing.T, src string) string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	require.NoError(t, err, src)
+
+	var buf bytes.Buffer
+	require.NoError(t, printer.Fprint(&buf, fset, f))
+	return buf.String()
+}
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"x=1", "x = 1\n"},
+		{"a ,b = b,a", "a, b = b, a\n"},
+		{"x = 1, 2\ny = (1,)\nz = ( 1 , 2 )", "x = 1, 2\ny = (1,)\nz = (1, 2)\n"},
+		{"x  +=  -  -  ~3", "x += - -~3\n"},
+		{"if not(a and b)or c {}", "if not (a and b) or c {}\n"},
+		{"x = a not in b", "x = a not in b\n"},
+		{"[1,2,3,]\n{:}\n{}\n{1,2}\n{'a':1}", "[1, 2, 3]\n{:}\n{}\n{1, 2}\n{'a': 1}\n"},
+		{"xs[1 : 2]\nxs[:2]\nxs[1:]\nxs[ : ]\nxs[1:2:3]", "xs[1:2]\nxs[:2]\nxs[1:]\nxs[:]\nxs[1:2:3]\n"},
+		{"f( a , c... )\ng(a , b=2)", "f(a, c...)\ng(a, b=2)\n"},
+		{"x as map[string]list[int]", "x as map[string]list[int]\n"},
+		{"var t tuple[int,set[float]]", "var t tuple[int, set[float]]\n"},
+		{`"a {b} {{c}} {d["e"]}"`, `"a {b} {{c}} {d["e"]}"` + "\n"},
+
+		// spacing by precedence
+		{"x = a * b+c", "x = a*b + c\n"},
+		{"x = a+b+c\ny = a  *  b\nz = 2**3", "x = a + b + c\ny = a * b\nz = 2 ** 3\n"},
+		{"f(a + b, c)\nf(a + b)\nxs[i + 1]", "f(a+b, c)\nf(a + b)\nxs[i+1]\n"},
+		{"x = a - -b\ny = a*(b+c)\nz = (a + b)*c", "x = a - -b\ny = a * (b + c)\nz = (a + b) * c\n"},
+		{"if a+b>c and not d or e in f {}", "if a+b > c and not d or e in f {}\n"},
+		{"xs[a+1 : b]\nxs[a+1:]\nf(xs[a+1 : b], c)", "xs[a+1 : b]\nxs[a+1:]\nf(xs[a+1:b], c)\n"},
+
+		// statements and blocks
+		{"x++;y--", "x++\ny--\n"},
+		{"if x {\n\n\ty = 1\n\n\n\tz = 2\n\n}", "if x {\n\ty = 1\n\n\tz = 2\n}\n"},
+		{"if a = f(); a { } else if b { d() } else { c() }", "if a = f(); a {} else if b {\n\td()\n} else {\n\tc()\n}\n"},
+		{"for {}\nfor x {}\nfor i = 0; i < 3; i++ {}\nfor ; ; i++ {}", "for {}\nfor x {}\nfor i = 0; i < 3; i++ {}\nfor ; ; i++ {}\n"},
+		{"for k,v in m if v>1 { break } else { x() }", "for k, v in m if v > 1 {\n\tbreak\n} else {\n\tx()\n}\n"},
+		{"switch x { case 1,2: fallthrough\n case 3: default: y() }", "switch x {\ncase 1, 2:\n\tfallthrough\ncase 3:\ndefault:\n\ty()\n}\n"},
+		{"switch t = typeof x { case int: }", "switch t = typeof x {\ncase int:\n}\n"},
+		{"switch y = 1; typeof x {}", "switch y = 1; typeof x {}\n"},
+
+		// declarations
+		{"const (a = 1; b = 2)", "const (\n\ta = 1\n\tb = 2\n)\n"},
+		{"const (\na = 1\nbbb = 2\n\ncccc = 3\n)", "const (\n\ta   = 1\n\tbbb = 2\n\n\tcccc = 3\n)\n"},
+		{"let (\nx, y int = 1, 2\nz = 3\nw float = 4\n)", "let (\n\tx, y int   = 1, 2\n\tz          = 3\n\tw    float = 4\n)\n"},
+		{"const (\na int = 1\n\nb = 2\n)", "const (\n\ta int = 1\n\n\tb = 2\n)\n"},
+		{"let (\na = 1 // a\nlong = \"x\" // b\n// c\nd = 4 // d\ne = [\n1,\n] // e\n)", "let (\n\ta    = 1   // a\n\tlong = \"x\" // b\n\t// c\n\td = 4 // d\n\te = [\n\t\t1,\n\t] // e\n)\n"},
+		{"let x, y int = 1, 2", "let x, y int = 1, 2\n"},
+		{"var ()", "var ()\n"},
+		{"fn f ( x , y int , z = 1 , ...r ) ( int , string ) { return 1, \"\" }", "fn f(x, y int, z=1, ...r) (int, string) { return 1, \"\" }\n"},
+		{"fn f() int {\nreturn 1\n}", "fn f() int {\n\treturn 1\n}\n"},
+		{"fn f() { a(); b() }", "fn f() { a(); b() }\n"},
+		{"fn f() { if x {} }", "fn f() {\n\tif x {}\n}\n"},
+		{"g = fn { }\nh = fn(x) int { return x }", "g = fn {}\nh = fn(x) int { return x }\n"},
+
+		// line breaks
+		{"x = [\n1,\n2,\n]", "x = [\n\t1,\n\t2,\n]\n"},
+		{"f(a,\nb)", "f(a,\n\tb)\n"},
+		{"x = a +\nb", "x = a +\n\tb\n"},
+		{"fn f(a int,\nb int) {}", "fn f(a int,\n\tb int) {}\n"},
+		{"m = {\n\"k\": 1,\n\"j\": [\n2,\n],\n}", "m = {\n\t\"k\": 1,\n\t\"j\": [\n\t\t2,\n\t],\n}\n"},
+
+		// comments
+		{"// a\n\n\n// b\nx = 1 // c\n/* d */ y = 2\n// e", "// a\n\n// b\nx = 1 // c\n/* d */ y = 2\n// e\n"},
+		{"if x {\n\ty() // a\n\t// b\n}", "if x {\n\ty() // a\n\t// b\n}\n"},
+		{"if x {\n// a\n}", "if x {\n\t// a\n}\n"},
+		{"switch x {\ncase 1:\n\ty()\n\t// a\n// b\ncase 2:\n\t// c\n}", "switch x {\ncase 1:\n\ty()\n\t// a\n// b\ncase 2:\n\t// c\n}\n"},
+		{"x = [\n1, // a\n// b\n]", "x = [\n\t1, // a\n\t// b\n]\n"},
+		{"const (\n\ta = 1 // a\n\t// b\n\tb = 2\n)", "const (\n\ta = 1 // a\n\t// b\n\tb = 2\n)\n"},
+		{"f(/* a */ x)", "f(/* a */ x)\n"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, format(t, test.input), test.input)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "if x {\n\ty()\n}", 0)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		cfg      printer.Config
+		expected string
+	}{
+		{printer.Config{}, "if x {\n\ty()\n}\n"},
+		{printer.Config{Mode: printer.UseSpaces, Tabwidth: 4}, "if x {\n    y()\n}\n"},
+		{printer.Config{Mode: printer.UseSpaces, Tabwidth: 2, Indent: 1}, "  if x {\n    y()\n  }\n"},
+		{printer.Config{Indent: 2}, "\t\tif x {\n\t\t\ty()\n\t\t}\n"},
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, test.cfg.Fprint(&buf, fset, f))
+		require.Equal(t, test.expected, buf.String())
+	}
+}
+
+func TestPrintNodes(t *testing.T) {
+	print := func(fset *token.FileSet, node ast.Node) string {
+		var buf bytes.Buffer
+		require.NoError(t, printer.Fprint(&buf, fset, node))
+		return buf.String()
+	}
+
+	// nodes without position information
+	x := &ast.BinaryExpr{
+		Lhs: &ast.Ident{Name: "a"},
+		Op:  token.MUL,
+		Rhs: &ast.ParenExpr{Expr: &ast.BinaryExpr{
+			Lhs: &ast.BasicLit{Kind: token.INT, Value: "1"},
+			Op:  token.ADD,
+			Rhs: &ast.Ident{Name: "b"},
+		}},
+	}
+	require.Equal(t, "a * (1 + b)", print(nil, x))
+	require.Equal(t, "fn f() {\n\treturn a * (1 + b)\n}", print(nil, &ast.FuncDecl{
+		Name: &ast.Ident{Name: "f"},
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{x}}}},
+	}))
+	require.Equal(t, "(a,)", print(nil, &ast.TupleLit{Elts: []ast.Expr{&ast.Ident{Name: "a"}}}))
+	require.Equal(t, "x = (1, 2)", print(nil, &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.Ident{Name: "x"}},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.TupleLit{Lparen: 1, Elts: []ast.Expr{
+			&ast.BasicLit{Kind: token.INT, Value: "1"},
+			&ast.BasicLit{Kind: token.INT, Value: "2"},
+		}, Rparen: 6}},
+	}))
+
+	// the comments attached to nodes are printed with the nodes
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "// a\nconst (\n\t// b\n\tb = 1 // c\n)\n// d\nfn f() {}", parser.ParseComments)
+	require.NoError(t, err)
+	require.Equal(t, "// a\nconst (\n\t// b\n\tb = 1 // c\n)", print(fset, f.Stmts[0]))
+	require.Equal(t, "// d\nfn f() {}", print(fset, f.Stmts[1]))
+	spec := f.Stmts[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0]
+	require.Equal(t, "// b\nb = 1 // c", print(fset, spec))
+	require.Equal(t, "// b", print(fset, spec.(*ast.ValueSpec).Doc))
+}
+
+// snippets returns the source snippets that parser_test.go expects
+// to parse without errors: the string literals passed to expectParse
+// and parseFile, directly or through a variable named input.
+func snippets(t *testing.T) []string {
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, "../parser/parser_test.go", nil, 0)
+	require.NoError(t, err)
+
+	var list []string
+	var input string
+	goast.Inspect(f, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.AssignStmt:
+			if id, isIdent := n.Lhs[0].(*goast.Ident); isIdent && id.Name == "input" {
+				if lit, isLit := n.Rhs[0].(*goast.BasicLit); isLit {
+					input, err = strconv.Unquote(lit.Value)
+					require.NoError(t, err)
+				}
+			}
+		case *goast.CallExpr:
+			if fun, isIdent := n.Fun.(*goast.Ident); isIdent && (fun.Name == "expectParse" || fun.Name == "parseFile") {
+				switch arg := n.Args[1].(type) {
+				case *goast.BasicLit:
+					s, err := strconv.Unquote(arg.Value)
+					require.NoError(t, err)
+					list = append(list, s)
+				case *goast.Ident:
+					if arg.Name == "input" {
+						list = append(list, input)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return list
+}
+
+// stripPositions clears the position information and the resolved
+// objects of all nodes in the syntax tree of f.
+func stripPositions(f *ast.File) {
+	f.Scope = nil
+	f.Unresolved = nil
+	f.Comments = nil
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		v := reflect.ValueOf(n).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			switch field := v.Field(i); {
+			case field.Type() == posType:
+				field.SetInt(0)
+			case field.Type() == reflect.TypeOf((*ast.Object)(nil)):
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+		return true
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	list := snippets(t)
+	require.NotEmpty(t, list)
+
+	for _, src := range list {
+		out := format(t, src)
+		require.Equal(t, out, format(t, out), "printing is not idempotent:\n%s", src)
+
+		fset := token.NewFileSet()
+		orig, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+		require.NoError(t, err)
+		printed, err := parser.ParseFile(fset, "", out, parser.ParseComments)
+		require.NoError(t, err, out)
+		stripPositions(orig)
+		stripPositions(printed)
+		require.Equal(t, orig, printed, "printed AST differs:\n%s\n-- printed --\n%s", src, out)
+	}
+}
@@ -6,33 +6,140 @@ import (
 	"io"
 	"strings"
 
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/eval"
+	"github.com/capnspacehook/rose/lexer"
+	"github.com/capnspacehook/rose/object"
 	"github.com/capnspacehook/rose/parser"
 	"github.com/capnspacehook/rose/token"
-
-	"github.com/capnspacehook/pretty"
+	"github.com/capnspacehook/rose/types"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT      = ">> "
+	CONT_PROMPT = ".. "
+
+	// Filename is the file name of the entries read by the REPL.
+	Filename = "<repl>"
+)
 
-func Start(in io.Reader, out io.Writer) {
+// Start reads entries from in, evaluates them and writes their
+// values to out. An entry is read line by line until it is complete,
+// and each entry is added to fset as a file named Filename. The
+// declarations of an entry are visible in all following entries.
+func Start(fset *token.FileSet, in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	scope := ast.NewScope(nil)
+	env := object.NewEnvironment()
+	env.SetOutput(out)
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		src, ok := readEntry(scanner, out)
+		if !ok {
 			return
 		}
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
 
-		line := scanner.Text()
-		fset := token.NewFileSet()
-		file := fset.AddFile("", -1, len(line))
-		ast, err := parser.ParseFile(file, strings.NewReader(line))
+		val, err := evalEntry(fset, src, scope, env)
 		if err != nil {
-			fmt.Fprintf(out, "error: %v\n", err)
+			diagnostics.Fprint(out, fset, []byte(src), err)
 			continue
 		}
+		if val != nil {
+			fmt.Fprintln(out, val)
+		}
+	}
+}
+
+// readEntry reads lines from scanner until they form a complete entry,
+// prompting for each line on out. It reports false if there is no more
+// input.
+func readEntry(scanner *bufio.Scanner, out io.Writer) (string, bool) {
+	fmt.Fprint(out, PROMPT)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		src := strings.Join(lines, "\n")
+		if !incomplete(src) {
+			return src, true
+		}
+		fmt.Fprint(out, CONT_PROMPT)
+	}
+	if lines != nil {
+		// evaluate what there is to report its errors
+		return strings.Join(lines, "\n"), true
+	}
+	return "", false
+}
+
+// incomplete reports whether src needs more lines to form a complete
+// entry: if it has unclosed parentheses, brackets, braces, string
+// interpolations, raw strings or comments, or ends with a binary
+// operator.
+func incomplete(src string) bool {
+	unterminated := false
+	errh := func(_ token.Position, msg string) {
+		switch msg {
+		case "raw string literal not terminated", "comment not terminated":
+			unterminated = true
+		}
+	}
+
+	var lx lexer.Lexer
+	lx.Init(token.NewFileSet().AddFile("", -1, len(src)), []byte(src), errh, false)
+
+	depth := 0
+	last := token.ILLEGAL
+	for {
+		_, tok, lit := lx.Lex()
+		switch tok {
+		case token.EOF:
+			return unterminated || depth > 0 || last.Precedence() > token.LowestPrec
+		case token.LPAREN, token.LBRACK, token.LBRACE, token.STRING_HEAD:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE, token.STRING_TAIL:
+			depth--
+		case token.SEMI:
+			if lit != ";" {
+				// automatically inserted
+				continue
+			}
+		}
+		last = tok
+	}
+}
+
+// evalEntry parses, type checks and evaluates the entry src. The
+// entry is parsed in a new scope nested in scope and evaluated in a
+// new environment nested in env. Both are merged into scope and env
+// once the entry is evaluated, so that an entry with errors does not
+// declare anything.
+func evalEntry(fset *token.FileSet, src string, scope *ast.Scope, env *object.Environment) (object.Object, error) {
+	entryScope := ast.NewScope(scope)
+	f, err := parser.ParseFileInScope(fset, Filename, src, parser.DeclarationErrors, entryScope)
+	if err != nil {
+		return nil, err
+	}
+	if err := types.Check(fset, f, nil); err != nil {
+		return nil, err
+	}
+
+	entryEnv := object.NewEnclosedEnvironment(env)
+	val, err := eval.Eval(f, entryEnv)
+	if err != nil {
+		if e, ok := err.(*eval.Error); ok {
+			return nil, e.Diagnostic(fset)
+		}
+		return nil, err
+	}
 
-		pretty.Println(ast)
+	for name, obj := range entryScope.Objects {
+		scope.Objects[name] = obj
 	}
+	env.Merge(entryEnv)
+	return val, nil
 }
@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/capnspacehook/rose/token"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"", false},
+		{"x = 1", false},
+		{"x = (1 +", true},
+		{"x = (1 +\n2)", false},
+		{"x = [1,\n2,", true},
+		{"if x {", true},
+		{"if x {\n}", false},
+		{"const (", true},
+		{"const (\na = 1\n)", false},
+		{"x = 1 +", true},
+		{"x = a and", true},
+		{"x = a or\nb", false},
+		{"x = 1 // +", false},
+		{"x++", false},
+		{"s = `a", true},
+		{"s = `a\nb`", false},
+		{"/* a", true},
+		{`s = "{f(`, true},
+		{"x = )", false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.incomplete, incomplete(test.input), test.input)
+	}
+}
+
+func TestStart(t *testing.T) {
+	input := strings.Join([]string{
+		"x = (1 +",
+		"2)",
+		"x",
+		"const (",
+		"a = iota",
+		"b",
+		")",
+		"a + b",
+		"",
+		"y = x *",
+		"b",
+		"y",
+		"let z = undefined",
+		"let z = 1",
+		"z",
+		"1 / 0",
+		"x += 1; x",
+		"w = 1 / 0",
+		"w",
+		"t = x > 3 and",
+		"y <= 3 or",
+		"not true",
+		"t",
+		"let z = 2; z / 0",
+		"z",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(token.NewFileSet(), strings.NewReader(input), &out)
+	require.Equal(t, strings.Join([]string{
+		">> .. >> 3",
+		">> .. .. .. >> 1",
+		">> >> .. >> 3",
+		">> <repl>:1:9: error[E0006]: undefined: undefined",
+		" 1 | let z = undefined",
+		"   |         ^",
+		">> >> 1",
+		">> <repl>:1:3: error: integer divide by zero",
+		" 1 | 1 / 0",
+		"   |   ^",
+		">> 4",
+		">> <repl>:1:7: error: integer divide by zero",
+		" 1 | w = 1 / 0",
+		"   |       ^",
+		">> <repl>:1:1: error[E0006]: undefined: w",
+		" 1 | w",
+		"   | ^",
+		">> .. .. >> true",
+		">> <repl>:1:14: error: integer divide by zero",
+		" 1 | let z = 2; z / 0",
+		"   |              ^",
+		">> 1",
+		">> ",
+	}, "\n"), out.String())
+}
+
+func TestStartFunctions(t *testing.T) {
+	input := strings.Join([]string{
+		"count = 0",
+		"fn inc() {",
+		"count++",
+		`print("count is", count)`,
+		"}",
+		"inc()",
+		"inc(); count",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(token.NewFileSet(), strings.NewReader(input), &out)
+	require.Equal(t, strings.Join([]string{
+		">> >> .. .. .. >> count is 1",
+		">> count is 2",
+		"2",
+		">> ",
+	}, "\n"), out.String())
+}
+
+func TestStartInterpolatedStrings(t *testing.T) {
+	input := strings.Join([]string{
+		`name = "Rose"`,
+		`"Hi {name}, {len(name)} {{chars}}"`,
+		`x = 1; "sum: {x + 2}!"`,
+		`"{undefined}"`,
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(token.NewFileSet(), strings.NewReader(input), &out)
+	require.Equal(t, strings.Join([]string{
+		">> >> Hi Rose, 4 {chars}",
+		">> sum: 3!",
+		">> <repl>:1:3: error[E0006]: undefined: undefined",
+		` 1 | "{undefined}"`,
+		"   |   ^",
+		">> ",
+	}, "\n"), out.String())
+}
@@ -43,6 +43,11 @@ const (
 	CHAR       // 'a'
 	STRING     // "abc"
 	RAW_STRING // `abc`
+
+	// Segments of interpolated string literals
+	STRING_HEAD // "abc{
+	STRING_MID  // }abc{
+	STRING_TAIL // }abc"
 	literal_end
 
 	operator_beg
@@ -87,6 +92,7 @@ const (
 	GTR    // >
 	ASSIGN // =
 	NOT    // not
+	NOT_IN // not in
 
 	NEQ      // !=
 	LEQ      // <=
@@ -111,15 +117,22 @@ const (
 
 	keyword_beg
 	// Keywords
+	AS
 	BREAK
+	CASE
 	CONST
 	CONTINUE
+	DEFAULT
 	ELSE
 	FALLTHROUGH
 	FN
+	FOR
 	IF
+	IN
 	LET
 	RETURN
+	SWITCH
+	TYPEOF
 	VAR
 	keyword_end
 )
@@ -133,10 +146,15 @@ var tokens = [...]string{
 	IDENT:      "IDENT",
 	INT:        "INT",
 	FLOAT:      "FLOAT",
+	IMAG:       "IMAG",
 	CHAR:       "CHAR",
 	STRING:     "STRING",
 	RAW_STRING: "RAW_STRING",
 
+	STRING_HEAD: "STRING_HEAD",
+	STRING_MID:  "STRING_MID",
+	STRING_TAIL: "STRING_TAIL",
+
 	ADD: "+",
 	SUB: "-",
 	MUL: "*",
@@ -157,7 +175,7 @@ var tokens = [...]string{
 	MUL_ASSIGN: "*=",
 	QUO_ASSIGN: "/=",
 	REM_ASSIGN: "%=",
-	EXP_ASSIGN: "**",
+	EXP_ASSIGN: "**=",
 
 	AND_ASSIGN:     "&=",
 	OR_ASSIGN:      "|=",
@@ -177,6 +195,7 @@ var tokens = [...]string{
 	GTR:    ">",
 	ASSIGN: "=",
 	NOT:    "not",
+	NOT_IN: "not in",
 
 	NEQ:      "!=",
 	LEQ:      "<=",
@@ -198,15 +217,22 @@ var tokens = [...]string{
 	QUES:  "?",
 	EXCLM: "!",
 
+	AS:          "as",
 	BREAK:       "break",
+	CASE:        "case",
 	CONST:       "const",
 	CONTINUE:    "continue",
+	DEFAULT:     "default",
 	ELSE:        "else",
 	FALLTHROUGH: "fallthrough",
 	FN:          "fn",
+	FOR:         "for",
 	IF:          "if",
+	IN:          "in",
 	LET:         "let",
 	RETURN:      "return",
+	SWITCH:      "switch",
+	TYPEOF:      "typeof",
 	VAR:         "var",
 }
 
@@ -248,7 +274,7 @@ func (op Token) Precedence() int {
 		return 1
 	case LAND:
 		return 2
-	case EQL, NEQ, LSS, LEQ, GTR, GEQ:
+	case EQL, NEQ, LSS, LEQ, GTR, GEQ, IN, NOT_IN:
 		return 3
 	case ADD, SUB, OR, XOR:
 		return 4
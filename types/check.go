@@ -0,0 +1,105 @@
+// This file implements the Check function, which drives type-checking.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/token"
+)
+
+// Info holds result type information for a type-checked file.
+type Info struct {
+	// Types maps expressions to their types. Type expressions
+	// are recorded as well. Expressions that could not be
+	// type-checked are not recorded.
+	Types map[ast.Expr]Type
+}
+
+// TypeOf returns the type of expression x, or nil if not found.
+func (info *Info) TypeOf(x ast.Expr) Type {
+	return info.Types[x]
+}
+
+// An operandMode specifies the (addressing) mode of an operand.
+type operandMode int
+
+const (
+	invalid operandMode = iota // operand is invalid
+	novalue                    // operand represents no value (result of a function call w/o result)
+	builtin                    // operand is a builtin function
+	typexpr                    // operand is a type
+	value                      // operand is a computed value
+)
+
+// An operand represents an intermediate value during type checking.
+type operand struct {
+	mode operandMode
+	expr ast.Expr // source expression; or nil
+	typ  Type     // type of the operand; valid if mode is value or typexpr
+	id   builtinId
+}
+
+// funcContext holds the state of the function whose body is
+// being checked.
+type funcContext struct {
+	outer   *funcContext // enclosing function; or nil
+	sig     *Signature
+	infer   bool     // if set, results are inferred from return statements
+	returns [][]Type // result types of return statements, if infer is set
+}
+
+// A Checker maintains the state of the type checker.
+type Checker struct {
+	fset   *token.FileSet
+	info   *Info
+	errors diagnostics.List
+
+	fn   *funcContext // innermost function; or nil at file level
+	iota bool         // if set, a constant declaration is being checked
+}
+
+// Check type-checks the file f, whose positions are recorded in
+// fset. The type of every declared object is recorded in the
+// object's Type field, and if info is non-nil, the types of
+// expressions are recorded in info. The returned error, if any,
+// is a diagnostics.List sorted by position.
+func Check(fset *token.FileSet, f *ast.File, info *Info) error {
+	if info == nil {
+		info = new(Info)
+	}
+	if info.Types == nil {
+		info.Types = make(map[ast.Expr]Type)
+	}
+
+	c := &Checker{
+		fset: fset,
+		info: info,
+	}
+	c.stmtList(f.Stmts)
+
+	c.errors.Sort()
+	return c.errors.Err()
+}
+
+// error reports the error msg with the diagnostic code code at pos.
+func (c *Checker) error(pos token.Pos, code, msg string) {
+	c.errors.Report(&diagnostics.Diagnostic{
+		Code: code,
+		Pos:  c.fset.Position(pos),
+		Span: diagnostics.Span{Pos: pos},
+		Msg:  msg,
+	})
+}
+
+func (c *Checker) errorf(pos token.Pos, code, format string, args ...interface{}) {
+	c.error(pos, code, fmt.Sprintf(format, args...))
+}
+
+func (c *Checker) record(x ast.Expr, typ Type) {
+	if typ != nil && !isInvalid(typ) {
+		c.info.Types[x] = typ
+	}
+}
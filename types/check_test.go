@@ -0,0 +1,373 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/token"
+)
+
+func TestCheckDecls(t *testing.T) {
+	f := expectCheck(t, `const a = 4
+let b = 2.5
+var c list[int]
+d = {"one": 1, "two": 2}
+e = [1, "a"]
+f = 1, 'c'
+g = {1, 2}
+h = "{a} is {b}"
+var (
+	i map[string]set[int]
+	j tuple[int, bool]
+	k fn(x int) bool
+)
+l, m = j
+for n in "abc" {}
+for o, p in d {}
+q = fn(x, y=2.0) { return x, y }
+r = q(1)
+s = c + []
+t = e[0] as string
+u = f[1]
+v = 1 in c
+w = complex(0x1p-2) + 2.5i
+const z = 2 - 1
+y = f[(z - 1) * 3]`)
+
+	for name, expected := range map[string]string{
+		"a": "int",
+		"b": "float",
+		"c": "list[int]",
+		"d": "map[string]int",
+		"e": "list[any]",
+		"f": "tuple[int, char]",
+		"g": "set[int]",
+		"h": "string",
+		"i": "map[string]set[int]",
+		"j": "tuple[int, bool]",
+		"k": "fn(int) bool",
+		"l": "int",
+		"m": "bool",
+		"n": "char",
+		"o": "string",
+		"p": "int",
+		"q": "fn(any, float) (any, float)",
+		"r": "tuple[any, float]",
+		"s": "list[int]",
+		"t": "string",
+		"u": "char",
+		"v": "bool",
+		"w": "complex",
+		"y": "int",
+	} {
+		obj := lookup(f, name)
+		require.NotNil(t, obj, name)
+		require.Equal(t, expected, obj.Type.(Type).String(), name)
+	}
+}
+
+func TestCheckConstIota(t *testing.T) {
+	f := expectCheck(t, `const (
+	a = 'a' + iota
+	b
+	c, d = iota, "d"
+	e, f
+)`)
+
+	for name, expected := range map[string]string{
+		"a": "char",
+		"b": "char",
+		"c": "int",
+		"d": "string",
+		"e": "int",
+		"f": "string",
+	} {
+		obj := lookup(f, name)
+		require.NotNil(t, obj, name)
+		require.Equal(t, expected, obj.Type.(Type).String(), name)
+	}
+}
+
+func TestCheckFuncs(t *testing.T) {
+	f := expectCheck(t, `fn fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+fn void() {
+	print("hi")
+}
+fn either(x) {
+	if x {
+		return 9
+	}
+	return "blue"
+}
+fn split(s string) (string, string) {
+	return s[:1], s[1:]
+}
+fn sum(...nums int) {
+	total = 0
+	for n in nums {
+		total += n
+	}
+	return total
+}
+fn each(l list[int], f fn(i int)) {
+	for e in l {
+		f(e)
+	}
+}
+first, last = split("ab")
+each([1, 2], fn(i) { print(i + 1) })
+total = sum(1, 2, 3) + sum([4, 5]...)
+_ = fib(n=10)`)
+
+	for name, expected := range map[string]string{
+		"fib":    "fn(int) int",
+		"void":   "fn()",
+		"either": "fn(any) any",
+		"split":  "fn(string) (string, string)",
+		"sum":    "fn(...int) int",
+		"each":   "fn(list[int], fn(int))",
+		"first":  "string",
+		"last":   "string",
+		"total":  "int",
+	} {
+		obj := lookup(f, name)
+		require.NotNil(t, obj, name)
+		require.Equal(t, expected, obj.Type.(Type).String(), name)
+	}
+}
+
+func TestCheckInfo(t *testing.T) {
+	input := `x = [1, 2][0] + len("abc") * 2`
+	fset, f := parse(t, input)
+	info := &Info{Types: make(map[ast.Expr]Type)}
+	require.NoError(t, Check(fset, f, info))
+
+	rhs := f.Stmts[0].(*ast.AssignStmt).Rhs[0].(*ast.BinaryExpr)
+	require.Equal(t, Typ[Int], info.TypeOf(rhs))
+	index := rhs.Lhs.(*ast.IndexExpr)
+	require.Equal(t, "list[int]", info.TypeOf(index.X).String())
+	call := rhs.Rhs.(*ast.BinaryExpr).Lhs.(*ast.CallExpr)
+	require.Equal(t, Typ[Int], info.TypeOf(call))
+	require.Nil(t, info.TypeOf(call.Fun))
+}
+
+func TestCheckTypeSwitchVars(t *testing.T) {
+	f := expectCheck(t, `var x any
+switch v = typeof x {
+case int:
+	a = v + 1
+case string, char:
+	b = v
+case nil:
+	c = v
+}`)
+
+	for name, expected := range map[string]string{
+		"a": "int",
+		"b": "any",
+		"c": "any",
+	} {
+		obj := lookup(f, name)
+		require.NotNil(t, obj, name)
+		require.Equal(t, expected, obj.Type.(Type).String(), name)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"x = 1 + 2.5", "<input>:1:7: invalid operation: 1 + 2.5 (mismatched types int and float; use an explicit conversion)"},
+		{"x = 1; y = 1.5; z = x * y", "<input>:1:23: invalid operation: x * y (mismatched types int and float; use an explicit conversion)"},
+		{"x = 1; x += 0.5", "<input>:1:10: invalid operation: x += 0.5 (mismatched types int and float; use an explicit conversion)"},
+		{`x = 1 + "a"`, "<input>:1:7: invalid operation: 1 + \"a\" (mismatched types int and string)"},
+		{`x = 1 == "a"`, "<input>:1:7: invalid operation: 1 == \"a\" (mismatched types int and string)"},
+		{`x = "a" * "b"`, "<input>:1:9: invalid operation: operator * not defined on \"a\" (type string)"},
+		{"x = 1.5 % 2.5", "<input>:1:9: invalid operation: operator % not defined on 1.5 (type float)"},
+		{"x = 1i < 2i", "<input>:1:8: invalid operation: operator < not defined on 1i (type complex)"},
+		{"x = 1.5 + 2i", "<input>:1:9: invalid operation: 1.5 + 2i (mismatched types float and complex; use an explicit conversion)"},
+		{"x = 1i % 2i", "<input>:1:8: invalid operation: operator % not defined on 1i (type complex)"},
+		{"x = true < false", "<input>:1:10: invalid operation: operator < not defined on true (type bool)"},
+		{"x = 1 and true", "<input>:1:7: invalid operation: operator and not defined on 1 (type int)"},
+		{"x = not 1", "<input>:1:5: invalid operation: operator not not defined on 1 (type int)"},
+		{"x = -'a'", "<input>:1:5: invalid operation: operator - not defined on 'a' (type char)"},
+		{`x = 1 in "abc"`, "<input>:1:7: invalid operation: 1 in \"abc\" (mismatched types int and string)"},
+		{`x = "a" in [1, 2]`, "<input>:1:9: invalid operation: \"a\" in (list literal) (mismatched types string and int)"},
+		{"x = 1 in 2", "<input>:1:7: invalid operation: operator in not defined on 2 (type int)"},
+		{"x = 1; x = 2.5", "<input>:1:12: cannot use 2.5 (type float) as int value in assignment"},
+		{"x = nil", "<input>:1:5: use of untyped nil in assignment"},
+		{"var x int; x = [1]", "<input>:1:16: cannot use (list literal) (type list[int]) as int value in assignment"},
+		{`let x list[int] = ["a"]`, "<input>:1:20: cannot use \"a\" (type string) as int value in list literal"},
+		{`let x float = 1`, "<input>:1:15: cannot use 1 (type int) as float value in let declaration"},
+		{"const x = 1; x = 2", "<input>:1:14: cannot assign to x (declared const)"},
+		{"let x = iota", "<input>:1:9: cannot use iota outside constant declaration"},
+		{"let x = 1; x = 2", "<input>:1:12: cannot assign to x (declared let)"},
+		{"let x = 1; x += 2", "<input>:1:12: cannot assign to x (declared let)"},
+		{"const x = 1; x++", "<input>:1:14: cannot assign to x (declared const)"},
+		{"let x = 1.5; x--", "<input>:1:14: cannot assign to x (declared let)"},
+		{`const m = {"a": 1}; m["b"] = 2`, "<input>:1:21: cannot assign to m[\"b\"] (m declared const)"},
+		{"let l = [[1]]; l[0][0] = 2", "<input>:1:16: cannot assign to l[0][0] (l declared let)"},
+		{"t = (1, 2); t[0] = 3", "<input>:1:13: cannot assign to t[0] (tuples are immutable)"},
+		{`s = "ab"; s[0] = 'c'`, "<input>:1:11: cannot assign to s[0] (strings are immutable)"},
+		{"fn f() {}; f = 1", "<input>:1:12: cannot assign to f (neither addressable nor a map index expression)"},
+		{`s = "a"; s++`, "<input>:1:10: invalid operation: s++ (non-numeric type string)"},
+		{"x = y", "<input>:1:5: undefined: y"},
+		{"x = int", "<input>:1:5: int (type) is not an expression"},
+		{"x = len", "<input>:1:5: len must be called"},
+		{"fn f() {}; x = f()", "<input>:1:16: f() (no value) used as value"},
+		{"var x foo", "<input>:1:7: undefined: foo"},
+		{"x = 1; var y x", "<input>:1:14: x is not a type"},
+		{"var m map[list[int]]int", "<input>:1:11: invalid map key type list[int]"},
+		{"s = {[1]}", "<input>:1:5: invalid set element type list[int]"},
+		{"x = 1; x()", "<input>:1:8: invalid operation: cannot call non-function x (type int)"},
+		{"fn f(a int) {}; f()", "<input>:1:19: not enough arguments in call to f"},
+		{"fn f(a int) {}; f(1, 2)", "<input>:1:22: too many arguments in call to f"},
+		{`fn f(a int) {}; f("a")`, "<input>:1:19: cannot use \"a\" (type string) as int value in argument to f"},
+		{"fn f(a=0) {}; f(b=1)", "<input>:1:17: unknown parameter b in call to f"},
+		{"fn f(a, b=1) {}; f(1, b=2, b=3)", "<input>:1:28: duplicate argument for parameter b in call to f"},
+		{"fn f(a int) {}; f([1]...)", "<input>:1:22: cannot use ... in call to non-variadic f"},
+		{"fn f(...a int) {}; l = [1.5]; f(l...)", "<input>:1:33: cannot use l (type list[float]) as list[int] value in argument to f"},
+		{"x = len(1)", "<input>:1:9: invalid argument: 1 (type int) for len"},
+		{"x = len()", "<input>:1:9: not enough arguments for len()"},
+		{"x = range(1, 2, 3, 4)", "<input>:1:21: too many arguments for range(1, 2, 3, 4)"},
+		{`x = int("1")`, "<input>:1:9: cannot convert \"1\" (type string) to int"},
+		{"x = float(1, 2)", "<input>:1:14: too many arguments in conversion to float"},
+		{"x = 1 as int", "<input>:1:5: invalid operation: 1 (type int) is not of type any"},
+		{"x = 1[0]", "<input>:1:5: invalid operation: cannot index 1 (type int)"},
+		{`x = [1]["a"]`, "<input>:1:9: invalid argument: index \"a\" (type string) must be integer"},
+		{`x = {"a": 1}[1]`, "<input>:1:14: cannot use 1 (type int) as string value in map index"},
+		{"x = (1, 2)[2]", "<input>:1:12: invalid argument: index 2 out of bounds [0:2]"},
+		{"x = (1, 2)[-1]", "<input>:1:12: invalid argument: index -1 (constant of type int) must not be negative"},
+		{"const i = 5; x = (1, 2)[i]", "<input>:1:25: invalid argument: index 5 out of bounds [0:2]"},
+		{"x = (1, 2)[1 << 1]", "<input>:1:12: invalid argument: index 2 out of bounds [0:2]"},
+		{"const i = 1; x = [1][i - 2]", "<input>:1:22: invalid argument: index i - 2 (constant of type int) must not be negative"},
+		{`x = "abc"[1:-1]`, "<input>:1:13: invalid argument: index -1 (constant of type int) must not be negative"},
+		{`x = "abc"[1:2:3]`, "<input>:1:5: invalid operation: 3-index slice of string"},
+		{"x = 1[:]", "<input>:1:5: cannot slice 1 (type int)"},
+		{"x, y = 1", "<input>:1:8: cannot unpack 1 (type int) into 2 variables"},
+		{"x, y = (1, 2, 3)", "<input>:1:8: cannot unpack (1, 2, 3) (type tuple[int, int, int]) into 2 variables"},
+		{"x, y, z = 1, 2", "<input>:1:1: assignment mismatch: 3 variables but 2 values"},
+		{"for x in 1 {}", "<input>:1:10: cannot iterate over 1 (type int)"},
+		{"for x, y in [1] {}", "<input>:1:13: cannot unpack element of (list literal) (type int) into 2 variables"},
+		{`fn f() int { return "a" }`, "<input>:1:21: cannot use \"a\" (type string) as int value in return statement"},
+		{"fn f() int { return }", "<input>:1:14: not enough return values\n\twant (int)"},
+		{"fn f() int { return 1, 2 }", "<input>:1:24: too many return values\n\twant (int)"},
+		{`x = 1; switch x { case "a": }`, "<input>:1:24: invalid case \"a\" in switch on x (mismatched types string and int)"},
+		{"var x any; switch typeof x { case x: }", "<input>:1:35: x is not a type"},
+		{"x = _", "<input>:1:5: cannot use _ as value"},
+		{"x = 1 << 1.5", "<input>:1:10: invalid operation: shift count 1.5 (type float) must be integer"},
+		{`l = [1]; l << "a"`, "<input>:1:12: invalid operation: l << \"a\" (mismatched types list[int] and string)"},
+	}
+
+	for _, test := range tests {
+		fset, f := parse(t, test.input)
+		err := Check(fset, f, nil)
+		require.EqualError(t, err, test.err, test.input)
+	}
+}
+
+func TestCheckErrorCodes(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"x = y", diagnostics.Undefined},
+		{`x = 1 + "a"`, diagnostics.TypeMismatch},
+		{"x = -true", diagnostics.InvalidOperation},
+		{"fn f(a int) {}; f()", diagnostics.InvalidCall},
+		{"x = (1, 2)[2]", diagnostics.InvalidIndex},
+		{"fn f() int { return }", diagnostics.InvalidReturn},
+	}
+
+	for _, test := range tests {
+		fset, f := parse(t, test.input)
+		list, ok := Check(fset, f, nil).(diagnostics.List)
+		require.True(t, ok, test.input)
+		require.Equal(t, test.code, list[0].Code, test.input)
+	}
+}
+
+func expectCheck(t *testing.T, input string) *ast.File {
+	fset, f := parse(t, input)
+	require.NoError(t, Check(fset, f, nil))
+
+	return f
+}
+
+func parse(t *testing.T, input string) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", input, parser.DeclarationErrors)
+	require.NoError(t, err)
+
+	return fset, f
+}
+
+// lookup returns the first object named name declared in f.
+func lookup(f *ast.File, name string) *ast.Object {
+	var obj *ast.Object
+	var find func(n interface{})
+	find = func(n interface{}) {
+		if obj != nil {
+			return
+		}
+		switch n := n.(type) {
+		case []ast.Stmt:
+			for _, s := range n {
+				find(s)
+			}
+		case *ast.DeclStmt:
+			find(n.Decl)
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				find(spec.(*ast.ValueSpec).Names)
+			}
+		case *ast.FuncDecl:
+			find([]*ast.Ident{n.Name})
+			find(n.Body.List)
+		case *ast.AssignStmt:
+			find(n.Lhs)
+			for _, x := range n.Rhs {
+				if lit, isLit := x.(*ast.FuncLit); isLit {
+					find(lit.Body.List)
+				}
+			}
+		case []ast.Expr:
+			for _, x := range n {
+				if ident, isIdent := x.(*ast.Ident); isIdent {
+					find([]*ast.Ident{ident})
+				}
+			}
+		case []*ast.Ident:
+			for _, ident := range n {
+				if ident.Name == name && ident.Obj != nil {
+					obj = ident.Obj
+					return
+				}
+			}
+		case *ast.ForInStmt:
+			find(n.Vars)
+			find(n.Body.List)
+		case *ast.SwitchStmt:
+			find(n.Body.List)
+		case *ast.TypeSwitchStmt:
+			find(n.Body.List)
+		case *ast.CaseClause:
+			find(n.Body)
+		case *ast.BlockStmt:
+			find(n.List)
+		}
+	}
+	find(f.Stmts)
+
+	return obj
+}
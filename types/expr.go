@@ -0,0 +1,1097 @@
+// This file implements typechecking of expressions.
+
+package types
+
+import (
+	"go/constant"
+	"math"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/parser"
+	"github.com/capnspacehook/rose/token"
+)
+
+// rawExpr typechecks expression e and initializes x with the
+// expression value or type. If hint is non-nil, it is the type
+// the expression is expected to have; it is used to infer the
+// types of empty container literals and of function literal
+// parameters.
+func (c *Checker) rawExpr(x *operand, e ast.Expr, hint Type) {
+	c.exprInternal(x, e, hint)
+	x.expr = e
+	if x.mode == value || x.mode == typexpr {
+		c.record(e, x.typ)
+	}
+}
+
+// expr typechecks expression e and initializes x with the expression
+// value. If e is not a value, an error is reported and x is invalid.
+func (c *Checker) expr(x *operand, e ast.Expr, hint Type) {
+	c.rawExpr(x, e, hint)
+	c.exclude(x)
+}
+
+// exclude reports an error if x is not a value and invalidates x.
+func (c *Checker) exclude(x *operand) {
+	switch x.mode {
+	case novalue:
+		c.errorf(x.expr.Pos(), diagnostics.InvalidExpression, "%s (no value) used as value", ExprString(x.expr))
+	case builtin:
+		c.errorf(x.expr.Pos(), diagnostics.InvalidExpression, "%s must be called", ExprString(x.expr))
+	case typexpr:
+		c.errorf(x.expr.Pos(), diagnostics.InvalidExpression, "%s (type) is not an expression", ExprString(x.expr))
+	default:
+		return
+	}
+	x.mode = invalid
+}
+
+// typExpr typechecks the type expression e and returns its type.
+// If e is not a type, an error is reported and the result is the
+// invalid type.
+func (c *Checker) typExpr(e ast.Expr) Type {
+	var x operand
+	c.rawExpr(&x, e, nil)
+	switch x.mode {
+	case invalid:
+		// ignore - error reported before
+	case typexpr:
+		return x.typ
+	default:
+		c.errorf(e.Pos(), diagnostics.InvalidExpression, "%s is not a type", ExprString(e))
+	}
+
+	return Typ[Invalid]
+}
+
+func (c *Checker) exprInternal(x *operand, e ast.Expr, hint Type) {
+	x.mode = invalid
+	x.typ = Typ[Invalid]
+
+	switch e := e.(type) {
+	case *ast.BadExpr:
+		// ignore - error reported by the parser
+
+	case *ast.Ident:
+		c.ident(x, e)
+
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT:
+			x.typ = Typ[Int]
+		case token.FLOAT:
+			x.typ = Typ[Float]
+		case token.IMAG:
+			x.typ = Typ[Complex]
+		case token.CHAR:
+			x.typ = Typ[Char]
+		case token.STRING, token.RAW_STRING:
+			x.typ = Typ[String]
+		default:
+			c.errorf(e.Pos(), diagnostics.InvalidExpression, "unsupported literal %s", e.Value)
+			return
+		}
+		x.mode = value
+
+	case *ast.InterpolatedString:
+		// literal segments and interpolated expressions alternate
+		for i := 1; i < len(e.Parts); i += 2 {
+			var y operand
+			c.expr(&y, e.Parts[i], nil)
+		}
+		x.mode = value
+		x.typ = Typ[String]
+
+	case *ast.FuncLit:
+		hintSig, _ := hint.(*Signature)
+		sig := c.funcType(e.Type, hintSig)
+		c.funcBody(sig, e.Body, e.Type.Results == nil)
+		x.mode = value
+		x.typ = sig
+
+	case *ast.ListLit:
+		var elem Type
+		if l, ok := hint.(*List); ok {
+			elem = l.elem
+		}
+		x.mode = value
+		x.typ = NewList(c.elemType(e.Elts, elem, "list literal"))
+
+	case *ast.SetLit:
+		var elem Type
+		if s, ok := hint.(*Set); ok {
+			elem = s.elem
+		}
+		x.mode = value
+		x.typ = NewSet(c.elemType(e.Elts, elem, "set literal"))
+		if elem == nil {
+			c.checkImmutable(e, x.typ.(*Set).elem, "set element")
+		}
+
+	case *ast.MapLit:
+		var key, elem Type
+		if m, ok := hint.(*Map); ok {
+			key, elem = m.key, m.elem
+		}
+		keys := make([]ast.Expr, len(e.Elts))
+		vals := make([]ast.Expr, len(e.Elts))
+		for i, kv := range e.Elts {
+			keys[i], vals[i] = kv.Key, kv.Value
+		}
+		m := NewMap(c.elemType(keys, key, "map literal"), c.elemType(vals, elem, "map literal"))
+		if key == nil {
+			c.checkImmutable(e, m.key, "map key")
+		}
+		x.mode = value
+		x.typ = m
+
+	case *ast.TupleLit:
+		hintTuple, _ := hint.(*Tuple)
+		if hintTuple != nil && hintTuple.Len() != len(e.Elts) {
+			hintTuple = nil
+		}
+		elems := make([]Type, len(e.Elts))
+		for i, elt := range e.Elts {
+			var y operand
+			if hintTuple != nil {
+				c.expr(&y, elt, hintTuple.elems[i])
+				c.assignment(&y, hintTuple.elems[i], "tuple literal")
+				elems[i] = hintTuple.elems[i]
+				continue
+			}
+			c.expr(&y, elt, nil)
+			elems[i] = y.typ
+			if isBasic(y.typ, UntypedNil) {
+				elems[i] = Typ[Any]
+			}
+		}
+		x.mode = value
+		x.typ = NewTuple(elems...)
+
+	case *ast.ParenExpr:
+		c.rawExpr(x, e.Expr, hint)
+
+	case *ast.SelectorExpr:
+		c.expr(x, e.X, nil)
+		if x.mode == invalid {
+			return
+		}
+		// methods and package members are not modeled yet;
+		// selectors are resolved at runtime
+		x.typ = Typ[Any]
+
+	case *ast.IndexExpr:
+		c.indexExpr(x, e)
+
+	case *ast.SliceExpr:
+		c.sliceExpr(x, e)
+
+	case *ast.TypeAssertExpr:
+		c.expr(x, e.X, nil)
+		if x.mode == invalid {
+			return
+		}
+		T := c.typExpr(e.Type)
+		if isInvalid(T) {
+			x.mode = invalid
+			return
+		}
+		if !isAny(x.typ) {
+			c.errorf(x.expr.Pos(), diagnostics.InvalidOperation, "invalid operation: %s (type %s) is not of type any", ExprString(e.X), x.typ)
+			x.mode = invalid
+			return
+		}
+		x.typ = T
+
+	case *ast.CallExpr:
+		c.call(x, e)
+
+	case *ast.UnaryExpr:
+		c.expr(x, e.Expr, nil)
+		if x.mode == invalid {
+			return
+		}
+		c.unary(x, e)
+
+	case *ast.BinaryExpr:
+		var y operand
+		c.expr(x, e.Lhs, nil)
+		c.expr(&y, e.Rhs, c.operandHint(x, e.Op))
+		if x.mode == invalid {
+			return
+		}
+		if y.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		c.binary(x, &y, e.Op, e.OpPos, ExprString(e))
+
+	case *ast.FuncType:
+		x.mode = typexpr
+		x.typ = c.funcType(e, nil)
+
+	case *ast.ListType:
+		x.mode = typexpr
+		x.typ = NewList(c.typExpr(e.Elt))
+
+	case *ast.SetType:
+		elem := c.typExpr(e.Elt)
+		c.checkImmutable(e.Elt, elem, "set element")
+		x.mode = typexpr
+		x.typ = NewSet(elem)
+
+	case *ast.MapType:
+		key := c.typExpr(e.Key)
+		c.checkImmutable(e.Key, key, "map key")
+		x.mode = typexpr
+		x.typ = NewMap(key, c.typExpr(e.Value))
+
+	case *ast.TupleType:
+		elems := make([]Type, len(e.Elts))
+		for i, elt := range e.Elts {
+			elems[i] = c.typExpr(elt)
+		}
+		x.mode = typexpr
+		x.typ = NewTuple(elems...)
+
+	default:
+		// *ast.KeywordArg and *ast.KeyValueExpr are handled
+		// by calls and map literals, respectively
+		c.errorf(e.Pos(), diagnostics.InvalidExpression, "unexpected %s", ExprString(e))
+	}
+}
+
+func (c *Checker) ident(x *operand, e *ast.Ident) {
+	if e.Name == "_" {
+		c.error(e.Pos(), diagnostics.InvalidExpression, "cannot use _ as value")
+		return
+	}
+
+	if e.Obj == nil {
+		if typ, ok := predeclaredTypes[e.Name]; ok {
+			x.mode = typexpr
+			x.typ = typ
+		} else if typ, ok := predeclaredConsts[e.Name]; ok {
+			x.mode = value
+			x.typ = typ
+		} else if id, ok := predeclaredFuncs[e.Name]; ok {
+			x.mode = builtin
+			x.id = id
+		} else if e.Name == "iota" {
+			if !c.iota {
+				c.error(e.Pos(), diagnostics.InvalidExpression, "cannot use iota outside constant declaration")
+				return
+			}
+			x.mode = value
+			x.typ = Typ[Int]
+		} else {
+			c.errorf(e.Pos(), diagnostics.Undefined, "undefined: %s", e.Name)
+		}
+		return
+	}
+
+	typ := c.objType(e.Obj)
+	if typ == nil || isInvalid(typ) {
+		// the declaration of the object was
+		// erroneous; an error was reported before
+		return
+	}
+	x.mode = value
+	x.typ = typ
+}
+
+// objType returns the type of obj, or nil if it is not known.
+func (c *Checker) objType(obj *ast.Object) Type {
+	switch typ := obj.Type.(type) {
+	case ast.Expr:
+		// the parser records the case type of type switch
+		// variables in clauses that list exactly one type;
+		// it was checked together with the case clause
+		if t, ok := c.info.Types[typ]; ok {
+			obj.Type = t
+		} else if ts, isTypeSwitch := obj.Decl.(*ast.TypeSwitchStmt); isTypeSwitch && isNilIdent(typ) {
+			obj.Type = c.switchedType(ts)
+		} else {
+			obj.Type = Typ[Invalid]
+		}
+	case nil:
+		if ts, isTypeSwitch := obj.Decl.(*ast.TypeSwitchStmt); isTypeSwitch {
+			obj.Type = c.switchedType(ts)
+		}
+	}
+
+	typ, _ := obj.Type.(Type)
+	return typ
+}
+
+// switchedType returns the type of the expression switched on by ts.
+func (c *Checker) switchedType(ts *ast.TypeSwitchStmt) Type {
+	if typ, ok := c.info.Types[ts.X]; ok {
+		return typ
+	}
+	return Typ[Invalid]
+}
+
+func isNilIdent(e ast.Expr) bool {
+	ident, isIdent := unparen(e).(*ast.Ident)
+	return isIdent && ident.Name == "nil" && ident.Obj == nil
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.Expr
+	}
+}
+
+// assignment reports whether x can be assigned to a variable of
+// type T. If T is nil, x may be assigned to a variable of any type
+// other than the one of nil. An error is reported and x is
+// invalidated if the assignment is not permitted. context describes
+// the assignment in error messages.
+func (c *Checker) assignment(x *operand, T Type, context string) bool {
+	if x.mode == invalid {
+		return false
+	}
+	if T == nil {
+		if isBasic(x.typ, UntypedNil) {
+			c.errorf(x.expr.Pos(), diagnostics.TypeMismatch, "use of untyped nil in %s", context)
+			x.mode = invalid
+			return false
+		}
+		return true
+	}
+	if isInvalid(T) {
+		return false
+	}
+	if !assignableTo(x.typ, T) {
+		c.errorf(x.expr.Pos(), diagnostics.TypeMismatch, "cannot use %s (type %s) as %s value in %s", ExprString(x.expr), x.typ, T, context)
+		x.mode = invalid
+		return false
+	}
+
+	return true
+}
+
+// assignableTo reports whether a value of type V is assignable
+// to a variable of type T.
+func assignableTo(V, T Type) bool {
+	if Identical(V, T) || isAny(T) {
+		return true
+	}
+	if isBasic(V, UntypedNil) {
+		_, isSig := T.(*Signature)
+		return isSig
+	}
+	// functions accepting any value may be used wherever
+	// a function accepting more specific values is expected
+	if v, ok := V.(*Signature); ok {
+		if t, ok := T.(*Signature); ok && v.variadic == t.variadic && len(v.params) == len(t.params) {
+			for i, p := range v.params {
+				if !isAny(p.Type) && !Identical(p.Type, t.params[i].Type) {
+					return false
+				}
+			}
+			return identicalLists(v.results, t.results)
+		}
+	}
+
+	return false
+}
+
+// convertibleTo reports whether a value of type V can be
+// explicitly converted to type T.
+func convertibleTo(V, T Type) bool {
+	if assignableTo(V, T) || isAny(V) {
+		return true
+	}
+	isScalar := func(typ Type) bool { return isBasic(typ, Int) || isBasic(typ, Float) || isBasic(typ, Char) }
+	if isScalar(V) && isScalar(T) {
+		return true
+	}
+	// complex values have no real-valued equivalent
+	if isNumeric(V) && isBasic(T, Complex) {
+		return true
+	}
+	// every basic value has a string representation
+	if v, ok := V.(*Basic); ok && v.kind != UntypedNil && isBasic(T, String) {
+		return true
+	}
+	// lists and sets of the same element type are interchangeable
+	if elem, ok := containerElem(V); ok {
+		if telem, ok := containerElem(T); ok {
+			return Identical(elem, telem)
+		}
+	}
+
+	return false
+}
+
+func containerElem(typ Type) (Type, bool) {
+	switch t := typ.(type) {
+	case *List:
+		return t.elem, true
+	case *Set:
+		return t.elem, true
+	}
+	return nil, false
+}
+
+// elemType typechecks the elements of a container literal and returns
+// their type. If elem is non-nil, all elements must be assignable to
+// elem. Otherwise the element type is inferred: it is the type of all
+// elements if they agree, and any if they do not or there are none.
+func (c *Checker) elemType(elts []ast.Expr, elem Type, context string) Type {
+	var inferred Type
+	for _, e := range elts {
+		var x operand
+		c.expr(&x, e, elem)
+		if x.mode == invalid {
+			continue
+		}
+		if elem != nil {
+			c.assignment(&x, elem, context)
+			continue
+		}
+		typ := x.typ
+		if isBasic(typ, UntypedNil) {
+			typ = Typ[Any]
+		}
+		if inferred == nil {
+			inferred = typ
+		} else if !Identical(inferred, typ) {
+			inferred = Typ[Any]
+		}
+	}
+
+	if elem != nil {
+		return elem
+	}
+	if inferred == nil {
+		inferred = Typ[Any]
+	}
+	return inferred
+}
+
+func (c *Checker) checkImmutable(e ast.Expr, typ Type, what string) {
+	if !isImmutable(typ) {
+		c.errorf(e.Pos(), diagnostics.InvalidExpression, "invalid %s type %s", what, typ)
+	}
+}
+
+func (c *Checker) indexExpr(x *operand, e *ast.IndexExpr) {
+	c.expr(x, e.X, nil)
+	if x.mode == invalid {
+		return
+	}
+
+	switch typ := x.typ.(type) {
+	case *Basic:
+		switch typ.kind {
+		case String:
+			c.index(e.Index)
+			x.typ = Typ[Char]
+			return
+		case Any:
+			c.index(e.Index)
+			return
+		}
+	case *List:
+		c.index(e.Index)
+		x.typ = typ.elem
+		return
+	case *Set:
+		c.index(e.Index)
+		x.typ = typ.elem
+		return
+	case *Map:
+		var key operand
+		c.expr(&key, e.Index, typ.key)
+		c.assignment(&key, typ.key, "map index")
+		x.typ = typ.elem
+		return
+	case *Tuple:
+		i, isConst := c.index(e.Index)
+		switch {
+		case !isConst:
+			x.typ = commonType(typ.elems)
+		case i < int64(typ.Len()):
+			x.typ = typ.elems[i]
+		default:
+			c.errorf(e.Index.Pos(), diagnostics.InvalidIndex, "invalid argument: index %d out of bounds [0:%d]", i, typ.Len())
+			x.mode = invalid
+		}
+		return
+	}
+
+	c.errorf(x.expr.Pos(), diagnostics.InvalidOperation, "invalid operation: cannot index %s (type %s)", ExprString(e.X), x.typ)
+	x.mode = invalid
+}
+
+func (c *Checker) sliceExpr(x *operand, e *ast.SliceExpr) {
+	c.expr(x, e.X, nil)
+	if x.mode == invalid {
+		return
+	}
+
+	var indices [3]int64
+	var allConst = true
+	for i, index := range []ast.Expr{e.Low, e.High, e.Max} {
+		if index == nil {
+			allConst = allConst && i == 0
+			continue
+		}
+		val, isConst := c.index(index)
+		indices[i] = val
+		allConst = allConst && isConst
+	}
+
+	switch typ := x.typ.(type) {
+	case *Basic:
+		switch typ.kind {
+		case String:
+			if e.Slice3 {
+				c.error(e.Pos(), diagnostics.InvalidOperation, "invalid operation: 3-index slice of string")
+				x.mode = invalid
+			}
+			return
+		case Any:
+			return
+		}
+	case *List, *Set:
+		return
+	case *Tuple:
+		// the element types of the result are only known if
+		// the slice bounds are
+		high := int64(typ.Len())
+		if e.High != nil {
+			high = indices[1]
+		}
+		if allConst && indices[0] <= high && high <= int64(typ.Len()) {
+			x.typ = NewTuple(typ.elems[indices[0]:high]...)
+		} else {
+			x.typ = Typ[Any]
+		}
+		return
+	}
+
+	c.errorf(x.expr.Pos(), diagnostics.InvalidOperation, "cannot slice %s (type %s)", ExprString(e.X), x.typ)
+	x.mode = invalid
+}
+
+// index typechecks the index expression e, which must be of type
+// int. If e is a constant, its value is returned and isConst is set;
+// negative constant indices are reported.
+func (c *Checker) index(e ast.Expr) (val int64, isConst bool) {
+	var x operand
+	c.expr(&x, e, nil)
+	if x.mode == invalid {
+		return
+	}
+	if !isBasic(x.typ, Int) && !isAny(x.typ) {
+		c.errorf(e.Pos(), diagnostics.InvalidIndex, "invalid argument: index %s (type %s) must be integer", ExprString(e), x.typ)
+		return
+	}
+
+	v := parser.ConstValue(e)
+	if v == nil || v.Kind() != constant.Int {
+		return
+	}
+	if constant.Sign(v) < 0 {
+		c.errorf(e.Pos(), diagnostics.InvalidIndex, "invalid argument: index %s (constant of type int) must not be negative", ExprString(e))
+		return
+	}
+	if val, isConst = constant.Int64Val(v); !isConst {
+		// too large for any container
+		val, isConst = math.MaxInt64, true
+	}
+	return
+}
+
+// commonType returns the type shared by all types in list, or any
+// if they differ.
+func commonType(list []Type) Type {
+	if len(list) == 0 {
+		return Typ[Any]
+	}
+	for _, typ := range list[1:] {
+		if !Identical(list[0], typ) {
+			return Typ[Any]
+		}
+	}
+	return list[0]
+}
+
+func (c *Checker) call(x *operand, e *ast.CallExpr) {
+	c.rawExpr(x, e.Fun, nil)
+	switch x.mode {
+	case invalid:
+		c.useArgs(e.Args)
+		return
+	case typexpr:
+		c.conversion(x, e)
+		return
+	case builtin:
+		c.builtin(x, e)
+		return
+	case novalue:
+		c.exclude(x)
+		c.useArgs(e.Args)
+		return
+	}
+
+	if isAny(x.typ) {
+		// dynamic call; checked at runtime
+		c.useArgs(e.Args)
+		return
+	}
+	sig, isSig := x.typ.(*Signature)
+	if !isSig {
+		c.errorf(x.expr.Pos(), diagnostics.InvalidOperation, "invalid operation: cannot call non-function %s (type %s)", ExprString(e.Fun), x.typ)
+		c.useArgs(e.Args)
+		x.mode = invalid
+		return
+	}
+
+	c.arguments(e, sig)
+
+	switch {
+	case c.inferring(sig):
+		// recursive call of a function whose
+		// results are not known yet
+		x.typ = Typ[Any]
+	case len(sig.results) == 0:
+		x.mode = novalue
+	case len(sig.results) == 1:
+		x.typ = sig.results[0]
+	default:
+		x.typ = NewTuple(sig.results...)
+	}
+}
+
+// useArgs typechecks the arguments of an erroneous call
+// so that errors in them are reported.
+func (c *Checker) useArgs(args []ast.Expr) {
+	for _, arg := range args {
+		if kw, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			arg = kw.Value
+		}
+		var x operand
+		c.rawExpr(&x, arg, nil)
+	}
+}
+
+// arguments checks the arguments of call against the parameters
+// of sig. Arguments may be passed by position or by name.
+func (c *Checker) arguments(call *ast.CallExpr, sig *Signature) {
+	fun := ExprString(call.Fun)
+	params := sig.params
+	last := len(params) - 1
+	assigned := make([]bool, len(params))
+
+	if call.Ellipsis.IsValid() && !sig.variadic {
+		c.errorf(call.Ellipsis, diagnostics.InvalidCall, "cannot use ... in call to non-variadic %s", fun)
+		c.useArgs(call.Args)
+		return
+	}
+
+	for i, arg := range call.Args {
+		if kw, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			j := -1
+			for k, p := range params {
+				if p.Name == kw.Name.Name && !(sig.variadic && k == last) {
+					j = k
+					break
+				}
+			}
+			switch {
+			case j < 0:
+				c.errorf(kw.Name.Pos(), diagnostics.InvalidCall, "unknown parameter %s in call to %s", kw.Name.Name, fun)
+				c.useArgs([]ast.Expr{kw.Value})
+			case assigned[j]:
+				c.errorf(kw.Name.Pos(), diagnostics.InvalidCall, "duplicate argument for parameter %s in call to %s", kw.Name.Name, fun)
+				c.useArgs([]ast.Expr{kw.Value})
+			default:
+				assigned[j] = true
+				c.argument(kw.Value, params[j].Type, fun)
+			}
+			continue
+		}
+
+		switch {
+		case sig.variadic && i >= last:
+			typ := params[last].Type
+			if call.Ellipsis.IsValid() {
+				// the final argument is spread over the
+				// variadic parameter
+				typ = NewList(typ)
+			}
+			assigned[last] = true
+			c.argument(arg, typ, fun)
+		case i < len(params):
+			assigned[i] = true
+			c.argument(arg, params[i].Type, fun)
+		default:
+			c.errorf(arg.Pos(), diagnostics.InvalidCall, "too many arguments in call to %s", fun)
+			c.useArgs(call.Args[i:])
+			return
+		}
+	}
+
+	for i, p := range params {
+		if !assigned[i] && !p.Optional && !(sig.variadic && i == last) {
+			c.errorf(call.Rparen, diagnostics.InvalidCall, "not enough arguments in call to %s", fun)
+			return
+		}
+	}
+}
+
+func (c *Checker) argument(arg ast.Expr, T Type, fun string) {
+	var x operand
+	c.expr(&x, arg, T)
+	c.assignment(&x, T, "argument to "+fun)
+}
+
+// inferring reports whether the results of sig are being inferred
+// from the body of an enclosing function.
+func (c *Checker) inferring(sig *Signature) bool {
+	for fn := c.fn; fn != nil; fn = fn.outer {
+		if fn.sig == sig {
+			return fn.infer
+		}
+	}
+	return false
+}
+
+func (c *Checker) conversion(x *operand, call *ast.CallExpr) {
+	T := x.typ
+	switch n := len(call.Args); {
+	case n == 0:
+		c.errorf(call.Rparen, diagnostics.InvalidCall, "missing argument in conversion to %s", T)
+		x.mode = invalid
+		return
+	case n > 1:
+		c.errorf(call.Args[n-1].Pos(), diagnostics.InvalidCall, "too many arguments in conversion to %s", T)
+		c.useArgs(call.Args)
+		x.mode = invalid
+		return
+	}
+	if kw, isKwarg := call.Args[0].(*ast.KeywordArg); isKwarg {
+		c.errorf(kw.Pos(), diagnostics.InvalidCall, "unexpected keyword argument in conversion to %s", T)
+		c.useArgs(call.Args)
+		x.mode = invalid
+		return
+	}
+
+	c.expr(x, call.Args[0], T)
+	if x.mode == invalid {
+		return
+	}
+	if !convertibleTo(x.typ, T) {
+		c.errorf(x.expr.Pos(), diagnostics.TypeMismatch, "cannot convert %s (type %s) to %s", ExprString(x.expr), x.typ, T)
+		x.mode = invalid
+		return
+	}
+	x.typ = T
+}
+
+func (c *Checker) builtin(x *operand, call *ast.CallExpr) {
+	id := x.id
+	fun := ExprString(call.Fun)
+
+	args := make([]*operand, 0, len(call.Args))
+	for _, arg := range call.Args {
+		if kw, isKwarg := arg.(*ast.KeywordArg); isKwarg {
+			c.errorf(kw.Pos(), diagnostics.InvalidCall, "unexpected keyword argument in call to %s", fun)
+			arg = kw.Value
+		}
+		y := new(operand)
+		c.expr(y, arg, nil)
+		args = append(args, y)
+	}
+	if call.Ellipsis.IsValid() && id != _Print {
+		c.errorf(call.Ellipsis, diagnostics.InvalidCall, "invalid use of ... with builtin %s", fun)
+		x.mode = invalid
+		return
+	}
+
+	// check argument count
+	min, max := 1, 1
+	switch id {
+	case _Print:
+		min, max = 0, -1
+	case _Range:
+		max = 3
+	}
+	if n := len(args); n < min || max >= 0 && n > max {
+		msg := "not enough"
+		if n > min {
+			msg = "too many"
+		}
+		c.errorf(call.Rparen, diagnostics.InvalidCall, "%s arguments for %s", msg, ExprString(call))
+		x.mode = invalid
+		return
+	}
+	for _, arg := range args {
+		if arg.mode == invalid {
+			x.mode = invalid
+			return
+		}
+	}
+
+	switch id {
+	case _Print:
+		x.mode = novalue
+		return
+
+	case _Len, _Cap:
+		ok := false
+		switch typ := args[0].typ.(type) {
+		case *Basic:
+			ok = typ.kind == Any || typ.kind == String && id == _Len
+		case *List, *Set, *Map:
+			ok = true
+		case *Tuple:
+			ok = id == _Len
+		}
+		if !ok {
+			c.invalidArg(args[0], fun)
+			x.mode = invalid
+			return
+		}
+		x.typ = Typ[Int]
+
+	case _Range:
+		for _, arg := range args {
+			if !isBasic(arg.typ, Int) && !isAny(arg.typ) {
+				c.invalidArg(arg, fun)
+				x.mode = invalid
+				return
+			}
+		}
+		x.typ = NewList(Typ[Int])
+	}
+
+	x.mode = value
+}
+
+func (c *Checker) invalidArg(x *operand, fun string) {
+	c.errorf(x.expr.Pos(), diagnostics.InvalidCall, "invalid argument: %s (type %s) for %s", ExprString(x.expr), x.typ, fun)
+}
+
+func (c *Checker) unary(x *operand, e *ast.UnaryExpr) {
+	if isAny(x.typ) {
+		if e.Op == token.NOT {
+			x.typ = Typ[Bool]
+		}
+		return
+	}
+
+	var ok bool
+	switch e.Op {
+	case token.ADD, token.SUB:
+		ok = isNumeric(x.typ)
+	case token.INVT:
+		ok = isBasic(x.typ, Int)
+	case token.NOT:
+		ok = isBasic(x.typ, Bool)
+	}
+	if !ok {
+		c.opNotDefined(x, e.Op, e.OpPos)
+		x.mode = invalid
+	}
+}
+
+// operandHint returns the type hint for the right operand of
+// an operator whose left operand is x.
+func (c *Checker) operandHint(x *operand, op token.Token) Type {
+	switch op {
+	case token.LAND, token.LOR, token.IN, token.NOT_IN, token.SHL, token.SHR:
+		return nil
+	}
+	if x.mode == invalid {
+		return nil
+	}
+	return x.typ
+}
+
+func isComparison(op token.Token) bool {
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return true
+	}
+	return false
+}
+
+// comparable reports whether values of type x and y may be
+// compared for equality.
+func comparable(x, y Type) bool {
+	if Identical(x, y) || isAny(x) || isAny(y) {
+		return true
+	}
+	isNilable := func(typ Type) bool {
+		_, isSig := typ.(*Signature)
+		return isSig || isBasic(typ, UntypedNil)
+	}
+	return isBasic(x, UntypedNil) && isNilable(y) || isBasic(y, UntypedNil) && isNilable(x)
+}
+
+// binary typechecks the binary operation x op y, where expr
+// describes the operation in error messages. The result is
+// recorded in x.
+func (c *Checker) binary(x, y *operand, op token.Token, opPos token.Pos, expr string) {
+	X, Y := x.typ, y.typ
+
+	switch {
+	case op == token.LAND || op == token.LOR:
+		for _, z := range []*operand{x, y} {
+			if !isBasic(z.typ, Bool) && !isAny(z.typ) {
+				c.opNotDefined(z, op, opPos)
+				x.mode = invalid
+				return
+			}
+		}
+		x.typ = Typ[Bool]
+
+	case op == token.IN || op == token.NOT_IN:
+		c.membership(x, y, op, opPos, expr)
+
+	case isComparison(op):
+		if !comparable(X, Y) {
+			c.mismatched(x, y, opPos, expr)
+			return
+		}
+		if op != token.EQL && op != token.NEQ && !isAny(X) && !isAny(Y) && !isOrdered(X) {
+			c.opNotDefined(x, op, opPos)
+			x.mode = invalid
+			return
+		}
+		x.typ = Typ[Bool]
+
+	case op == token.SHL && isList(X):
+		// l << v appends v to l
+		if !assignableTo(Y, X.(*List).elem) {
+			c.mismatched(x, y, opPos, expr)
+			return
+		}
+
+	case op == token.SHR && isList(Y):
+		// v >> l prepends v to l
+		if !assignableTo(X, Y.(*List).elem) {
+			c.mismatched(x, y, opPos, expr)
+			return
+		}
+		x.typ = Y
+
+	case isAny(X) || isAny(Y):
+		// checked at runtime
+		x.typ = Typ[Any]
+
+	case op == token.SHL || op == token.SHR:
+		if !isBasic(X, Int) {
+			c.opNotDefined(x, op, opPos)
+			x.mode = invalid
+			return
+		}
+		if !isBasic(Y, Int) {
+			c.errorf(y.expr.Pos(), diagnostics.InvalidOperation, "invalid operation: shift count %s (type %s) must be integer", ExprString(y.expr), Y)
+			x.mode = invalid
+			return
+		}
+
+	case (op == token.ADD || op == token.SUB) && isBasic(X, Char) && (isBasic(Y, Char) || isBasic(Y, Int)):
+		// chars may be offset by ints and other chars
+
+	default:
+		if !Identical(X, Y) {
+			c.mismatched(x, y, opPos, expr)
+			return
+		}
+		if !arithmeticDefined(op, X) {
+			c.opNotDefined(x, op, opPos)
+			x.mode = invalid
+			return
+		}
+	}
+}
+
+func isList(typ Type) bool {
+	_, ok := typ.(*List)
+	return ok
+}
+
+// arithmeticDefined reports whether the arithmetic operator
+// op is defined on operands of type typ.
+func arithmeticDefined(op token.Token, typ Type) bool {
+	_, isSet := typ.(*Set)
+	switch op {
+	case token.ADD:
+		return isNumeric(typ) || isBasic(typ, String) || isList(typ)
+	case token.SUB:
+		return isNumeric(typ) || isSet
+	case token.MUL, token.QUO, token.EXP:
+		return isNumeric(typ)
+	case token.REM, token.AND_NOT:
+		return isBasic(typ, Int)
+	case token.AND, token.OR, token.XOR:
+		return isBasic(typ, Int) || isSet
+	}
+	return false
+}
+
+func (c *Checker) membership(x, y *operand, op token.Token, opPos token.Pos, expr string) {
+	var elem Type
+	switch typ := y.typ.(type) {
+	case *Basic:
+		switch typ.kind {
+		case String:
+			if !isBasic(x.typ, Char) && !isBasic(x.typ, String) && !isAny(x.typ) {
+				c.mismatched(x, y, opPos, expr)
+				return
+			}
+			x.typ = Typ[Bool]
+			return
+		case Any:
+			x.typ = Typ[Bool]
+			return
+		}
+	case *List:
+		elem = typ.elem
+	case *Set:
+		elem = typ.elem
+	case *Map:
+		elem = typ.key
+	case *Tuple:
+		elem = Typ[Any]
+	}
+
+	if elem == nil {
+		c.opNotDefined(y, op, opPos)
+		x.mode = invalid
+		return
+	}
+	if !assignableTo(x.typ, elem) && !isAny(x.typ) {
+		c.errorf(opPos, diagnostics.TypeMismatch, "invalid operation: %s (mismatched types %s and %s)", expr, x.typ, elem)
+		x.mode = invalid
+		return
+	}
+	x.typ = Typ[Bool]
+}
+
+func (c *Checker) mismatched(x, y *operand, opPos token.Pos, expr string) {
+	if isNumeric(x.typ) && isNumeric(y.typ) {
+		// ints and floats are never converted implicitly
+		c.errorf(opPos, diagnostics.TypeMismatch, "invalid operation: %s (mismatched types %s and %s; use an explicit conversion)", expr, x.typ, y.typ)
+	} else {
+		c.errorf(opPos, diagnostics.TypeMismatch, "invalid operation: %s (mismatched types %s and %s)", expr, x.typ, y.typ)
+	}
+	x.mode = invalid
+}
+
+func (c *Checker) opNotDefined(x *operand, op token.Token, opPos token.Pos) {
+	c.errorf(opPos, diagnostics.InvalidOperation, "invalid operation: operator %s not defined on %s (type %s)", op, ExprString(x.expr), x.typ)
+}
@@ -0,0 +1,225 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+
+// This file implements printing of expressions.
+
+package types
+
+import (
+	"bytes"
+
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/token"
+)
+
+// ExprString returns the (possibly shortened) string representation for x.
+// Shortened representations are suitable for user interfaces but may not
+// necessarily follow Rose syntax.
+func ExprString(x ast.Expr) string {
+	var buf bytes.Buffer
+	WriteExpr(&buf, x)
+	return buf.String()
+}
+
+// WriteExpr writes the (possibly shortened) string representation for x to buf.
+// Shortened representations are suitable for user interfaces but may not
+// necessarily follow Rose syntax.
+func WriteExpr(buf *bytes.Buffer, x ast.Expr) {
+	// The AST preserves source-level parentheses so there is
+	// no need to introduce them here to correct for different
+	// operator precedences. (This assumes that the AST was
+	// generated by a Rose parser.)
+
+	switch x := x.(type) {
+	default:
+		buf.WriteString("(bad expr)") // nil, ast.BadExpr, ast.KeyValueExpr
+
+	case *ast.Ident:
+		buf.WriteString(x.Name)
+
+	case *ast.BasicLit:
+		buf.WriteString(x.Value)
+
+	case *ast.FuncLit:
+		buf.WriteByte('(')
+		WriteExpr(buf, x.Type)
+		buf.WriteString(" literal)") // shortened
+
+	case *ast.InterpolatedString:
+		for _, part := range x.Parts {
+			WriteExpr(buf, part)
+		}
+
+	case *ast.ListLit:
+		buf.WriteString("(list literal)") // shortened
+
+	case *ast.SetLit:
+		buf.WriteString("(set literal)") // shortened
+
+	case *ast.MapLit:
+		buf.WriteString("(map literal)") // shortened
+
+	case *ast.TupleLit:
+		buf.WriteByte('(')
+		writeExprList(buf, x.Elts)
+		if len(x.Elts) == 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte(')')
+
+	case *ast.ParenExpr:
+		buf.WriteByte('(')
+		WriteExpr(buf, x.Expr)
+		buf.WriteByte(')')
+
+	case *ast.SelectorExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('.')
+		buf.WriteString(x.Sel.Name)
+
+	case *ast.IndexExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('[')
+		WriteExpr(buf, x.Index)
+		buf.WriteByte(']')
+
+	case *ast.SliceExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('[')
+		if x.Low != nil {
+			WriteExpr(buf, x.Low)
+		}
+		buf.WriteByte(':')
+		if x.High != nil {
+			WriteExpr(buf, x.High)
+		}
+		if x.Slice3 {
+			buf.WriteByte(':')
+			if x.Max != nil {
+				WriteExpr(buf, x.Max)
+			}
+		}
+		buf.WriteByte(']')
+
+	case *ast.TypeAssertExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteString(" as ")
+		WriteExpr(buf, x.Type)
+
+	case *ast.CallExpr:
+		WriteExpr(buf, x.Fun)
+		buf.WriteByte('(')
+		writeExprList(buf, x.Args)
+		if x.Ellipsis.IsValid() {
+			buf.WriteString("...")
+		}
+		buf.WriteByte(')')
+
+	case *ast.KeywordArg:
+		buf.WriteString(x.Name.Name)
+		buf.WriteByte('=')
+		WriteExpr(buf, x.Value)
+
+	case *ast.UnaryExpr:
+		buf.WriteString(x.Op.String())
+		if x.Op == token.NOT {
+			buf.WriteByte(' ')
+		}
+		WriteExpr(buf, x.Expr)
+
+	case *ast.BinaryExpr:
+		WriteExpr(buf, x.Lhs)
+		buf.WriteByte(' ')
+		buf.WriteString(x.Op.String())
+		buf.WriteByte(' ')
+		WriteExpr(buf, x.Rhs)
+
+	case *ast.FuncType:
+		buf.WriteString("fn")
+		writeSigExpr(buf, x)
+
+	case *ast.ListType:
+		buf.WriteString("list[")
+		WriteExpr(buf, x.Elt)
+		buf.WriteByte(']')
+
+	case *ast.SetType:
+		buf.WriteString("set[")
+		WriteExpr(buf, x.Elt)
+		buf.WriteByte(']')
+
+	case *ast.MapType:
+		buf.WriteString("map[")
+		WriteExpr(buf, x.Key)
+		buf.WriteByte(']')
+		WriteExpr(buf, x.Value)
+
+	case *ast.TupleType:
+		buf.WriteString("tuple[")
+		writeExprList(buf, x.Elts)
+		buf.WriteByte(']')
+	}
+}
+
+func writeSigExpr(buf *bytes.Buffer, sig *ast.FuncType) {
+	buf.WriteByte('(')
+	writeFieldList(buf, sig.Params)
+	buf.WriteByte(')')
+
+	res := sig.Results
+	n := res.NumFields()
+	if n == 0 {
+		// no result
+		return
+	}
+
+	buf.WriteByte(' ')
+	if n == 1 && len(res.List[0].Names) == 0 {
+		// single unnamed result
+		WriteExpr(buf, res.List[0].Type)
+		return
+	}
+
+	// multiple or named result(s)
+	buf.WriteByte('(')
+	writeFieldList(buf, res)
+	buf.WriteByte(')')
+}
+
+func writeFieldList(buf *bytes.Buffer, fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	for i, f := range fields.List {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		// field list names
+		for i, name := range f.Names {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if i == 0 && f.Ellipsis.IsValid() {
+				buf.WriteString("...")
+			}
+			buf.WriteString(name.Name)
+		}
+
+		// types of parameter/result fields
+		if f.Type != nil {
+			if len(f.Names) > 0 {
+				buf.WriteByte(' ')
+			}
+			WriteExpr(buf, f.Type)
+		}
+	}
+}
+
+func writeExprList(buf *bytes.Buffer, list []ast.Expr) {
+	for i, x := range list {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		WriteExpr(buf, x)
+	}
+}
@@ -0,0 +1,669 @@
+// This file implements typechecking of declarations and statements.
+
+package types
+
+import (
+	"github.com/capnspacehook/rose/ast"
+	"github.com/capnspacehook/rose/diagnostics"
+	"github.com/capnspacehook/rose/token"
+)
+
+// compoundOps maps compound assignment tokens to their
+// corresponding binary operators.
+var compoundOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN:     token.ADD,
+	token.SUB_ASSIGN:     token.SUB,
+	token.MUL_ASSIGN:     token.MUL,
+	token.QUO_ASSIGN:     token.QUO,
+	token.REM_ASSIGN:     token.REM,
+	token.EXP_ASSIGN:     token.EXP,
+	token.AND_ASSIGN:     token.AND,
+	token.OR_ASSIGN:      token.OR,
+	token.XOR_ASSIGN:     token.XOR,
+	token.SHL_ASSIGN:     token.SHL,
+	token.SHR_ASSIGN:     token.SHR,
+	token.AND_NOT_ASSIGN: token.AND_NOT,
+}
+
+func (c *Checker) decl(d ast.Decl) {
+	switch d := d.(type) {
+	case *ast.BadDecl:
+		// ignore - error reported by the parser
+
+	case *ast.GenDecl:
+		if d.Tok == token.CONST {
+			c.constDecl(d)
+			break
+		}
+		for _, spec := range d.Specs {
+			c.valueSpec(spec.(*ast.ValueSpec), d.Tok)
+		}
+
+	case *ast.FuncDecl:
+		sig := c.funcType(d.Type, nil)
+		// set the type before checking the body so
+		// the function can be called recursively
+		d.Name.Obj.Type = sig
+		c.funcBody(sig, d.Body, d.Type.Results == nil)
+	}
+}
+
+func (c *Checker) valueSpec(s *ast.ValueSpec, tok token.Token) {
+	var T Type
+	if s.Type != nil {
+		T = c.typExpr(s.Type)
+	}
+
+	context := tok.String() + " declaration"
+	types := make([]Type, len(s.Names))
+	if len(s.Values) == len(s.Names) {
+		for i, e := range s.Values {
+			types[i] = c.initVar(e, T, context)
+		}
+	} else {
+		// missing or extra values were reported by the parser
+		for _, e := range s.Values {
+			var x operand
+			c.expr(&x, e, T)
+		}
+		for i := range types {
+			types[i] = T
+		}
+	}
+
+	for i, name := range s.Names {
+		obj := name.Obj
+		if obj == nil {
+			continue
+		}
+		obj.Type = types[i]
+		if obj.Type == nil {
+			obj.Type = Typ[Invalid]
+		}
+	}
+}
+
+// constDecl typechecks the constant declaration d. A spec without
+// type and values in a group takes the types of the constants of the
+// last spec with values; their inherited values are not checked again.
+func (c *Checker) constDecl(d *ast.GenDecl) {
+	c.iota = true
+	defer func() { c.iota = false }()
+
+	var last *ast.ValueSpec
+	for i, spec := range d.Specs {
+		s := spec.(*ast.ValueSpec)
+		if i == 0 || s.Type != nil || s.Values != nil || last == nil {
+			c.valueSpec(s, token.CONST)
+			last = s
+			continue
+		}
+
+		for j, name := range s.Names {
+			if name.Obj == nil {
+				continue
+			}
+			name.Obj.Type = Typ[Invalid]
+			if j < len(last.Names) && last.Names[j].Obj != nil {
+				name.Obj.Type = last.Names[j].Obj.Type
+			}
+		}
+	}
+}
+
+// initVar typechecks the initialization expression e of a variable
+// of type T and returns the type of the variable. If T is nil, the
+// type is inferred from e.
+func (c *Checker) initVar(e ast.Expr, T Type, context string) Type {
+	var x operand
+	c.expr(&x, e, T)
+	if !c.assignment(&x, T, context) && T == nil {
+		return Typ[Invalid]
+	}
+	if T == nil {
+		return x.typ
+	}
+	return T
+}
+
+// funcType returns the signature of the function type ft and records
+// the types of its parameters. Parameters without a type or default
+// value take the type of the corresponding parameter of hint, if any,
+// and are of type any otherwise.
+func (c *Checker) funcType(ft *ast.FuncType, hint *Signature) *Signature {
+	var params []*Param
+	variadic := false
+	if ft.Params != nil {
+		for _, f := range ft.Params.List {
+			var T Type
+			if f.Type != nil {
+				T = c.typExpr(f.Type)
+			}
+			if f.Default != nil {
+				T = c.initVar(f.Default, T, "parameter default value")
+			}
+			for _, name := range f.Names {
+				typ := T
+				if typ == nil {
+					typ = Typ[Any]
+					if hint != nil && len(params) < len(hint.params) {
+						typ = hint.params[len(params)].Type
+					}
+				}
+				params = append(params, &Param{Name: name.Name, Type: typ, Optional: f.Default != nil})
+
+				if f.Ellipsis.IsValid() {
+					variadic = true
+					// variadic arguments are collected in a list
+					typ = NewList(typ)
+				}
+				if name.Obj != nil {
+					name.Obj.Type = typ
+				}
+			}
+		}
+	}
+
+	var results []Type
+	if ft.Results != nil {
+		for _, f := range ft.Results.List {
+			results = append(results, c.typExpr(f.Type))
+		}
+	}
+
+	return NewSignature(params, results, variadic)
+}
+
+// funcBody typechecks the body of a function with signature sig.
+// If infer is set, the results of sig are inferred from the return
+// statements of the body.
+func (c *Checker) funcBody(sig *Signature, body *ast.BlockStmt, infer bool) {
+	c.fn = &funcContext{outer: c.fn, sig: sig, infer: infer}
+	c.stmtList(body.List)
+	if c.fn.infer {
+		sig.results = inferResults(c.fn.returns)
+	}
+	c.fn = c.fn.outer
+}
+
+// inferResults returns the result types of a function without result
+// type annotations, given the types of the values of its return
+// statements. A function without values to return has no results.
+// Otherwise each result has the type returned by all return statements,
+// or any if they disagree.
+func inferResults(returns [][]Type) []Type {
+	var results []Type
+	for i, list := range returns {
+		if i == 0 {
+			results = append(results, list...)
+			continue
+		}
+		if len(list) != len(results) {
+			return []Type{Typ[Any]}
+		}
+		for j, typ := range list {
+			if !Identical(results[j], typ) {
+				results[j] = Typ[Any]
+			}
+		}
+	}
+
+	return results
+}
+
+func (c *Checker) stmtList(list []ast.Stmt) {
+	for _, s := range list {
+		c.stmt(s)
+	}
+}
+
+func (c *Checker) simpleStmt(s ast.Stmt) {
+	if s != nil {
+		c.stmt(s)
+	}
+}
+
+func (c *Checker) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.BadStmt, *ast.EmptyStmt, *ast.BranchStmt:
+		// ignore
+
+	case *ast.DeclStmt:
+		c.decl(s.Decl)
+
+	case *ast.ExprStmt:
+		var x operand
+		c.rawExpr(&x, s.Expr, nil)
+		if x.mode != novalue {
+			c.exclude(&x)
+		}
+
+	case *ast.IncDecStmt:
+		T := c.lhsVar(s.Expr)
+		if T == nil || isInvalid(T) {
+			return
+		}
+		if !isNumeric(T) && !isBasic(T, Char) && !isAny(T) {
+			c.errorf(s.Expr.Pos(), diagnostics.InvalidOperation, "invalid operation: %s%s (non-numeric type %s)", ExprString(s.Expr), s.Tok, T)
+		}
+
+	case *ast.AssignStmt:
+		c.assignStmt(s)
+
+	case *ast.ReturnStmt:
+		c.returnStmt(s)
+
+	case *ast.BlockStmt:
+		c.stmtList(s.List)
+
+	case *ast.IfStmt:
+		c.simpleStmt(s.Init)
+		c.cond(s.Cond)
+		c.stmtList(s.Body.List)
+		if s.Else != nil {
+			c.stmt(s.Else)
+		}
+
+	case *ast.SwitchStmt:
+		c.simpleStmt(s.Init)
+		var tag operand
+		if s.Tag != nil {
+			c.expr(&tag, s.Tag, nil)
+		}
+		for _, clause := range s.Body.List {
+			clause := clause.(*ast.CaseClause)
+			for _, e := range clause.List {
+				if s.Tag == nil {
+					// tagless switches select the first true case
+					c.cond(e)
+					continue
+				}
+				var x operand
+				c.expr(&x, e, c.operandHint(&tag, token.EQL))
+				if x.mode != invalid && tag.mode != invalid && !comparable(x.typ, tag.typ) {
+					c.errorf(e.Pos(), diagnostics.TypeMismatch, "invalid case %s in switch on %s (mismatched types %s and %s)",
+						ExprString(e), ExprString(s.Tag), x.typ, tag.typ)
+				}
+			}
+			c.stmtList(clause.Body)
+		}
+
+	case *ast.TypeSwitchStmt:
+		c.simpleStmt(s.Init)
+		var x operand
+		c.expr(&x, s.X, nil)
+		for _, clause := range s.Body.List {
+			clause := clause.(*ast.CaseClause)
+			for _, e := range clause.List {
+				if !isNilIdent(e) {
+					c.typExpr(e)
+				}
+			}
+			c.stmtList(clause.Body)
+		}
+
+	case *ast.ForStmt:
+		c.simpleStmt(s.Init)
+		if s.Cond != nil {
+			c.cond(s.Cond)
+		}
+		c.simpleStmt(s.Post)
+		c.stmtList(s.Body.List)
+		if s.Else != nil {
+			c.stmtList(s.Else.List)
+		}
+
+	case *ast.ForInStmt:
+		c.forInStmt(s)
+
+	default:
+		c.error(s.Pos(), diagnostics.InvalidExpression, "invalid statement")
+	}
+}
+
+// cond typechecks the condition e. Values of every type may be used
+// as conditions: zero values are false and all other values are true.
+func (c *Checker) cond(e ast.Expr) {
+	var x operand
+	c.expr(&x, e, nil)
+}
+
+func (c *Checker) forInStmt(s *ast.ForInStmt) {
+	var x operand
+	c.expr(&x, s.X, nil)
+
+	types := make([]Type, len(s.Vars))
+	if x.mode != invalid {
+		if list := c.iterVars(&x, len(s.Vars)); list != nil {
+			types = list
+		}
+	}
+	for i, v := range s.Vars {
+		if v.Obj == nil {
+			continue
+		}
+		v.Obj.Type = types[i]
+		if types[i] == nil {
+			v.Obj.Type = Typ[Invalid]
+		}
+	}
+
+	if s.Filter != nil {
+		c.cond(s.Filter)
+	}
+	c.stmtList(s.Body.List)
+	if s.Else != nil {
+		c.stmtList(s.Else.List)
+	}
+}
+
+// iterVars returns the types of the n iteration variables of a for
+// in statement iterating over x, or nil if x cannot be iterated over.
+// Iterating over a map yields its keys, or its keys and values if
+// there are two variables. Otherwise, elements are unpacked if there
+// is more than one variable.
+func (c *Checker) iterVars(x *operand, n int) []Type {
+	var elem Type
+	switch typ := x.typ.(type) {
+	case *Basic:
+		switch typ.kind {
+		case String:
+			elem = Typ[Char]
+		case Any:
+			elem = Typ[Any]
+		}
+	case *List:
+		elem = typ.elem
+	case *Set:
+		elem = typ.elem
+	case *Tuple:
+		elem = commonType(typ.elems)
+	case *Map:
+		if n == 2 {
+			return []Type{typ.key, typ.elem}
+		}
+		elem = typ.key
+	}
+
+	if elem == nil {
+		c.errorf(x.expr.Pos(), diagnostics.InvalidOperation, "cannot iterate over %s (type %s)", ExprString(x.expr), x.typ)
+		return nil
+	}
+	if n == 1 {
+		return []Type{elem}
+	}
+	types := unpack(elem, n)
+	if types == nil {
+		c.errorf(x.expr.Pos(), diagnostics.InvalidAssignment, "cannot unpack element of %s (type %s) into %d variables", ExprString(x.expr), elem, n)
+	}
+	return types
+}
+
+// unpack returns the types of the n values a value of type typ
+// is unpacked into, or nil if it cannot be unpacked into n values.
+func unpack(typ Type, n int) []Type {
+	var elem Type
+	switch t := typ.(type) {
+	case *Tuple:
+		if t.Len() == n {
+			return t.elems
+		}
+		return nil
+	case *List:
+		elem = t.elem
+	case *Set:
+		elem = t.elem
+	default:
+		if !isAny(typ) {
+			return nil
+		}
+		elem = typ
+	}
+
+	types := make([]Type, n)
+	for i := range types {
+		types[i] = elem
+	}
+	return types
+}
+
+func (c *Checker) assignStmt(s *ast.AssignStmt) {
+	if s.Tok != token.ASSIGN {
+		c.compoundAssign(s)
+		return
+	}
+
+	nl, nr := len(s.Lhs), len(s.Rhs)
+	switch {
+	case nl == nr:
+		for i, lhs := range s.Lhs {
+			c.assignVar(s, lhs, s.Rhs[i])
+		}
+
+	case nr == 1:
+		// the value is unpacked into the variables
+		var x operand
+		c.expr(&x, s.Rhs[0], nil)
+		var types []Type
+		if x.mode != invalid {
+			if types = unpack(x.typ, nl); types == nil {
+				c.errorf(x.expr.Pos(), diagnostics.InvalidAssignment, "cannot unpack %s (type %s) into %d variables", ExprString(x.expr), x.typ, nl)
+			}
+		}
+		for i, lhs := range s.Lhs {
+			typ := Type(Typ[Invalid])
+			if types != nil {
+				typ = types[i]
+			}
+			c.assignVarType(s, lhs, typ)
+		}
+
+	default:
+		c.errorf(s.Pos(), diagnostics.InvalidAssignment, "assignment mismatch: %d variables but %d values", nl, nr)
+		for _, lhs := range s.Lhs {
+			c.assignVarType(s, lhs, Typ[Invalid])
+		}
+		c.useArgs(s.Rhs)
+	}
+}
+
+func (c *Checker) compoundAssign(s *ast.AssignStmt) {
+	op := compoundOps[s.Tok]
+	T := c.lhsVar(s.Lhs[0])
+	x := operand{mode: value, expr: s.Lhs[0], typ: T}
+	if T == nil || isInvalid(T) {
+		x.mode = invalid
+	}
+
+	var y operand
+	c.expr(&y, s.Rhs[0], c.operandHint(&x, op))
+	if x.mode == invalid || y.mode == invalid {
+		return
+	}
+
+	c.binary(&x, &y, op, s.TokPos, ExprString(s.Lhs[0])+" "+s.Tok.String()+" "+ExprString(s.Rhs[0]))
+	if x.mode == invalid {
+		return
+	}
+	if !assignableTo(x.typ, T) {
+		c.errorf(s.TokPos, diagnostics.TypeMismatch, "cannot assign %s value to %s (type %s)", x.typ, ExprString(s.Lhs[0]), T)
+	}
+}
+
+// declaredBy returns the object lhs denotes if lhs is an identifier
+// implicitly declared by the assignment s, and nil otherwise.
+func declaredBy(lhs ast.Expr, s *ast.AssignStmt) *ast.Object {
+	if ident, isIdent := lhs.(*ast.Ident); isIdent && ident.Obj != nil && ident.Obj.Decl == s {
+		return ident.Obj
+	}
+	return nil
+}
+
+// assignVar typechecks the assignment of rhs to lhs in s.
+func (c *Checker) assignVar(s *ast.AssignStmt, lhs, rhs ast.Expr) {
+	var x operand
+	if obj := declaredBy(lhs, s); obj != nil {
+		c.expr(&x, rhs, nil)
+		obj.Type = Typ[Invalid]
+		if c.assignment(&x, nil, "assignment") {
+			obj.Type = x.typ
+		}
+		return
+	}
+
+	T := c.lhsVar(lhs)
+	c.expr(&x, rhs, T)
+	if T == nil || !isInvalid(T) {
+		c.assignment(&x, T, "assignment")
+	}
+}
+
+// assignVarType typechecks the assignment of a value of type typ to
+// lhs in s.
+func (c *Checker) assignVarType(s *ast.AssignStmt, lhs ast.Expr, typ Type) {
+	if obj := declaredBy(lhs, s); obj != nil {
+		obj.Type = typ
+		return
+	}
+
+	T := c.lhsVar(lhs)
+	if T == nil || isInvalid(T) || isInvalid(typ) {
+		return
+	}
+	if !assignableTo(typ, T) {
+		c.errorf(lhs.Pos(), diagnostics.TypeMismatch, "cannot assign %s value to %s (type %s)", typ, ExprString(lhs), T)
+	}
+}
+
+// lhsVar checks that e may be assigned to and returns its type. The
+// result is nil for the blank identifier, and the invalid type if e
+// cannot be assigned to.
+func (c *Checker) lhsVar(e ast.Expr) Type {
+	switch x := unparen(e).(type) {
+	case *ast.Ident:
+		if x.Name == "_" {
+			return nil
+		}
+		if x.Obj == nil {
+			var y operand
+			c.expr(&y, x, nil)
+			if y.mode != invalid {
+				c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (neither addressable nor a map index expression)", ExprString(e))
+			}
+			return Typ[Invalid]
+		}
+		switch x.Obj.Kind {
+		case ast.Con, ast.Let:
+			c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (declared %s)", ExprString(e), x.Obj.Kind)
+			return Typ[Invalid]
+		case ast.Fun:
+			c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (neither addressable nor a map index expression)", ExprString(e))
+			return Typ[Invalid]
+		}
+
+	case *ast.IndexExpr:
+		if root := rootIdent(x.X); root != nil && root.Obj != nil && isConstant(root.Obj) {
+			c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (%s declared %s)", ExprString(e), root.Name, root.Obj.Kind)
+			return Typ[Invalid]
+		}
+		var y operand
+		c.expr(&y, e, nil)
+		if y.mode == invalid {
+			return Typ[Invalid]
+		}
+		switch typ := c.info.Types[x.X].(type) {
+		case *Tuple:
+			c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (tuples are immutable)", ExprString(e))
+			return Typ[Invalid]
+		case *Basic:
+			if typ.kind == String {
+				c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (strings are immutable)", ExprString(e))
+				return Typ[Invalid]
+			}
+		}
+		return y.typ
+
+	case *ast.SelectorExpr:
+		// fields are not modeled yet
+
+	default:
+		c.errorf(e.Pos(), diagnostics.InvalidAssignment, "cannot assign to %s (neither addressable nor a map index expression)", ExprString(e))
+		return Typ[Invalid]
+	}
+
+	var y operand
+	c.expr(&y, e, nil)
+	if y.mode == invalid {
+		return Typ[Invalid]
+	}
+	return y.typ
+}
+
+// isConstant reports whether obj was declared with const or let.
+// Constants and the values they hold can never change.
+func isConstant(obj *ast.Object) bool {
+	return obj.Kind == ast.Con || obj.Kind == ast.Let
+}
+
+// rootIdent returns the identifier at the root of the index or slice
+// expression e, or nil if there is none.
+func rootIdent(e ast.Expr) *ast.Ident {
+	for {
+		switch x := e.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.SliceExpr:
+			e = x.X
+		case *ast.ParenExpr:
+			e = x.Expr
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Checker) returnStmt(s *ast.ReturnStmt) {
+	fn := c.fn
+	if fn == nil {
+		// return outside of a function was reported by the parser
+		c.useArgs(s.Results)
+		return
+	}
+
+	if fn.infer {
+		types := make([]Type, len(s.Results))
+		for i, e := range s.Results {
+			var x operand
+			c.expr(&x, e, nil)
+			types[i] = x.typ
+			if x.mode == invalid || isBasic(x.typ, UntypedNil) {
+				types[i] = Typ[Any]
+			}
+		}
+		fn.returns = append(fn.returns, types)
+		return
+	}
+
+	results := fn.sig.results
+	switch n := len(s.Results); {
+	case n == len(results):
+		for i, e := range s.Results {
+			var x operand
+			c.expr(&x, e, results[i])
+			c.assignment(&x, results[i], "return statement")
+		}
+	case n == 1 && len(results) > 1:
+		// the results of a call may be returned directly
+		var x operand
+		c.expr(&x, s.Results[0], nil)
+		c.assignment(&x, NewTuple(results...), "return statement")
+	case n < len(results):
+		c.useArgs(s.Results)
+		c.errorf(s.Return, diagnostics.InvalidReturn, "not enough return values\n\twant (%s)", typeList(results))
+	default:
+		c.useArgs(s.Results)
+		c.errorf(s.Results[len(results)].Pos(), diagnostics.InvalidReturn, "too many return values\n\twant (%s)", typeList(results))
+	}
+}
@@ -0,0 +1,282 @@
+// Package types declares the data types and implements the algorithms
+// for static type checking of Rose source files.
+package types
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Type represents a type of Rose.
+// All types implement the Type interface.
+type Type interface {
+	// String returns a string representation of a type.
+	String() string
+}
+
+// BasicKind describes the kind of basic type.
+type BasicKind int
+
+const (
+	Invalid BasicKind = iota // type is invalid
+
+	// predeclared types
+	Bool
+	Int
+	Float
+	Complex
+	Char
+	String
+	Any
+
+	// types for untyped values
+	UntypedNil
+)
+
+// A Basic represents a basic type.
+type Basic struct {
+	kind BasicKind
+	name string
+}
+
+// Kind returns the kind of basic type b.
+func (b *Basic) Kind() BasicKind { return b.kind }
+
+// Name returns the name of basic type b.
+func (b *Basic) Name() string { return b.name }
+
+func (b *Basic) String() string { return b.name }
+
+// Typ contains the predeclared *Basic types indexed by their
+// corresponding BasicKind.
+var Typ = [...]*Basic{
+	Invalid:    {Invalid, "invalid type"},
+	Bool:       {Bool, "bool"},
+	Int:        {Int, "int"},
+	Float:      {Float, "float"},
+	Complex:    {Complex, "complex"},
+	Char:       {Char, "char"},
+	String:     {String, "string"},
+	Any:        {Any, "any"},
+	UntypedNil: {UntypedNil, "untyped nil"},
+}
+
+// A List represents a list type.
+type List struct {
+	elem Type
+}
+
+// NewList returns a new list type for the given element type.
+func NewList(elem Type) *List { return &List{elem: elem} }
+
+// Elem returns the element type of list l.
+func (l *List) Elem() Type { return l.elem }
+
+func (l *List) String() string { return "list[" + l.elem.String() + "]" }
+
+// A Set represents a set type.
+type Set struct {
+	elem Type
+}
+
+// NewSet returns a new set type for the given element type.
+func NewSet(elem Type) *Set { return &Set{elem: elem} }
+
+// Elem returns the element type of set s.
+func (s *Set) Elem() Type { return s.elem }
+
+func (s *Set) String() string { return "set[" + s.elem.String() + "]" }
+
+// A Map represents a map type.
+type Map struct {
+	key, elem Type
+}
+
+// NewMap returns a new map for the given key and element types.
+func NewMap(key, elem Type) *Map { return &Map{key: key, elem: elem} }
+
+// Key returns the key type of map m.
+func (m *Map) Key() Type { return m.key }
+
+// Elem returns the element type of map m.
+func (m *Map) Elem() Type { return m.elem }
+
+func (m *Map) String() string { return "map[" + m.key.String() + "]" + m.elem.String() }
+
+// A Tuple represents an ordered, immutable list of values.
+type Tuple struct {
+	elems []Type
+}
+
+// NewTuple returns a new tuple for the given element types.
+func NewTuple(elems ...Type) *Tuple { return &Tuple{elems: elems} }
+
+// Len returns the number of elements of tuple t.
+func (t *Tuple) Len() int { return len(t.elems) }
+
+// At returns the i'th element of tuple t.
+func (t *Tuple) At(i int) Type { return t.elems[i] }
+
+func (t *Tuple) String() string { return "tuple[" + typeList(t.elems) + "]" }
+
+// A Param represents a function parameter.
+type Param struct {
+	Name     string // parameter name; or ""
+	Type     Type   // parameter type; the element type for variadic parameters
+	Optional bool   // set if the parameter has a default value
+}
+
+// A Signature represents a function type.
+type Signature struct {
+	params   []*Param
+	results  []Type
+	variadic bool
+}
+
+// NewSignature returns a new function type for the given parameters
+// and results. If variadic is set, the last parameter is variadic.
+func NewSignature(params []*Param, results []Type, variadic bool) *Signature {
+	return &Signature{params: params, results: results, variadic: variadic}
+}
+
+// Params returns the parameters of signature s.
+func (s *Signature) Params() []*Param { return s.params }
+
+// Results returns the result types of signature s; or nil.
+func (s *Signature) Results() []Type { return s.results }
+
+// Variadic reports whether the signature s is variadic.
+func (s *Signature) Variadic() bool { return s.variadic }
+
+func (s *Signature) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("fn(")
+	for i, p := range s.params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if s.variadic && i == len(s.params)-1 {
+			buf.WriteString("...")
+		}
+		buf.WriteString(p.Type.String())
+	}
+	buf.WriteByte(')')
+	switch len(s.results) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&buf, " %s", s.results[0])
+	default:
+		fmt.Fprintf(&buf, " (%s)", typeList(s.results))
+	}
+
+	return buf.String()
+}
+
+func typeList(list []Type) string {
+	var buf bytes.Buffer
+	for i, typ := range list {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(typ.String())
+	}
+
+	return buf.String()
+}
+
+// Identical reports whether x and y are identical types.
+func Identical(x, y Type) bool {
+	if x == y {
+		return true
+	}
+
+	switch x := x.(type) {
+	case *Basic:
+		// Basic types are singletons, except for
+		// the invalid type which is never identical.
+		if y, ok := y.(*Basic); ok {
+			return x.kind == y.kind && x.kind != Invalid
+		}
+	case *List:
+		if y, ok := y.(*List); ok {
+			return Identical(x.elem, y.elem)
+		}
+	case *Set:
+		if y, ok := y.(*Set); ok {
+			return Identical(x.elem, y.elem)
+		}
+	case *Map:
+		if y, ok := y.(*Map); ok {
+			return Identical(x.key, y.key) && Identical(x.elem, y.elem)
+		}
+	case *Tuple:
+		if y, ok := y.(*Tuple); ok {
+			return identicalLists(x.elems, y.elems)
+		}
+	case *Signature:
+		if y, ok := y.(*Signature); ok {
+			if x.variadic != y.variadic || len(x.params) != len(y.params) {
+				return false
+			}
+			for i, p := range x.params {
+				if !Identical(p.Type, y.params[i].Type) {
+					return false
+				}
+			}
+			return identicalLists(x.results, y.results)
+		}
+	}
+
+	return false
+}
+
+func identicalLists(x, y []Type) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !Identical(x[i], y[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isBasic reports whether typ is the basic type of the given kind.
+func isBasic(typ Type, kind BasicKind) bool {
+	b, ok := typ.(*Basic)
+	return ok && b.kind == kind
+}
+
+func isAny(typ Type) bool     { return isBasic(typ, Any) }
+func isInvalid(typ Type) bool { return isBasic(typ, Invalid) }
+
+// isNumeric reports whether typ is int, float or complex.
+func isNumeric(typ Type) bool {
+	return isBasic(typ, Int) || isBasic(typ, Float) || isBasic(typ, Complex)
+}
+
+// isOrdered reports whether values of type typ can be ordered
+// with <, <=, > and >=.
+func isOrdered(typ Type) bool {
+	return isNumeric(typ) && !isBasic(typ, Complex) || isBasic(typ, Char) || isBasic(typ, String)
+}
+
+// isImmutable reports whether values of type typ are immutable and
+// may therefore be used as map keys and set elements.
+func isImmutable(typ Type) bool {
+	switch t := typ.(type) {
+	case *Basic:
+		return t.kind != UntypedNil
+	case *Tuple:
+		for _, elem := range t.elems {
+			if !isImmutable(elem) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
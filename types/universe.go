@@ -0,0 +1,36 @@
+// This file implements the universe of predeclared identifiers.
+
+package types
+
+// A builtinId identifies a builtin function.
+type builtinId int
+
+const (
+	_Cap builtinId = iota
+	_Len
+	_Print
+	_Range
+)
+
+var predeclaredTypes = map[string]Type{
+	"bool":    Typ[Bool],
+	"int":     Typ[Int],
+	"float":   Typ[Float],
+	"complex": Typ[Complex],
+	"char":    Typ[Char],
+	"string":  Typ[String],
+	"any":     Typ[Any],
+}
+
+var predeclaredConsts = map[string]Type{
+	"true":  Typ[Bool],
+	"false": Typ[Bool],
+	"nil":   Typ[UntypedNil],
+}
+
+var predeclaredFuncs = map[string]builtinId{
+	"cap":   _Cap,
+	"len":   _Len,
+	"print": _Print,
+	"range": _Range,
+}